@@ -219,6 +219,23 @@ const routes: Array<RouteRecordRaw> = [
         },
         component: () => import('../pages/auth/Logout.vue'),
       },
+      {
+        name: 'authorize',
+        path: 'authorize',
+        meta: {
+          nav: {
+            icon: 'vuestic-iconset-dashboard',
+            displayName: 'menu.login',
+            disabled: true,
+            hidden: true,
+          },
+          perms: {
+            requiresAuth: true,
+            requiresScopes: [],
+          },
+        },
+        component: () => import('../pages/auth/Authorize.vue'),
+      },
       {
         name: 'validate_oauth',
         path: 'validate_oauth',
@@ -236,6 +253,23 @@ const routes: Array<RouteRecordRaw> = [
         },
         component: () => import('../pages/auth/ValidateOAuth.vue'),
       },
+      {
+        name: 'validate_twitch',
+        path: 'validate_twitch',
+        meta: {
+          nav: {
+            icon: 'vuestic-iconset-dashboard',
+            displayName: 'menu.activeStreams',
+            disabled: true,
+            hidden: true,
+          },
+          perms: {
+            requiresAuth: false,
+            requiresScopes: [],
+          },
+        },
+        component: () => import('../pages/auth/ValidateOAuth.vue'),
+      },
       {
         path: '',
         meta: {
@@ -345,7 +379,7 @@ router.beforeEach(async (to: any, from: any, next) => {
       if (AuthStore.getAccessToken() === '') {
         console.log("Triggered return to login screen.")
         if (to.path !== '/auth/login') {
-          next('/auth/login?redirect_path='+encodeURIComponent(to.path))
+          next('/auth/login?redirect_path='+encodeURIComponent(to.fullPath))
         } else {
           next('/auth/login')
         }
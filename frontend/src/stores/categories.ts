@@ -41,6 +41,7 @@ export const useCategoryStore = defineStore('categories', {
                 item.id = response.data.data[0].id
                 item.mastodon_tags = response.data.data[0].mastodon_tags
                 item.bluesky_tags = response.data.data[0].bluesky_tags
+                item.discord_post_mode = response.data.data[0].discord_post_mode
                 foundItem = true
                 return
               }
@@ -1,7 +1,6 @@
 import { defineStore } from 'pinia'
 import { useLocalStorage } from '@vueuse/core'
-import { useCookies } from "vue3-cookies"
-import { useRoute } from "vue-router"
+import axios from 'axios'
 
 // Should load these from an env config instead.
 export const apiBaseUrlDev = "https://tl72sifq5iu6gkzpqyyp7umsra0wjejp.lambda-url.ca-central-1.on.aws"
@@ -22,21 +21,6 @@ export const useGlobalStore = defineStore('global', {
     setDevEnvironment(isDevEnvironment: boolean) {
       this.isDevEnvironment = isDevEnvironment
     },
-    getStateBlob() {
-      const route = useRoute()
-      const { cookies } = useCookies()
-
-      const preBlob = {
-        'csrftoken': cookies.get('csrftoken'),
-        'redirect_path': route.query.redirect_path ? decodeURIComponent(String(route.query.redirect_path)) : '/streams',
-      }
-
-      const blob = btoa(JSON.stringify(preBlob))
-      return blob
-    },
-    decodeStateBlob(stateBlob: string) {
-      return JSON.parse(atob(stateBlob))
-    },
     getApiBaseUrl() {
       // TODO: Assemble this more sensibly
       if (this.isDevEnvironment) {
@@ -45,22 +29,66 @@ export const useGlobalStore = defineStore('global', {
         return apiBaseUrlProd
       }
     },
-    getDiscordOAuthUrl() {
+    async getDiscordOAuthUrl(redirectPath: string) {
       // TODO: Assemble this more sensibly
       let client_id
-      const discord_seg1 = 'https://discord.com/oauth2/authorize?client_id='
-      const discord_seg2 = '&response_type=code&redirect_uri='
-      const discord_seg3 = '&scope=identify+connections&state='
-      const state_blob = this.getStateBlob()
-
-      const this_url = encodeURIComponent(window.location.origin + '/shrampybot/auth/validate_oauth')
       if (this.isDevEnvironment) {
         client_id = '1043225123395739780'
       } else {
         client_id = '1042309025506787359'
       }
 
-      return discord_seg1 + client_id + discord_seg2 + this_url + discord_seg3 + state_blob
+      // The API issues the state, PKCE challenge and redirect URI for us
+      const response = await axios.post(
+        '/auth/state',
+        {
+          provider: 'discord',
+          redirect_base: window.location.origin + '/',
+          redirect_path: redirectPath,
+        },
+        {
+          baseURL: this.getApiBaseUrl(),
+          // The state is tied to this browser by a cookie
+          withCredentials: true,
+        },
+      )
+      // Checked on the way back in, before the code is handed to the API
+      sessionStorage.setItem('oauthState', response.data.state)
+
+      const params = new URLSearchParams({
+        client_id: client_id,
+        response_type: 'code',
+        redirect_uri: response.data.redirect_uri,
+        scope: 'identify connections',
+        state: response.data.state,
+        code_challenge: response.data.code_challenge,
+        code_challenge_method: response.data.code_challenge_method,
+      })
+      return 'https://discord.com/oauth2/authorize?' + params.toString()
+    },
+    async getTwitchOAuthUrl(redirectPath: string) {
+      const response = await axios.post(
+        '/auth/state',
+        {
+          provider: 'twitch',
+          redirect_base: window.location.origin + '/',
+          redirect_path: redirectPath,
+        },
+        {
+          baseURL: this.getApiBaseUrl(),
+          withCredentials: true,
+        },
+      )
+      sessionStorage.setItem('oauthState', response.data.state)
+
+      const params = new URLSearchParams({
+        client_id: response.data.client_id,
+        response_type: 'code',
+        redirect_uri: response.data.redirect_uri,
+        scope: '',
+        state: response.data.state,
+      })
+      return 'https://id.twitch.tv/oauth2/authorize?' + params.toString()
     },
   },
 })
@@ -0,0 +1,65 @@
+// Receives EventSub notifications over a websocket instead of webhooks and
+// runs them through the same callbacks as the webhook view. Meant for
+// development, where there's no public HTTPS callback to give Twitch.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"shrampybot/config"
+	"shrampybot/connector/twitch"
+	"shrampybot/controller/event"
+	"shrampybot/utility/nosqldb"
+	"shrampybot/utility/queue"
+	"time"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Fatalf("Could not instantiate dynamodb: %v\n", err)
+	}
+	userIds, err := n.GetActiveTwitchIds()
+	if err != nil {
+		log.Fatalf("Could not retrieve active users: %v\n", err)
+	}
+
+	subscriber, err := twitch.NewWebsocketSubscriber(config.TwitchUserToken, config.EventsubWebsocketApiUrl)
+	if err != nil {
+		log.Fatalf("Could not connect to Twitch API: %v\n", err)
+	}
+
+	client := twitch.NewWebsocketClient(config.EventsubWebsocketUrl)
+	client.OnWelcome = func(sessionId string) error {
+		for _, userId := range *userIds {
+			for _, subType := range twitch.DesiredSubscriptionTypes {
+				_, err := subscriber.Subscribe(subType.Type, subType.Version, userId, sessionId)
+				if err != nil {
+					log.Printf("Could not subscribe %v to %v: %v\n", userId, subType.Type, err)
+				}
+			}
+		}
+		log.Printf("Subscribed %v users to %v types.\n", len(*userIds), len(twitch.DesiredSubscriptionTypes))
+		return nil
+	}
+	// Notifications go through the same queue and retries as in Lambda,
+	// just kept in memory
+	backend := queue.NewMemoryBackend()
+	worker := event.NewNotificationWorker(backend)
+	go worker.Run(ctx, time.Second)
+	client.OnNotification = func(messageId string, sub *twitch.Subscription, ev *map[string]any) error {
+		return event.EnqueueNotification(backend, messageId, sub, ev)
+	}
+	// Revocations are recorded and followed up the same way as for webhooks
+	client.OnRevocation = event.HandleRevocation
+
+	err = client.Run(ctx)
+	if err != nil {
+		log.Fatalf("EventSub websocket stopped: %v\n", err)
+	}
+}
@@ -6,6 +6,12 @@ var (
 	BotName                  = os.Getenv("BOT_NAME")
 	StreamupDebounceInterval = os.Getenv("STREAMUP_DEBOUNCE_INTERVAL")
 	StreamThumbResolution    = os.Getenv("STREAM_THUMB_RESOLUTION")
+	// Raid post text; {from}, {to}, {viewers}, {category}, {title} and {url}
+	// are filled in
+	RaidAnnouncementFormat = os.Getenv("RAID_ANNOUNCEMENT_FORMAT")
+	// What to do with announcements once a stream ends, per platform, e.g.
+	// discord=edit,mastodon=edit,bluesky=delete. Unlisted platforms are left.
+	EndOfStreamActions = os.Getenv("END_OF_STREAM_ACTIONS")
 
 	AwsAccessKeyId     = os.Getenv("AWS_ACCESS_KEY_ID")
 	AwsSecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
@@ -19,6 +25,23 @@ var (
 	TwitchEventSecret = os.Getenv("TWITCH_EVENT_SECRET")
 	TwitchTeamName    = os.Getenv("TWITCH_TEAM_NAME")
 	EventsubUrl       = os.Getenv("EVENTSUB_URL")
+	// Helix base url; blank means real Twitch
+	TwitchApiUrl = os.Getenv("TWITCH_API_URL")
+	// How subscriptions are delivered: webhook (default) or conduit. Conduit
+	// shards still call EVENTSUB_URL.
+	EventsubTransport = os.Getenv("EVENTSUB_TRANSPORT")
+	// Conduit to route subscriptions through; blank uses the app's first
+	EventsubConduitId = os.Getenv("EVENTSUB_CONDUIT_ID")
+
+	// EventSub websocket mode for development (cmd/eventsubws). Both urls
+	// can point at the Twitch CLI's mock server; blank means real Twitch.
+	EventsubWebsocketUrl    = os.Getenv("EVENTSUB_WS_URL")
+	EventsubWebsocketApiUrl = os.Getenv("EVENTSUB_WS_API_URL")
+	// Websocket subscriptions need a user access token, not an app token
+	TwitchUserToken = os.Getenv("TWITCH_USER_TOKEN")
+
+	// Where notifications wait to be processed: dynamodb (default) or memory
+	EventQueueBackend = os.Getenv("EVENT_QUEUE_BACKEND")
 
 	MastodonApiUrl   = os.Getenv("MASTODON_API_URL")
 	MastodonApiToken = os.Getenv("MASTODON_API_TOKEN")
@@ -34,6 +57,11 @@ var (
 	DiscordChannel      = os.Getenv("DISCORD_CHANNEL")
 	DiscordAdminRole    = os.Getenv("DISCORD_ADMIN_ROLE")
 	DiscordDevRole      = os.Getenv("DISCORD_DEV_ROLE")
+	// How stream announcements look: text (default) or embed. Categories
+	// can override this.
+	DiscordPostMode = os.Getenv("DISCORD_POST_MODE")
+	// Channel for operational alerts to admins, such as revoked subscriptions
+	DiscordAdminChannel = os.Getenv("DISCORD_ADMIN_CHANNEL")
 
 	EventApiHost    = os.Getenv("EVENT_API_HOST")
 	EventApiPath    = os.Getenv("EVENT_API_PATH")
@@ -41,4 +69,13 @@ var (
 	EventApiService = os.Getenv("EVENT_API_SERVICE")
 
 	DBCryptKey = os.Getenv("DB_CRYPT_KEY")
+
+	OAuthStateSecret = os.Getenv("OAUTH_STATE_SECRET")
+	// Comma-separated list of frontend base URLs allowed as OAuth redirect targets
+	OAuthRedirectAllowlist = os.Getenv("OAUTH_REDIRECT_ALLOWLIST")
+
+	// Public URL of the auth controller, which OIDC client apps use as issuer
+	OIDCIssuer = os.Getenv("OIDC_ISSUER")
+	// Frontend page where users approve an OIDC client login
+	OIDCAuthorizeUrl = os.Getenv("OIDC_AUTHORIZE_URL")
 )
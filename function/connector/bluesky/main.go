@@ -2,47 +2,122 @@ package bluesky
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
-	"shrampybot/config"
+	"shrampybot/connector"
 	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
 
 	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/bluesky-social/indigo/lex/util"
 	"github.com/bluesky-social/indigo/xrpc"
-	blueSky "github.com/tailscale/go-bluesky"
 )
 
 const (
 	PlatformName = "bluesky"
+	serverUrl    = "https://bsky.social"
 )
 
+func init() {
+	connector.RegisterPublisher(PlatformName, func() (connector.Publisher, error) {
+		c, err := NewClient()
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+	connector.RegisterOfflinePublisher(PlatformName, func() (connector.Publisher, error) {
+		return NewOfflineClient(), nil
+	})
+}
+
 type Client struct {
-	bc  *blueSky.Client
-	ctx context.Context
+	xc      *xrpc.Client
+	ctx     context.Context
+	session *nosqldb.BlueskySessionDatum
+	// Stands in for handle resolution in tests
+	resolver func(handle string) (string, error)
+	// Stand in for the stored session in tests
+	loadSession  func() (*nosqldb.BlueskySessionDatum, error)
+	storeSession func(session *nosqldb.BlueskySessionDatum) error
+	// Offline clients never log in or call the API
+	offline bool
 }
 
 func NewClient() (*Client, error) {
-	ctx := context.Background()
-
-	bc, err := blueSky.Dial(ctx, blueSky.ServerBskySocial)
-	if err != nil {
-		return &Client{}, err
+	c := &Client{
+		xc: &xrpc.Client{
+			Client: &http.Client{Timeout: 30 * time.Second},
+			Host:   serverUrl,
+		},
+		ctx: context.Background(),
 	}
-	defer bc.Close()
 
-	err = bc.Login(ctx, config.BlueskyLogin, config.BlueskyPassword)
+	err := c.authenticate()
 	if err != nil {
 		return &Client{}, err
 	}
+	return c, nil
+}
 
+// A client for formatting posts only. It has no session, and handles that
+// haven't been resolved before are assumed to resolve.
+func NewOfflineClient() *Client {
 	return &Client{
-		bc:  bc,
-		ctx: ctx,
-	}, nil
+		xc:      &xrpc.Client{Host: serverUrl},
+		ctx:     context.Background(),
+		offline: true,
+	}
+}
+
+// Runs an API call, first replacing the session if it's about to expire
+func (c *Client) call(callback func(api *xrpc.Client) error) error {
+	if c.offline {
+		return connector.ErrOffline
+	}
+	if c.session == nil || !sessionUsable(c.session.AccessJwt, c.session.AccessExpiresAt) {
+		err := c.authenticate()
+		if err != nil {
+			return err
+		}
+	}
+	return callback(c.xc)
+}
+
+func (c *Client) Name() string {
+	return PlatformName
+}
+
+// Streamers with a linked Bluesky account get mentioned, as long as their
+// handle still resolves
+func (c *Client) Mention(user *nosqldb.TwitchUserDatum) string {
+	handle := normalizeHandle(user.BlueskyUsername)
+	if handle != "" && c.didForHandle(handle) != "" {
+		return "@" + handle
+	}
+	return user.DisplayName
+}
+
+func (c *Client) FormatStreamMsg(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) string {
+	streamer := stream.UserName
+	if user.BlueskyUsername != "" {
+		streamer = c.Mention(user)
+	}
+
+	return fmt.Sprintf(
+		"%v is now streaming %v on Twitch: %v\n\n%v\n\n%v",
+		streamer,
+		stream.GameName,
+		fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin),
+		stream.Title,
+		strings.Join(category.BlueskyTags, " "),
+	)
 }
 
 func (c *Client) Post(msg string, thumb *utility.Image) (*utility.PostResponse, error) {
@@ -53,7 +128,7 @@ func (c *Client) Post(msg string, thumb *utility.Image) (*utility.PostResponse,
 
 	log.Println("Uploading image blob to Bluesky.")
 	// Pre-upload the image "blob"
-	err = c.bc.CustomCall(func(api *xrpc.Client) error {
+	err = c.call(func(api *xrpc.Client) error {
 		resp, err := atproto.RepoUploadBlob(c.ctx, api, thumb.GetReader())
 		if err != nil {
 			log.Printf("Issue uploading image blob: %v\n", err)
@@ -86,15 +161,14 @@ func (c *Client) Post(msg string, thumb *utility.Image) (*utility.PostResponse,
 		Text:      msg,
 		CreatedAt: now.Format(time.RFC3339),
 		Embed:     &embed,
-		Facets:    compileFacets(msg),
+		Facets:    c.compileFacets(msg),
 		// Reply: &bsky.FeedPost_ReplyRef{},
 	}
 
 	postResponse := &utility.PostResponse{}
 
 	log.Println("Posting message to Bluesky.")
-	// Gotta use the CustomCall API to post since the library is minimal
-	err = c.bc.CustomCall(func(api *xrpc.Client) error {
+	err = c.call(func(api *xrpc.Client) error {
 		record, err := atproto.RepoCreateRecord(c.ctx, api, &atproto.RepoCreateRecord_Input{
 			Repo:       api.Auth.Did,
 			Collection: "app.bsky.feed.post",
@@ -118,12 +192,64 @@ func (c *Client) Post(msg string, thumb *utility.Image) (*utility.PostResponse,
 	return postResponse, err
 }
 
-func compileFacets(msg string) []*bsky.RichtextFacet {
+// Bluesky posts can't be edited, only deleted. The record key is the last
+// part of the post's at:// uri.
+func (c *Client) Delete(post utility.PostResponse) error {
+	repo, rkey, err := postRecordKey(post.Url)
+	if err != nil {
+		return err
+	}
+
+	return c.call(func(api *xrpc.Client) error {
+		_, err := atproto.RepoDeleteRecord(c.ctx, api, &atproto.RepoDeleteRecord_Input{
+			Repo:       repo,
+			Collection: "app.bsky.feed.post",
+			Rkey:       rkey,
+		})
+		return err
+	})
+}
+
+// Splits at://<did>/app.bsky.feed.post/<rkey> into its repo and record key
+func postRecordKey(uri string) (string, string, error) {
+	parts := strings.Split(strings.TrimPrefix(uri, "at://"), "/")
+	if !strings.HasPrefix(uri, "at://") || len(parts) != 3 || parts[1] != "app.bsky.feed.post" || parts[2] == "" {
+		return "", "", fmt.Errorf("not a bluesky post uri: %v", uri)
+	}
+	return parts[0], parts[2], nil
+}
+
+// The link and hashtag facets a post would be sent with
+func (c *Client) Facets(msg string) []connector.Facet {
+	output := []connector.Facet{}
+	for _, facet := range c.compileFacets(msg) {
+		f := connector.Facet{
+			ByteStart: int(facet.Index.ByteStart),
+			ByteEnd:   int(facet.Index.ByteEnd),
+		}
+		for _, feature := range facet.Features {
+			switch {
+			case feature.RichtextFacet_Link != nil:
+				f.Type = "link"
+				f.Value = feature.RichtextFacet_Link.Uri
+			case feature.RichtextFacet_Tag != nil:
+				f.Type = "tag"
+				f.Value = feature.RichtextFacet_Tag.Tag
+			case feature.RichtextFacet_Mention != nil:
+				f.Type = "mention"
+				f.Value = feature.RichtextFacet_Mention.Did
+			}
+		}
+		output = append(output, f)
+	}
+	return output
+}
+
+func (c *Client) compileFacets(msg string) []*bsky.RichtextFacet {
 	facets := []*bsky.RichtextFacet{}
 
 	urlRegex := regexp.MustCompile(`https?://[A-Za-z0-9._\-/]+`)
 	hashTagRegex := regexp.MustCompile(`#([A-Za-z0-9]([^ \n]*[A-Za-z0-9]{1}))`)
-	// atUserRegex := regexp.MustCompile(`@([A-Za-z0-9\-.]+)`)
 
 	urlIndices := urlRegex.FindAllIndex([]byte(msg), 10)
 	urlMatches := urlRegex.FindAllStringSubmatch(msg, 10)
@@ -161,8 +287,22 @@ func compileFacets(msg string) []*bsky.RichtextFacet {
 		facets = append(facets, &facet)
 	}
 
-	// atUserIndices := atUserRegex.FindAllIndex([]byte(msg), 10)
-	// atUserMatches := atUserRegex.FindAllStringSubmatch(msg, 10)
+	// Handles that don't resolve are left as plain text
+	for _, mention := range c.findMentions(msg) {
+		facet := bsky.RichtextFacet{
+			Index: &bsky.RichtextFacet_ByteSlice{
+				ByteStart: int64(mention.start),
+				ByteEnd:   int64(mention.end),
+			},
+			Features: []*bsky.RichtextFacet_Features_Elem{{
+				RichtextFacet_Mention: &bsky.RichtextFacet_Mention{
+					LexiconTypeID: "app.bsky.richtext.facet#mention",
+					Did:           mention.did,
+				},
+			}},
+		}
+		facets = append(facets, &facet)
+	}
 
 	return facets
 }
@@ -0,0 +1,114 @@
+package bluesky
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+const (
+	didCacheDuration = 6 * time.Hour
+	// Handles that didn't resolve are tried again sooner, in case the
+	// failure was only a blip
+	didFailureCacheDuration = 10 * time.Minute
+)
+
+type cachedDid struct {
+	did     string
+	expires time.Time
+}
+
+var (
+	// Shared by every client, since one is made per post
+	didCacheLock sync.Mutex
+	didCache     = map[string]cachedDid{}
+
+	// An @ at the start of the text or after something that isn't part of
+	// a word, followed by a domain-shaped handle
+	mentionRegex = regexp.MustCompile(`(?:^|[^\w@])(@((?:[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)+[A-Za-z](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?))`)
+)
+
+// Handles are case-insensitive and sometimes stored with their @
+func normalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// The DID behind a handle, or blank if it can't be resolved. Answers are
+// cached, failures included.
+func (c *Client) didForHandle(handle string) string {
+	handle = normalizeHandle(handle)
+	if handle == "" {
+		return ""
+	}
+
+	didCacheLock.Lock()
+	cached, ok := didCache[handle]
+	didCacheLock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.did
+	}
+	if c.offline {
+		// Not cached, since it isn't a real answer
+		return offlineDid(handle)
+	}
+
+	did, err := c.resolveHandle(handle)
+	expires := time.Now().Add(didCacheDuration)
+	if err != nil {
+		log.Printf("Could not resolve Bluesky handle %v: %v\n", handle, err)
+		did = ""
+		expires = time.Now().Add(didFailureCacheDuration)
+	}
+
+	didCacheLock.Lock()
+	didCache[handle] = cachedDid{did: did, expires: expires}
+	didCacheLock.Unlock()
+	return did
+}
+
+// Stands in for the DID of a handle an offline client hasn't looked up
+func offlineDid(handle string) string {
+	return "did:unresolved:" + handle
+}
+
+func (c *Client) resolveHandle(handle string) (string, error) {
+	if c.resolver != nil {
+		return c.resolver(handle)
+	}
+
+	did := ""
+	err := c.call(func(api *xrpc.Client) error {
+		resp, err := atproto.IdentityResolveHandle(c.ctx, api, handle)
+		if err != nil {
+			return err
+		}
+		did = resp.Did
+		return nil
+	})
+	return did, err
+}
+
+// Spans of msg mentioning a handle that resolves, with the handle's DID
+type mentionMatch struct {
+	start int
+	end   int
+	did   string
+}
+
+func (c *Client) findMentions(msg string) []mentionMatch {
+	mentions := []mentionMatch{}
+	for _, indices := range mentionRegex.FindAllStringSubmatchIndex(msg, 10) {
+		start, end := indices[2], indices[3]
+		did := c.didForHandle(msg[indices[4]:indices[5]])
+		if did == "" {
+			continue
+		}
+		mentions = append(mentions, mentionMatch{start: start, end: end, did: did})
+	}
+	return mentions
+}
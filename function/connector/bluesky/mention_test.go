@@ -0,0 +1,73 @@
+package bluesky
+
+import (
+	"errors"
+	"shrampybot/connector"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"testing"
+
+	"github.com/litui/helix/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(lookups *int) *Client {
+	didCache = map[string]cachedDid{}
+	return &Client{resolver: func(handle string) (string, error) {
+		*lookups++
+		if handle == "shrimp.bsky.social" {
+			return "did:plc:shrimp", nil
+		}
+		return "", errors.New("unable to resolve handle")
+	}}
+}
+
+func TestMention(t *testing.T) {
+	lookups := 0
+	c := newTestClient(&lookups)
+
+	linked := &nosqldb.TwitchUserDatum{User: helix.User{DisplayName: "Shrimp"}, BlueskyUsername: "@Shrimp.bsky.social"}
+	unresolvable := &nosqldb.TwitchUserDatum{User: helix.User{DisplayName: "Prawn"}, BlueskyUsername: "prawn.example"}
+	unlinked := &nosqldb.TwitchUserDatum{User: helix.User{DisplayName: "Krill"}}
+
+	assert.Equal(t, "@shrimp.bsky.social", c.Mention(linked))
+	assert.Equal(t, "Prawn", c.Mention(unresolvable))
+	assert.Equal(t, "Krill", c.Mention(unlinked))
+
+	// Both answers come from the cache the second time round
+	c.Mention(linked)
+	c.Mention(unresolvable)
+	assert.Equal(t, 2, lookups)
+}
+
+func TestCompileFacetsMentions(t *testing.T) {
+	lookups := 0
+	c := newTestClient(&lookups)
+
+	msg := "@shrimp.bsky.social raided @prawn.example! Email me@shrimp.bsky.social"
+	facets := c.Facets(msg)
+
+	mentions := []string{}
+	for _, facet := range facets {
+		if facet.Type == "mention" {
+			mentions = append(mentions, msg[facet.ByteStart:facet.ByteEnd]+" "+facet.Value)
+		}
+	}
+	assert.Equal(t, []string{"@shrimp.bsky.social did:plc:shrimp"}, mentions)
+}
+
+func TestOfflineClient(t *testing.T) {
+	c := NewOfflineClient()
+
+	linked := &nosqldb.TwitchUserDatum{User: helix.User{DisplayName: "Lobster"}, BlueskyUsername: "lobster.bsky.social"}
+	assert.Equal(t, "@lobster.bsky.social", c.Mention(linked))
+
+	// The stand-in answer mustn't be handed to clients that can look it up
+	didCacheLock.Lock()
+	_, cached := didCache["lobster.bsky.social"]
+	didCacheLock.Unlock()
+	assert.False(t, cached)
+
+	err := c.Delete(utility.PostResponse{Platform: PlatformName, Url: "at://did:plc:shrimp/app.bsky.feed.post/abc"})
+	assert.ErrorIs(t, err, connector.ErrOffline)
+}
@@ -0,0 +1,200 @@
+package bluesky
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"shrampybot/utility/nosqldb"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Tokens this close to expiring are replaced before they're used
+	sessionExpiryMargin = 5 * time.Minute
+)
+
+var (
+	sessionLock sync.Mutex
+	// The session as this process last saw it, so that warm invocations
+	// don't need to read it back from the database
+	currentSession *nosqldb.BlueskySessionDatum
+)
+
+func sessionUsable(token string, expires time.Time) bool {
+	return token != "" && time.Until(expires) > sessionExpiryMargin
+}
+
+// Gives the client a working session. The session in memory or DynamoDB is
+// used while it lasts, then refreshed, and only if that fails is a new one
+// created with the app password.
+func (c *Client) authenticate() error {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+
+	session := currentSession
+	if session == nil || session.Id != config.BlueskyLogin || !sessionUsable(session.AccessJwt, session.AccessExpiresAt) {
+		stored, err := c.loadStoredSession()
+		if err != nil {
+			log.Printf("Could not load stored Bluesky session: %v\n", err)
+		} else {
+			session = stored
+		}
+	}
+
+	if session != nil && session.Id == config.BlueskyLogin {
+		if sessionUsable(session.AccessJwt, session.AccessExpiresAt) {
+			c.useSession(session)
+			currentSession = session
+			return nil
+		}
+		if sessionUsable(session.RefreshJwt, session.RefreshExpiresAt) {
+			refreshed, err := c.refreshSession(session)
+			if err == nil {
+				c.saveSession(refreshed)
+				return nil
+			}
+			log.Printf("Could not refresh Bluesky session, logging in again: %v\n", err)
+		}
+	}
+
+	log.Println("Logging in to Bluesky.")
+	session, err := c.createSession()
+	if err != nil {
+		return err
+	}
+	c.saveSession(session)
+	return nil
+}
+
+func (c *Client) loadStoredSession() (*nosqldb.BlueskySessionDatum, error) {
+	if c.loadSession != nil {
+		return c.loadSession()
+	}
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return n.GetBlueskySession(config.BlueskyLogin)
+}
+
+// Puts a new session to use and keeps it for later invocations. Failing to
+// store it only costs a login next time, so that isn't an error.
+func (c *Client) saveSession(session *nosqldb.BlueskySessionDatum) {
+	c.useSession(session)
+	currentSession = session
+
+	var err error
+	if c.storeSession != nil {
+		err = c.storeSession(session)
+	} else {
+		var n *nosqldb.NoSqlDb
+		n, err = nosqldb.NewClient()
+		if err == nil {
+			err = n.PutBlueskySession(session)
+		}
+	}
+	if err != nil {
+		log.Printf("Could not store Bluesky session: %v\n", err)
+	}
+}
+
+func (c *Client) useSession(session *nosqldb.BlueskySessionDatum) {
+	c.session = session
+	c.xc.Auth = &xrpc.AuthInfo{
+		AccessJwt:  session.AccessJwt,
+		RefreshJwt: session.RefreshJwt,
+		Handle:     session.Handle,
+		Did:        session.Did,
+	}
+}
+
+func (c *Client) createSession() (*nosqldb.BlueskySessionDatum, error) {
+	sess, err := atproto.ServerCreateSession(c.ctx, c.xc, &atproto.ServerCreateSession_Input{
+		Identifier: config.BlueskyLogin,
+		Password:   config.BlueskyPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions made with the account's real password can do anything,
+	// so they're kept but flagged
+	claims, err := tokenClaims(sess.AccessJwt)
+	if err != nil {
+		return nil, err
+	}
+	if scope, _ := claims["scope"].(string); !strings.HasPrefix(scope, "com.atproto.appPass") {
+		log.Printf("Bluesky login %v isn't using an app password; one should be set up instead.\n", config.BlueskyLogin)
+	}
+
+	return newSession(sess.AccessJwt, sess.RefreshJwt, sess.Did, sess.Handle)
+}
+
+func (c *Client) refreshSession(session *nosqldb.BlueskySessionDatum) (*nosqldb.BlueskySessionDatum, error) {
+	// Refreshing is authorised with the refresh token in place of the
+	// access token
+	refClient := *c.xc
+	refClient.Auth = &xrpc.AuthInfo{
+		AccessJwt: session.RefreshJwt,
+		Handle:    session.Handle,
+		Did:       session.Did,
+	}
+
+	sess, err := atproto.ServerRefreshSession(c.ctx, &refClient)
+	if err != nil {
+		return nil, err
+	}
+	return newSession(sess.AccessJwt, sess.RefreshJwt, sess.Did, sess.Handle)
+}
+
+func newSession(accessJwt string, refreshJwt string, did string, handle string) (*nosqldb.BlueskySessionDatum, error) {
+	accessExpires, err := tokenExpiry(accessJwt)
+	if err != nil {
+		return nil, fmt.Errorf("access token: %w", err)
+	}
+	refreshExpires, err := tokenExpiry(refreshJwt)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token: %w", err)
+	}
+
+	return &nosqldb.BlueskySessionDatum{
+		Id:               config.BlueskyLogin,
+		Did:              did,
+		Handle:           handle,
+		AccessJwt:        accessJwt,
+		RefreshJwt:       refreshJwt,
+		AccessExpiresAt:  accessExpires,
+		RefreshExpiresAt: refreshExpires,
+	}, nil
+}
+
+// The tokens are only read here, never verified; Bluesky does that
+func tokenClaims(token string) (jwt.MapClaims, error) {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Claims.(jwt.MapClaims), nil
+}
+
+func tokenExpiry(token string) (time.Time, error) {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	expires, err := claims.GetExpirationTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if expires == nil {
+		return time.Time{}, errors.New("token has no expiry")
+	}
+	return expires.Time, nil
+}
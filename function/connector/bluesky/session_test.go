@@ -0,0 +1,133 @@
+package bluesky
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shrampybot/config"
+	"shrampybot/utility/nosqldb"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func testToken(claims jwt.MapClaims) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("not-checked"))
+	return token
+}
+
+func TestNewSession(t *testing.T) {
+	accessExpires := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	refreshExpires := time.Now().Add(60 * 24 * time.Hour).Truncate(time.Second)
+
+	session, err := newSession(
+		testToken(jwt.MapClaims{"scope": "com.atproto.appPass", "exp": accessExpires.Unix()}),
+		testToken(jwt.MapClaims{"scope": "com.atproto.refresh", "exp": refreshExpires.Unix()}),
+		"did:plc:shrimp",
+		"shrimp.bsky.social",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "did:plc:shrimp", session.Did)
+	assert.True(t, accessExpires.Equal(session.AccessExpiresAt))
+	assert.True(t, refreshExpires.Equal(session.RefreshExpiresAt))
+
+	_, err = newSession(testToken(jwt.MapClaims{"scope": "com.atproto.appPass"}), "", "", "")
+	assert.EqualError(t, err, "access token: token has no expiry")
+}
+
+func TestSessionUsable(t *testing.T) {
+	assert.True(t, sessionUsable("token", time.Now().Add(time.Hour)))
+	assert.False(t, sessionUsable("token", time.Now().Add(time.Minute)))
+	assert.False(t, sessionUsable("", time.Now().Add(time.Hour)))
+}
+
+func TestAuthenticate(t *testing.T) {
+	live := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	session := func(name string, accessExpires time.Time, refreshExpires time.Time) *nosqldb.BlueskySessionDatum {
+		return &nosqldb.BlueskySessionDatum{
+			Id:               config.BlueskyLogin,
+			AccessJwt:        testToken(jwt.MapClaims{"sub": name, "exp": accessExpires.Unix()}),
+			RefreshJwt:       testToken(jwt.MapClaims{"sub": name + "-refresh", "exp": refreshExpires.Unix()}),
+			AccessExpiresAt:  accessExpires,
+			RefreshExpiresAt: refreshExpires,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		stored       *nosqldb.BlueskySessionDatum
+		refreshFails bool
+		loginScope   string
+		calls        []string
+		expected     string
+		saved        bool
+	}{
+		{"stored session", session("stored", live, live), false, "", []string{}, "stored", false},
+		{"refreshed", session("stored", expired, live), false, "", []string{"refreshSession"}, "refreshed", true},
+		{"refresh fails", session("stored", expired, live), true, "com.atproto.appPass", []string{"refreshSession", "createSession"}, "created", true},
+		{"refresh expired", session("stored", expired, expired), false, "com.atproto.appPass", []string{"createSession"}, "created", true},
+		{"nothing stored", nil, false, "com.atproto.appPassPrivileged", []string{"createSession"}, "created", true},
+		{"main password", nil, false, "com.atproto.access", []string{"createSession"}, "created", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method := r.URL.Path[len("/xrpc/com.atproto.server."):]
+				calls = append(calls, method)
+
+				name := "created"
+				scope := tt.loginScope
+				if method == "refreshSession" {
+					if tt.refreshFails {
+						w.WriteHeader(http.StatusBadRequest)
+						json.NewEncoder(w).Encode(map[string]string{"error": "ExpiredToken"})
+						return
+					}
+					// Refreshing is authorised with the refresh token
+					assert.Equal(t, "Bearer "+tt.stored.RefreshJwt, r.Header.Get("Authorization"))
+					name = "refreshed"
+					scope = "com.atproto.appPass"
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]string{
+					"accessJwt":  testToken(jwt.MapClaims{"sub": name, "scope": scope, "exp": live.Unix()}),
+					"refreshJwt": testToken(jwt.MapClaims{"sub": name + "-refresh", "exp": live.Unix()}),
+					"did":        "did:plc:shrimp",
+					"handle":     "shrimp.bsky.social",
+				})
+			}))
+			defer server.Close()
+
+			currentSession = nil
+			var saved *nosqldb.BlueskySessionDatum
+			c := &Client{
+				xc:  &xrpc.Client{Client: server.Client(), Host: server.URL},
+				ctx: context.Background(),
+				loadSession: func() (*nosqldb.BlueskySessionDatum, error) {
+					return tt.stored, nil
+				},
+				storeSession: func(session *nosqldb.BlueskySessionDatum) error {
+					saved = session
+					return nil
+				},
+			}
+
+			assert.NoError(t, c.authenticate())
+			assert.Equal(t, tt.calls, calls)
+			claims, _ := tokenClaims(c.xc.Auth.AccessJwt)
+			assert.Equal(t, tt.expected, claims["sub"])
+			assert.Equal(t, c.session, currentSession)
+			if tt.saved {
+				assert.Equal(t, c.session, saved)
+			} else {
+				assert.Nil(t, saved)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"fmt"
+	"shrampybot/config"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	PostModeText  = "text"
+	PostModeEmbed = "embed"
+
+	// Twitch purple
+	embedColor = 0x9146ff
+)
+
+// Whether announcements in a category go out as embeds. The category's own
+// mode wins over the global one; anything but embed means plain text.
+func embedMode(category *nosqldb.CategoryDatum) bool {
+	mode := config.DiscordPostMode
+	if category != nil && category.DiscordPostMode != "" {
+		mode = category.DiscordPostMode
+	}
+	return mode == PostModeEmbed
+}
+
+// Announces a stream as an embed with a Watch button when the category calls
+// for it, or as plain text otherwise
+func (c *BotClient) PostStream(msg string, image *utility.Image, user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) (*utility.PostResponse, error) {
+	if !embedMode(category) {
+		return c.Post(msg, image)
+	}
+
+	return c.send(&discordgo.MessageSend{
+		Content:    msg,
+		Files:      imageFiles(image),
+		Embeds:     []*discordgo.MessageEmbed{streamEmbed(user, stream)},
+		Components: watchButton(stream),
+	})
+}
+
+// The embed and button an announcement would carry, as sent to Discord
+type StreamEmbedPreview struct {
+	Embed      *discordgo.MessageEmbed      `json:"embed"`
+	Components []discordgo.MessageComponent `json:"components"`
+}
+
+func (c *BotClient) PreviewStream(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) (string, any) {
+	if !embedMode(category) {
+		return PostModeText, nil
+	}
+	return PostModeEmbed, &StreamEmbedPreview{
+		Embed:      streamEmbed(user, stream),
+		Components: watchButton(stream),
+	}
+}
+
+func streamUrl(stream *nosqldb.StreamHistoryDatum) string {
+	return fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin)
+}
+
+func streamEmbed(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum) *discordgo.MessageEmbed {
+	url := streamUrl(stream)
+
+	return &discordgo.MessageEmbed{
+		Type:  discordgo.EmbedTypeRich,
+		Title: stream.Title,
+		URL:   url,
+		Color: embedColor,
+		Author: &discordgo.MessageEmbedAuthor{
+			Name:    stream.UserName,
+			URL:     url,
+			IconURL: user.ProfileImageURL,
+		},
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Category",
+				Value:  stream.GameName,
+				Inline: true,
+			},
+		},
+		// The thumbnail is sent as an attachment alongside the embed
+		Image: &discordgo.MessageEmbedImage{
+			URL: "attachment://" + imageFileName,
+		},
+	}
+}
+
+func watchButton(stream *nosqldb.StreamHistoryDatum) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label: "Watch",
+					Style: discordgo.LinkButton,
+					URL:   streamUrl(stream),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package discord
+
+import (
+	"shrampybot/config"
+	"shrampybot/utility/nosqldb"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbedMode(t *testing.T) {
+	original := config.DiscordPostMode
+	defer func() { config.DiscordPostMode = original }()
+
+	testCases := []struct {
+		name     string
+		global   string
+		category *nosqldb.CategoryDatum
+		expected bool
+	}{
+		{name: "Nothing set", global: "", category: &nosqldb.CategoryDatum{}, expected: false},
+		{name: "Global embed", global: PostModeEmbed, category: &nosqldb.CategoryDatum{}, expected: true},
+		{name: "No category", global: PostModeEmbed, category: nil, expected: true},
+		{name: "Category embed", global: PostModeText, category: &nosqldb.CategoryDatum{DiscordPostMode: PostModeEmbed}, expected: true},
+		{name: "Category text", global: PostModeEmbed, category: &nosqldb.CategoryDatum{DiscordPostMode: PostModeText}, expected: false},
+		{name: "Unknown mode", global: "fancy", category: &nosqldb.CategoryDatum{}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.DiscordPostMode = tc.global
+			assert.Equal(t, tc.expected, embedMode(tc.category))
+		})
+	}
+}
+
+func TestPreviewStream(t *testing.T) {
+	original := config.DiscordPostMode
+	defer func() { config.DiscordPostMode = original }()
+	config.DiscordPostMode = PostModeText
+
+	c := &BotClient{offline: true}
+	user := &nosqldb.TwitchUserDatum{}
+	stream := &nosqldb.StreamHistoryDatum{}
+	stream.UserLogin = "shrimp"
+	stream.GameName = "Music"
+
+	mode, extra := c.PreviewStream(user, stream, &nosqldb.CategoryDatum{})
+	assert.Equal(t, PostModeText, mode)
+	assert.Nil(t, extra)
+
+	mode, extra = c.PreviewStream(user, stream, &nosqldb.CategoryDatum{DiscordPostMode: PostModeEmbed})
+	assert.Equal(t, PostModeEmbed, mode)
+	embed := extra.(*StreamEmbedPreview)
+	assert.Equal(t, "https://twitch.tv/shrimp", embed.Embed.URL)
+	assert.Equal(t, "Music", embed.Embed.Fields[0].Value)
+	assert.Len(t, embed.Components, 1)
+}
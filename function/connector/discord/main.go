@@ -5,19 +5,37 @@ import (
 	"fmt"
 	"log"
 	"shrampybot/config"
+	"shrampybot/connector"
 	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
 	"slices"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 const (
-	PlatformName = "discord"
+	PlatformName  = "discord"
+	imageFileName = "image.jpg"
 )
 
+func init() {
+	connector.RegisterPublisher(PlatformName, func() (connector.Publisher, error) {
+		c, err := NewBotClient()
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+	connector.RegisterOfflinePublisher(PlatformName, func() (connector.Publisher, error) {
+		return &BotClient{offline: true}, nil
+	})
+}
+
 type BotClient struct {
 	dc    *discordgo.Session
 	ready bool
+	// Offline clients have no session and only format posts
+	offline bool
 }
 
 func NewBotClient() (*BotClient, error) {
@@ -54,23 +72,52 @@ func (c *BotClient) FormatMsg(userName string, category string, title string, ur
 	)
 }
 
+func (c *BotClient) Name() string {
+	return PlatformName
+}
+
+func (c *BotClient) Mention(user *nosqldb.TwitchUserDatum) string {
+	return fmt.Sprintf("**%v**", user.DisplayName)
+}
+
+func (c *BotClient) FormatStreamMsg(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) string {
+	return c.FormatMsg(
+		stream.UserName,
+		stream.GameName,
+		stream.Title,
+		fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin),
+	)
+}
+
 func (c *BotClient) Post(msg string, image *utility.Image) (*utility.PostResponse, error) {
+	return c.send(&discordgo.MessageSend{
+		Content: msg,
+		Files:   imageFiles(image),
+		Flags:   discordgo.MessageFlagsSuppressEmbeds,
+	})
+}
+
+func imageFiles(image *utility.Image) []*discordgo.File {
 	var files []*discordgo.File
 
 	files = append(files, &discordgo.File{
-		Name:        "image.jpg",
+		Name:        imageFileName,
 		ContentType: "image/jpeg",
 		Reader:      image.GetReader(),
 	})
+	return files
+}
 
+// Sends a message to the announcement channel and crossposts it to any
+// servers following the channel
+func (c *BotClient) send(message *discordgo.MessageSend) (*utility.PostResponse, error) {
 	postResponse := &utility.PostResponse{}
+	if c.offline {
+		return postResponse, connector.ErrOffline
+	}
 
 	log.Printf("Sending Discord message...")
-	res, err := c.dc.ChannelMessageSendComplex(config.DiscordChannel, &discordgo.MessageSend{
-		Content: msg,
-		Files:   files,
-		Flags:   discordgo.MessageFlagsSuppressEmbeds,
-	})
+	res, err := c.dc.ChannelMessageSendComplex(config.DiscordChannel, message)
 	if err != nil {
 		return postResponse, err
 	}
@@ -94,6 +141,33 @@ func (c *BotClient) Post(msg string, image *utility.Image) (*utility.PostRespons
 	return postResponse, nil
 }
 
+// Replaces the text of an announcement, keeping its image
+func (c *BotClient) Edit(post utility.PostResponse, msg string) error {
+	if c.offline {
+		return connector.ErrOffline
+	}
+	_, err := c.dc.ChannelMessageEdit(config.DiscordChannel, post.Id, msg)
+	return err
+}
+
+func (c *BotClient) Delete(post utility.PostResponse) error {
+	if c.offline {
+		return connector.ErrOffline
+	}
+	return c.dc.ChannelMessageDelete(config.DiscordChannel, post.Id)
+}
+
+// Sends a plain message to the admin channel, if one is configured
+func (c *BotClient) AlertAdmins(msg string) error {
+	if config.DiscordAdminChannel == "" {
+		log.Printf("No admin channel configured; alert not sent: %v\n", msg)
+		return nil
+	}
+
+	_, err := c.dc.ChannelMessageSend(config.DiscordAdminChannel, msg)
+	return err
+}
+
 func (c *BotClient) UserIsAdmin(id string) bool {
 	membership, err := c.GetGuildMember(id)
 	if err != nil {
@@ -2,9 +2,13 @@ package mastodon
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"shrampybot/config"
+	"shrampybot/connector"
 	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strings"
 
 	mast "github.com/litui/go-mastodon"
 )
@@ -13,9 +17,24 @@ const (
 	PlatformName = "mastodon"
 )
 
+func init() {
+	connector.RegisterPublisher(PlatformName, func() (connector.Publisher, error) {
+		c, err := NewClient()
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+	connector.RegisterOfflinePublisher(PlatformName, func() (connector.Publisher, error) {
+		return &Client{ctx: context.Background(), offline: true}, nil
+	})
+}
+
 type Client struct {
 	mh  *mast.Client
 	ctx context.Context
+	// Offline clients have no API client and only format posts
+	offline bool
 }
 
 func NewClient() (*Client, error) {
@@ -30,7 +49,38 @@ func NewClient() (*Client, error) {
 	return &c, nil
 }
 
+func (c *Client) Name() string {
+	return PlatformName
+}
+
+// Streamers who have told us their Mastodon account get tagged
+func (c *Client) Mention(user *nosqldb.TwitchUserDatum) string {
+	if user.MastodonUserId != "" {
+		return fmt.Sprintf("@%v", user.MastodonUserId)
+	}
+	return user.DisplayName
+}
+
+func (c *Client) FormatStreamMsg(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) string {
+	streamer := stream.UserName
+	if user.MastodonUserId != "" {
+		streamer = fmt.Sprintf("@%v", user.MastodonUserId)
+	}
+
+	return fmt.Sprintf(
+		"%v is now streaming %v on Twitch: %v\n\n%v\n\n%v",
+		streamer,
+		stream.GameName,
+		fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin),
+		stream.Title,
+		strings.Join(category.MastodonTags, " "),
+	)
+}
+
 func (c *Client) Post(msg string, thumb *utility.Image) (*utility.PostResponse, error) {
+	if c.offline {
+		return &utility.PostResponse{}, connector.ErrOffline
+	}
 	var mediaIds []mast.ID
 
 	log.Println("Uploading image attachment to Mastodon...")
@@ -61,6 +111,37 @@ func (c *Client) Post(msg string, thumb *utility.Image) (*utility.PostResponse,
 	return postResponse, nil
 }
 
+// Replaces the text of a status. Mastodon drops attachments that aren't
+// resent with an edit, so the existing ones are looked up first.
+func (c *Client) Edit(post utility.PostResponse, msg string) error {
+	if c.offline {
+		return connector.ErrOffline
+	}
+	status, err := c.mh.GetStatus(c.ctx, mast.ID(post.Id))
+	if err != nil {
+		return err
+	}
+
+	var mediaIds []mast.ID
+	for _, attachment := range status.MediaAttachments {
+		mediaIds = append(mediaIds, attachment.ID)
+	}
+
+	_, err = c.mh.UpdateStatus(c.ctx, &mast.Toot{
+		Status:    msg,
+		MediaIDs:  mediaIds,
+		Sensitive: status.Sensitive,
+	}, status.ID)
+	return err
+}
+
+func (c *Client) Delete(post utility.PostResponse) error {
+	if c.offline {
+		return connector.ErrOffline
+	}
+	return c.mh.DeleteStatus(c.ctx, mast.ID(post.Id))
+}
+
 // Function no longer usable when moving to public instance
 
 // func (c *Client) GetMappedTwitchLoginsThreaded(ch chan map[string]string) {
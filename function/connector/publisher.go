@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"sort"
+	"sync"
+)
+
+// A platform announcements can be posted to
+type Publisher interface {
+	// Same as the Platform on the responses it returns
+	Name() string
+	// How to refer to a streamer in a post, e.g. as a mention or in bold
+	Mention(user *nosqldb.TwitchUserDatum) string
+	// Text announcing that a stream has gone live
+	FormatStreamMsg(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) string
+	Post(msg string, image *utility.Image) (*utility.PostResponse, error)
+}
+
+// Publishers that can rewrite a post they made
+type Editor interface {
+	Edit(post utility.PostResponse, msg string) error
+}
+
+// Publishers that can take down a post they made
+type Deleter interface {
+	Delete(post utility.PostResponse) error
+}
+
+// Publishers that can present a stream announcement as more than its text,
+// given the stream it's about
+type StreamPublisher interface {
+	PostStream(msg string, image *utility.Image, user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) (*utility.PostResponse, error)
+}
+
+// Publishers that can say how a stream announcement would go out beyond its
+// text, for previews
+type StreamPreviewer interface {
+	// The post mode, e.g. text or embed, and whatever would be sent along
+	// with the text in that mode
+	PreviewStream(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) (string, any)
+}
+
+// A span of a post's text that a platform treats specially
+type Facet struct {
+	// link, tag or mention
+	Type      string `json:"type"`
+	ByteStart int    `json:"byte_start"`
+	ByteEnd   int    `json:"byte_end"`
+	Value     string `json:"value"`
+}
+
+// Publishers that send rich text data along with a post's text
+type FacetPublisher interface {
+	Facets(msg string) []Facet
+}
+
+type PublisherFactory func() (Publisher, error)
+
+var (
+	// Returned by offline publishers for anything that would reach the
+	// platform
+	ErrOffline = errors.New("publisher is offline")
+
+	publishersLock    sync.RWMutex
+	publishers        = map[string]PublisherFactory{}
+	offlinePublishers = map[string]PublisherFactory{}
+)
+
+// Makes a platform available for announcements. Connectors call this from
+// init, so importing a connector is enough to start posting to it.
+func RegisterPublisher(name string, factory PublisherFactory) {
+	publishersLock.Lock()
+	defer publishersLock.Unlock()
+	publishers[name] = factory
+}
+
+// Registers a version of a platform's publisher that formats posts without
+// logging in or otherwise reaching the platform, for dry runs and previews
+func RegisterOfflinePublisher(name string, factory PublisherFactory) {
+	publishersLock.Lock()
+	defer publishersLock.Unlock()
+	offlinePublishers[name] = factory
+}
+
+// Names of every registered platform, in a stable order
+func PublisherNames() []string {
+	publishersLock.RLock()
+	defer publishersLock.RUnlock()
+
+	names := []string{}
+	for name := range publishers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewPublisher(name string) (Publisher, error) {
+	publishersLock.RLock()
+	factory, ok := publishers[name]
+	publishersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no publisher registered for %v", name)
+	}
+	return factory()
+}
+
+// An offline publisher for the named platform. Posting, editing or deleting
+// with it fails with ErrOffline.
+func NewOfflinePublisher(name string) (Publisher, error) {
+	publishersLock.RLock()
+	factory, ok := offlinePublishers[name]
+	publishersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no offline publisher registered for %v", name)
+	}
+	return factory()
+}
+
+var hashtagRegex = regexp.MustCompile(`#([A-Za-z0-9]([^ \n]*[A-Za-z0-9]{1}))`)
+
+// Hashtags in a post's text, without the #
+func Hashtags(msg string) []string {
+	tags := []string{}
+	for _, match := range hashtagRegex.FindAllStringSubmatch(msg, -1) {
+		tags = append(tags, match[1])
+	}
+	return tags
+}
@@ -0,0 +1,84 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"shrampybot/utility/nosqldb"
+	"strings"
+	"text/template"
+)
+
+// What announcement templates are rendered against
+type AnnouncementData struct {
+	Stream   *nosqldb.StreamHistoryDatum
+	User     *nosqldb.TwitchUserDatum
+	Category *nosqldb.CategoryDatum
+	// Blank fields if no event is running
+	Event *nosqldb.CurrentEventDatum
+	// The streamer named the platform's way, e.g. as a mention
+	Mention string
+	Url     string
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func NewAnnouncementData(pub Publisher, user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum, event *nosqldb.CurrentEventDatum) *AnnouncementData {
+	if event == nil {
+		event = &nosqldb.CurrentEventDatum{}
+	}
+	return &AnnouncementData{
+		Stream:   stream,
+		User:     user,
+		Category: category,
+		Event:    event,
+		Mention:  pub.Mention(user),
+		Url:      fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin),
+	}
+}
+
+func RenderAnnouncement(text string, data *AnnouncementData) (string, error) {
+	tmpl, err := template.New("announcement").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	out := strings.Builder{}
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// Checks that a template parses and only refers to fields that exist, by
+// rendering it against empty data
+func ValidateAnnouncementTemplate(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return errors.New("template is empty")
+	}
+	_, err := RenderAnnouncement(text, &AnnouncementData{
+		Stream:   &nosqldb.StreamHistoryDatum{},
+		User:     &nosqldb.TwitchUserDatum{},
+		Category: &nosqldb.CategoryDatum{},
+		Event:    &nosqldb.CurrentEventDatum{},
+	})
+	return err
+}
+
+// Renders a stream announcement with a stored template, or in the
+// platform's built-in format if there's no template. A template that fails
+// to render also falls back, with the error returned alongside.
+func FormatStreamAnnouncement(pub Publisher, tmpl *nosqldb.AnnouncementTemplateDatum, data *AnnouncementData) (string, error) {
+	if tmpl == nil || tmpl.Template == "" {
+		return pub.FormatStreamMsg(data.User, data.Stream, data.Category), nil
+	}
+	msg, err := RenderAnnouncement(tmpl.Template, data)
+	if err != nil || msg == "" {
+		return pub.FormatStreamMsg(data.User, data.Stream, data.Category), err
+	}
+	return msg, nil
+}
@@ -0,0 +1,75 @@
+package connector
+
+import (
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"testing"
+
+	"github.com/litui/helix/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPublisher struct{}
+
+func (p *testPublisher) Name() string { return "test" }
+
+func (p *testPublisher) Mention(user *nosqldb.TwitchUserDatum) string {
+	return "@" + user.Login
+}
+
+func (p *testPublisher) FormatStreamMsg(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) string {
+	return "default " + stream.UserName
+}
+
+func (p *testPublisher) Post(msg string, image *utility.Image) (*utility.PostResponse, error) {
+	return &utility.PostResponse{Platform: "test", Id: "1"}, nil
+}
+
+func TestFormatStreamAnnouncement(t *testing.T) {
+	pub := &testPublisher{}
+	stream := &nosqldb.StreamHistoryDatum{Stream: helix.Stream{UserLogin: "shrimp", UserName: "Shrimp", GameName: "Art", Title: "Drawing"}}
+	user := &nosqldb.TwitchUserDatum{}
+	user.Login = "shrimp"
+	category := &nosqldb.CategoryDatum{Id: "c1", MastodonTags: []string{"#art", "#live"}}
+	event := &nosqldb.CurrentEventDatum{Title: "Jam"}
+	data := NewAnnouncementData(pub, user, stream, category, event)
+
+	tests := []struct {
+		name     string
+		template *nosqldb.AnnouncementTemplateDatum
+		msg      string
+		err      bool
+	}{
+		{
+			name:     "no template",
+			template: nil,
+			msg:      "default Shrimp",
+		},
+		{
+			name:     "template",
+			template: &nosqldb.AnnouncementTemplateDatum{Template: `{{.Mention}} is streaming {{.Stream.GameName}} for {{.Event.Title}}: {{.Url}} {{join .Category.MastodonTags " "}}`},
+			msg:      "@shrimp is streaming Art for Jam: https://twitch.tv/shrimp #art #live",
+		},
+		{
+			name:     "unknown field falls back",
+			template: &nosqldb.AnnouncementTemplateDatum{Template: `{{.Stream.Nope}}`},
+			msg:      "default Shrimp",
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := FormatStreamAnnouncement(pub, test.template, data)
+			assert.Equal(t, test.msg, msg)
+			assert.Equal(t, test.err, err != nil)
+		})
+	}
+}
+
+func TestValidateAnnouncementTemplate(t *testing.T) {
+	assert.NoError(t, ValidateAnnouncementTemplate(`{{.User.DisplayName}} is live {{if .Event.Title}}for {{.Event.Title}}{{end}}`))
+	assert.Error(t, ValidateAnnouncementTemplate(`{{.User.Nope}}`))
+	assert.Error(t, ValidateAnnouncementTemplate(`{{.Stream.Title`))
+	assert.Error(t, ValidateAnnouncementTemplate("  "))
+}
@@ -0,0 +1,295 @@
+package twitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"shrampybot/config"
+	"time"
+
+	"github.com/litui/helix/v3"
+)
+
+const (
+	TransportWebhook = "webhook"
+	TransportConduit = "conduit"
+)
+
+// A conduit lets one set of shards receive every subscription routed
+// through it, instead of each subscription carrying its own callback.
+type Conduit struct {
+	Id         string `json:"id"`
+	ShardCount int    `json:"shard_count"`
+}
+
+type ShardTransport struct {
+	Method    string `json:"method"`
+	Callback  string `json:"callback,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	SessionId string `json:"session_id,omitempty"`
+	// RFC3339; only set for websocket shards
+	ConnectedAt    string `json:"connected_at,omitempty"`
+	DisconnectedAt string `json:"disconnected_at,omitempty"`
+}
+
+type ConduitShard struct {
+	Id        string          `json:"id"`
+	Status    string          `json:"status,omitempty"`
+	Transport *ShardTransport `json:"transport"`
+}
+
+// A shard Twitch refused to update
+type ShardError struct {
+	Id      string `json:"id"`
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// Twitch's helix library predates conduits, so these calls are made
+// directly against the API.
+type ConduitClient struct {
+	baseUrl        string
+	clientId       string
+	appAccessToken string
+	http           *http.Client
+}
+
+func NewConduitClient(baseUrl string, clientId string, appAccessToken string) *ConduitClient {
+	if baseUrl == "" {
+		baseUrl = helix.DefaultAPIBaseURL
+	}
+	return &ConduitClient{
+		baseUrl:        baseUrl,
+		clientId:       clientId,
+		appAccessToken: appAccessToken,
+		http:           &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Conduits are managed with the same app access token as everything else
+func (c *Client) Conduits() *ConduitClient {
+	return NewConduitClient(config.TwitchApiUrl, config.TwitchApiKey, c.tc.GetAppAccessToken())
+}
+
+// The transport new subscriptions should use
+func EventsubTransportMethod() string {
+	if config.EventsubTransport == TransportConduit {
+		return TransportConduit
+	}
+	return TransportWebhook
+}
+
+// Subscribes to an EventSub type for a broadcaster through whichever
+// transport is configured. Returns the new subscription's id and status.
+func (c *Client) Subscribe(subType string, version string, broadcasterId string, callback string) (string, string, error) {
+	if EventsubTransportMethod() == TransportWebhook {
+		id, err := c.CreateEventSubSubscription(subType, version, broadcasterId, callback)
+		return id, "webhook_callback_verification_pending", err
+	}
+
+	conduits := c.Conduits()
+	conduitId, err := conduits.ActiveConduitId()
+	if err != nil {
+		return "", "", err
+	}
+	return conduits.Subscribe(subType, version, broadcasterId, conduitId)
+}
+
+// The configured conduit, or failing that the app's first one
+func (c *ConduitClient) ActiveConduitId() (string, error) {
+	if config.EventsubConduitId != "" {
+		return config.EventsubConduitId, nil
+	}
+	conduits, err := c.GetConduits()
+	if err != nil {
+		return "", err
+	}
+	if len(conduits) == 0 {
+		return "", errors.New("no eventsub conduit exists")
+	}
+	return conduits[0].Id, nil
+}
+
+func (c *ConduitClient) GetConduits() ([]*Conduit, error) {
+	resp := struct {
+		Data []*Conduit `json:"data"`
+	}{}
+	err := c.request("GET", "/eventsub/conduits", nil, nil, &resp)
+	if err != nil {
+		return []*Conduit{}, err
+	}
+	return resp.Data, nil
+}
+
+func (c *ConduitClient) CreateConduit(shardCount int) (*Conduit, error) {
+	resp := struct {
+		Data []*Conduit `json:"data"`
+	}{}
+	err := c.request("POST", "/eventsub/conduits", nil, map[string]int{"shard_count": shardCount}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, errors.New("twitch returned no conduit")
+	}
+	return resp.Data[0], nil
+}
+
+// Changes the number of shards. Shards past the new count are dropped.
+func (c *ConduitClient) UpdateConduit(id string, shardCount int) (*Conduit, error) {
+	resp := struct {
+		Data []*Conduit `json:"data"`
+	}{}
+	err := c.request("PATCH", "/eventsub/conduits", nil, map[string]any{"id": id, "shard_count": shardCount}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, errors.New("twitch returned no conduit")
+	}
+	return resp.Data[0], nil
+}
+
+// Deleting a conduit also deletes every subscription routed through it
+func (c *ConduitClient) DeleteConduit(id string) error {
+	return c.request("DELETE", "/eventsub/conduits", url.Values{"id": {id}}, nil, nil)
+}
+
+func (c *ConduitClient) GetConduitShards(conduitId string) ([]*ConduitShard, error) {
+	output := []*ConduitShard{}
+	after := ""
+
+	for {
+		query := url.Values{"conduit_id": {conduitId}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		resp := struct {
+			Data       []*ConduitShard `json:"data"`
+			Pagination struct {
+				Cursor string `json:"cursor"`
+			} `json:"pagination"`
+		}{}
+		err := c.request("GET", "/eventsub/conduits/shards", query, nil, &resp)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, resp.Data...)
+		if resp.Pagination.Cursor == "" {
+			break
+		}
+		after = resp.Pagination.Cursor
+	}
+
+	return output, nil
+}
+
+func (c *ConduitClient) UpdateConduitShards(conduitId string, shards []*ConduitShard) ([]*ConduitShard, []*ShardError, error) {
+	resp := struct {
+		Data   []*ConduitShard `json:"data"`
+		Errors []*ShardError   `json:"errors"`
+	}{}
+	err := c.request("PATCH", "/eventsub/conduits/shards", nil, map[string]any{
+		"conduit_id": conduitId,
+		"shards":     shards,
+	}, &resp)
+	if err != nil {
+		return []*ConduitShard{}, []*ShardError{}, err
+	}
+	if resp.Errors == nil {
+		resp.Errors = []*ShardError{}
+	}
+	return resp.Data, resp.Errors, nil
+}
+
+// Points every shard of a conduit at our webhook
+func (c *ConduitClient) AssignWebhookShards(conduitId string, shardCount int, callback string) ([]*ConduitShard, []*ShardError, error) {
+	shards := []*ConduitShard{}
+	for i := 0; i < shardCount; i++ {
+		shards = append(shards, &ConduitShard{
+			Id: fmt.Sprint(i),
+			Transport: &ShardTransport{
+				Method:   TransportWebhook,
+				Callback: callback,
+				Secret:   config.TwitchEventSecret,
+			},
+		})
+	}
+	return c.UpdateConduitShards(conduitId, shards)
+}
+
+// Routes a subscription through a conduit. Returns its id and status.
+func (c *ConduitClient) Subscribe(subType string, version string, broadcasterId string, conduitId string) (string, string, error) {
+	resp := struct {
+		Data []struct {
+			Id     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}{}
+	err := c.request("POST", "/eventsub/subscriptions", nil, map[string]any{
+		"type":      subType,
+		"version":   version,
+		"condition": subscriptionCondition(subType, broadcasterId),
+		"transport": map[string]string{
+			"method":     TransportConduit,
+			"conduit_id": conduitId,
+		},
+	}, &resp)
+	if err != nil {
+		return "", "", err
+	}
+	if len(resp.Data) == 0 {
+		return "", "", errors.New("twitch returned no subscription")
+	}
+	return resp.Data[0].Id, resp.Data[0].Status, nil
+}
+
+func (c *ConduitClient) request(method string, path string, query url.Values, body any, output any) error {
+	endpoint := c.baseUrl + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+
+	request, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Client-Id", c.clientId)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %v", c.appAccessToken))
+	if body != nil {
+		request.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBytes, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errBody := struct {
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(respBytes, &errBody)
+		return fmt.Errorf("twitch returned %v: %v", resp.StatusCode, errBody.Message)
+	}
+	if output != nil && len(respBytes) > 0 {
+		return json.Unmarshal(respBytes, output)
+	}
+
+	return nil
+}
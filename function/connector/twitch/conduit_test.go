@@ -0,0 +1,144 @@
+package twitch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Stands in for the conduit endpoints of the Twitch API, remembering what
+// was asked of it
+type conduitStandIn struct {
+	conduits []*Conduit
+	shards   map[string]*ConduitShard
+	requests []map[string]any
+}
+
+func newConduitStandIn(t *testing.T, s *conduitStandIn) *httptest.Server {
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, status int, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+	readBody := func(r *http.Request) map[string]any {
+		body := map[string]any{}
+		bodyBytes, _ := io.ReadAll(r.Body)
+		json.Unmarshal(bodyBytes, &body)
+		s.requests = append(s.requests, body)
+		return body
+	}
+
+	mux.HandleFunc("/eventsub/conduits", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer app-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "client-id", r.Header.Get("Client-Id"))
+		switch r.Method {
+		case "GET":
+			reply(w, 200, map[string]any{"data": s.conduits})
+		case "POST":
+			body := readBody(r)
+			conduit := &Conduit{Id: "conduit-1", ShardCount: int(body["shard_count"].(float64))}
+			s.conduits = append(s.conduits, conduit)
+			reply(w, 200, map[string]any{"data": []*Conduit{conduit}})
+		case "DELETE":
+			if len(s.conduits) == 0 || r.URL.Query().Get("id") != s.conduits[0].Id {
+				reply(w, 404, map[string]any{"message": "conduit not found"})
+				return
+			}
+			s.conduits = []*Conduit{}
+			w.WriteHeader(204)
+		}
+	})
+	mux.HandleFunc("/eventsub/conduits/shards", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			// One shard per page to exercise pagination
+			ids := []string{"0", "1"}
+			page := 0
+			if r.URL.Query().Get("after") == "page-2" {
+				page = 1
+			}
+			resp := map[string]any{"data": []*ConduitShard{s.shards[ids[page]]}}
+			if page == 0 {
+				resp["pagination"] = map[string]string{"cursor": "page-2"}
+			}
+			reply(w, 200, resp)
+		case "PATCH":
+			body := readBody(r)
+			bodyBytes, _ := json.Marshal(body["shards"])
+			shards := []*ConduitShard{}
+			json.Unmarshal(bodyBytes, &shards)
+			accepted := []*ConduitShard{}
+			errors := []*ShardError{}
+			for _, shard := range shards {
+				if shard.Transport.Callback == "" {
+					errors = append(errors, &ShardError{Id: shard.Id, Message: "missing callback", Code: "400"})
+					continue
+				}
+				shard.Status = "webhook_callback_verification_pending"
+				s.shards[shard.Id] = shard
+				accepted = append(accepted, shard)
+			}
+			reply(w, 202, map[string]any{"data": accepted, "errors": errors})
+		}
+	})
+	mux.HandleFunc("/eventsub/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		readBody(r)
+		reply(w, 202, map[string]any{"data": []map[string]string{{"id": "sub-1", "status": "enabled"}}})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestConduitClient(t *testing.T) {
+	standIn := &conduitStandIn{conduits: []*Conduit{}, shards: map[string]*ConduitShard{}}
+	server := newConduitStandIn(t, standIn)
+	defer server.Close()
+	c := NewConduitClient(server.URL, "client-id", "app-token")
+
+	conduit, err := c.CreateConduit(2)
+	assert.NoError(t, err)
+	assert.Equal(t, &Conduit{Id: "conduit-1", ShardCount: 2}, conduit)
+
+	activeId, err := c.ActiveConduitId()
+	assert.NoError(t, err)
+	assert.Equal(t, "conduit-1", activeId)
+
+	assigned, shardErrors, err := c.AssignWebhookShards(conduit.Id, conduit.ShardCount, "https://bot.example/event/webhook")
+	assert.NoError(t, err)
+	assert.Len(t, assigned, 2)
+	assert.Empty(t, shardErrors)
+
+	shards, err := c.GetConduitShards(conduit.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, shards, 2) {
+		assert.Equal(t, "1", shards[1].Id)
+		assert.Equal(t, TransportWebhook, shards[1].Transport.Method)
+		assert.Equal(t, "https://bot.example/event/webhook", shards[1].Transport.Callback)
+	}
+
+	_, shardErrors, err = c.UpdateConduitShards(conduit.Id, []*ConduitShard{{Id: "0", Transport: &ShardTransport{Method: TransportWebhook}}})
+	assert.NoError(t, err)
+	if assert.Len(t, shardErrors, 1) {
+		assert.Equal(t, "missing callback", shardErrors[0].Message)
+	}
+
+	id, status, err := c.Subscribe("channel.raid", "1", "1234", conduit.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "sub-1", id)
+	assert.Equal(t, "enabled", status)
+	subRequest := standIn.requests[len(standIn.requests)-1]
+	assert.Equal(t, map[string]any{"method": "conduit", "conduit_id": "conduit-1"}, subRequest["transport"])
+	assert.Equal(t, "1234", subRequest["condition"].(map[string]any)["to_broadcaster_user_id"])
+
+	assert.NoError(t, c.DeleteConduit(conduit.Id))
+	err = c.DeleteConduit(conduit.Id)
+	assert.EqualError(t, err, "twitch returned 404: conduit not found")
+
+	_, err = c.ActiveConduitId()
+	assert.Error(t, err)
+}
@@ -6,8 +6,8 @@ type ChallengeWebhook struct {
 }
 
 type NotificationWebhook struct {
-	Subscription *Subscription      `json:"subscription"`
-	Event        *map[string]string `json:"event"`
+	Subscription *Subscription   `json:"subscription"`
+	Event        *map[string]any `json:"event"`
 }
 
 type RevocationWebhook struct {
@@ -25,9 +25,18 @@ type Subscription struct {
 	CreatedAt string            `json:"created_at"`
 }
 
+// The tracked user the subscription's condition refers to
+func (s *Subscription) BroadcasterId() string {
+	if s.Condition["broadcaster_user_id"] != "" {
+		return s.Condition["broadcaster_user_id"]
+	}
+	return s.Condition["to_broadcaster_user_id"]
+}
+
 type Transport struct {
-	Method   string `json:"method"`
-	Callback string `json:"callback"`
+	Method    string `json:"method"`
+	Callback  string `json:"callback"`
+	ConduitId string `json:"conduit_id,omitempty"`
 }
 
 type Event struct {
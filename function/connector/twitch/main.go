@@ -95,3 +95,23 @@ func (c *Client) GetStreamByUserId(userId string) (*helix.Stream, error) {
 
 	return &resp.Data.Streams[0], nil
 }
+
+// Retrieves whichever of the given users are currently live
+func (c *Client) GetStreamsByUserIds(userIds []string) ([]helix.Stream, error) {
+	streams := []helix.Stream{}
+
+	// 100 item maximum for each call to GetStreams
+	for subList := range slices.Chunk(userIds, 100) {
+		resp, err := c.tc.GetStreams(&helix.StreamsParams{
+			UserIDs: subList,
+			Type:    "live",
+			First:   100,
+		})
+		if err != nil {
+			return streams, err
+		}
+		streams = append(streams, resp.Data.Streams...)
+	}
+
+	return streams, nil
+}
@@ -0,0 +1,55 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"shrampybot/config"
+
+	"github.com/litui/helix/v3"
+)
+
+// Client acting on behalf of a Twitch user who logged in through OAuth
+type OAuthClient struct {
+	tc *helix.Client
+}
+
+// Exchanges an authorization code for a user access token
+func NewOAuthClientFromCode(code string, redirectUri string) (*OAuthClient, error) {
+	tc, err := helix.NewClient(&helix.Options{
+		ClientID:     config.TwitchApiKey,
+		ClientSecret: config.TwitchApiSecret,
+		RedirectURI:  redirectUri,
+	})
+	if err != nil {
+		return &OAuthClient{}, err
+	}
+
+	resp, err := tc.RequestUserAccessToken(code)
+	if err != nil {
+		log.Printf("Unsuccessful request to Twitch for new token: %v\n", err)
+		return &OAuthClient{}, err
+	}
+	if resp.StatusCode > 399 || resp.Data.AccessToken == "" {
+		log.Printf("Error when requesting new Twitch token: %v %v\n", resp.StatusCode, resp.ErrorMessage)
+		return &OAuthClient{}, fmt.Errorf("error when requesting new twitch token")
+	}
+	tc.SetUserAccessToken(resp.Data.AccessToken)
+
+	return &OAuthClient{
+		tc: tc,
+	}, nil
+}
+
+// Retrieves the user the access token belongs to
+func (c *OAuthClient) GetSelf() (*helix.User, error) {
+	resp, err := c.tc.GetUsers(&helix.UsersParams{})
+	if err != nil {
+		return &helix.User{}, err
+	}
+	if len(resp.Data.Users) == 0 {
+		return &helix.User{}, errors.New("no user returned for access token")
+	}
+
+	return &resp.Data.Users[0], nil
+}
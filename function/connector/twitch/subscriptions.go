@@ -0,0 +1,144 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"time"
+
+	"github.com/litui/helix/v3"
+)
+
+const (
+	// Longest we'll wait for a rate limit bucket to refill before giving up
+	maxRateLimitWait = 10 * time.Second
+)
+
+type SubscriptionType struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+// Subscriptions every active user should have
+var DesiredSubscriptionTypes = []SubscriptionType{
+	{Type: "stream.online", Version: "1"},
+	{Type: "stream.offline", Version: "1"},
+	{Type: "channel.update", Version: "2"},
+	{Type: "channel.raid", Version: "1"},
+}
+
+// Raids are subscribed to on the receiving end, so that one subscription
+// per user covers raids between any two users.
+func subscriptionCondition(subType string, broadcasterId string) helix.EventSubCondition {
+	if subType == "channel.raid" {
+		return helix.EventSubCondition{ToBroadcasterUserID: broadcasterId}
+	}
+	return helix.EventSubCondition{BroadcasterUserID: broadcasterId}
+}
+
+// The tracked user a subscription's condition refers to
+func ConditionBroadcasterId(condition helix.EventSubCondition) string {
+	if condition.BroadcasterUserID != "" {
+		return condition.BroadcasterUserID
+	}
+	return condition.ToBroadcasterUserID
+}
+
+// Every EventSub subscription on the app, along with Twitch's cost totals
+type SubscriptionList struct {
+	Subscriptions []helix.EventSubSubscription
+	Total         int
+	TotalCost     int
+	MaxTotalCost  int
+}
+
+func (c *Client) GetEventSubSubscriptions() (*SubscriptionList, error) {
+	output := SubscriptionList{Subscriptions: []helix.EventSubSubscription{}}
+	var after string
+
+	for {
+		resp, err := c.tc.GetEventSubSubscriptions(&helix.EventSubSubscriptionsParams{
+			After: after,
+		})
+		if err != nil {
+			return &output, err
+		}
+		if resp.ErrorMessage != "" {
+			return &output, fmt.Errorf("twitch returned %v: %v", resp.StatusCode, resp.ErrorMessage)
+		}
+		output.Subscriptions = append(output.Subscriptions, resp.Data.EventSubSubscriptions...)
+		output.Total = resp.Data.Total
+		output.TotalCost = resp.Data.TotalCost
+		output.MaxTotalCost = resp.Data.MaxTotalCost
+
+		err = waitForRateLimit(&resp.ResponseCommon)
+		if err != nil {
+			return &output, err
+		}
+		if resp.Data.Pagination.Cursor == "" {
+			break
+		}
+		after = resp.Data.Pagination.Cursor
+	}
+
+	return &output, nil
+}
+
+// Subscribes our webhook to an EventSub type for a broadcaster, returning
+// the new subscription id.
+func (c *Client) CreateEventSubSubscription(subType string, version string, broadcasterId string, callback string) (string, error) {
+	resp, err := c.tc.CreateEventSubSubscription(&helix.EventSubSubscription{
+		Type:      subType,
+		Version:   version,
+		Condition: subscriptionCondition(subType, broadcasterId),
+		Transport: helix.EventSubTransport{
+			Method:   "webhook",
+			Callback: callback,
+			Secret:   config.TwitchEventSecret,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.ErrorMessage != "" {
+		return "", fmt.Errorf("twitch returned %v: %v", resp.StatusCode, resp.ErrorMessage)
+	}
+	if len(resp.Data.EventSubSubscriptions) == 0 {
+		return "", errors.New("twitch returned no subscription")
+	}
+	waitForRateLimit(&resp.ResponseCommon)
+
+	return resp.Data.EventSubSubscriptions[0].ID, nil
+}
+
+func (c *Client) RemoveEventSubSubscription(id string) error {
+	resp, err := c.tc.RemoveEventSubSubscription(id)
+	if err != nil {
+		return err
+	}
+	if resp.ErrorMessage != "" {
+		return fmt.Errorf("twitch returned %v: %v", resp.StatusCode, resp.ErrorMessage)
+	}
+
+	return waitForRateLimit(&resp.ResponseCommon)
+}
+
+// Sleeps until the rate limit bucket refills if we've nearly emptied it
+func waitForRateLimit(rc *helix.ResponseCommon) error {
+	if rc.GetRateLimit() == 0 || rc.GetRateLimitRemaining() > 1 {
+		return nil
+	}
+
+	wait := time.Until(time.Unix(int64(rc.GetRateLimitReset()), 0))
+	if wait <= 0 {
+		return nil
+	}
+	if wait > maxRateLimitWait {
+		return errors.New("twitch rate limit exhausted")
+	}
+	log.Printf("Twitch rate limit nearly exhausted; waiting %v\n", wait)
+	time.Sleep(wait)
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/litui/helix/v3"
+)
+
+const (
+	DefaultEventsubWebsocketUrl = "wss://eventsub.wss.twitch.tv/ws"
+	// Extra allowance on top of the keepalive timeout Twitch gives us
+	websocketKeepaliveSlack = 5 * time.Second
+	// Used until the welcome message tells us the real timeout
+	websocketWelcomeTimeout = 30 * time.Second
+	// How long message ids are remembered for spotting resends. Twitch
+	// doesn't resend anything older than this.
+	websocketSeenWindow = 10 * time.Minute
+)
+
+type WebsocketMessage struct {
+	Metadata WebsocketMetadata `json:"metadata"`
+	Payload  WebsocketPayload  `json:"payload"`
+}
+
+type WebsocketMetadata struct {
+	MessageId           string `json:"message_id"`
+	MessageType         string `json:"message_type"`
+	MessageTimestamp    string `json:"message_timestamp"`
+	SubscriptionType    string `json:"subscription_type,omitempty"`
+	SubscriptionVersion string `json:"subscription_version,omitempty"`
+}
+
+type WebsocketPayload struct {
+	Session      *WebsocketSession `json:"session,omitempty"`
+	Subscription *Subscription     `json:"subscription,omitempty"`
+	Event        *map[string]any   `json:"event,omitempty"`
+}
+
+type WebsocketSession struct {
+	Id                      string `json:"id"`
+	Status                  string `json:"status"`
+	KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+	ReconnectUrl            string `json:"reconnect_url"`
+	ConnectedAt             string `json:"connected_at"`
+}
+
+// EventSub over a WebSocket rather than webhooks, for development where a
+// public HTTPS callback isn't available.
+type WebsocketClient struct {
+	Url string
+	// Called once for the first session so that subscriptions can be bound
+	// to it. Sessions resumed after a reconnect keep their subscriptions.
+	OnWelcome      func(sessionId string) error
+	OnNotification func(messageId string, sub *Subscription, event *map[string]any) error
+	OnRevocation   func(sub *Subscription)
+
+	conn      *websocket.Conn
+	connLock  sync.Mutex
+	sessionId string
+	timeout   time.Duration
+	slack     time.Duration
+	// Ids of recent messages and when they arrived
+	seen map[string]time.Time
+}
+
+func NewWebsocketClient(url string) *WebsocketClient {
+	if url == "" {
+		url = DefaultEventsubWebsocketUrl
+	}
+	return &WebsocketClient{
+		Url:     url,
+		timeout: websocketWelcomeTimeout,
+		slack:   websocketKeepaliveSlack,
+		seen:    map[string]time.Time{},
+	}
+}
+
+func (c *WebsocketClient) SessionId() string {
+	return c.sessionId
+}
+
+// Reads messages until the context is cancelled or the connection fails
+func (c *WebsocketClient) Run(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.Url, nil)
+	if err != nil {
+		return err
+	}
+	c.setConn(conn)
+	defer c.setConn(nil)
+
+	// Unblock the read loop when we're told to stop
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.setConn(nil)
+		case <-stop:
+		}
+	}()
+
+	for {
+		msg, err := c.read(c.currentConn())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		err = c.handle(ctx, msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Swaps in a new connection, closing the previous one
+func (c *WebsocketClient) setConn(conn *websocket.Conn) {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = conn
+}
+
+func (c *WebsocketClient) currentConn() *websocket.Conn {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	return c.conn
+}
+
+func (c *WebsocketClient) read(conn *websocket.Conn) (*WebsocketMessage, error) {
+	if conn == nil {
+		return nil, errors.New("websocket connection closed")
+	}
+	conn.SetReadDeadline(time.Now().Add(c.timeout))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := WebsocketMessage{}
+	err = json.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (c *WebsocketClient) handle(ctx context.Context, msg *WebsocketMessage) error {
+	// Twitch may resend a message; only act on it once
+	if msg.Metadata.MessageId != "" && !c.markSeen(msg.Metadata.MessageId, time.Now()) {
+		return nil
+	}
+
+	switch msg.Metadata.MessageType {
+	case "session_welcome":
+		if msg.Payload.Session == nil {
+			return errors.New("welcome message had no session")
+		}
+		firstSession := c.sessionId == ""
+		c.welcome(msg.Payload.Session)
+		log.Printf("EventSub websocket session started: %v\n", c.sessionId)
+		if firstSession && c.OnWelcome != nil {
+			return c.OnWelcome(c.sessionId)
+		}
+
+	case "session_keepalive":
+		// Reading anything at all extends the deadline
+
+	case "session_reconnect":
+		if msg.Payload.Session == nil || msg.Payload.Session.ReconnectUrl == "" {
+			return errors.New("reconnect message had no url")
+		}
+		return c.reconnect(ctx, msg.Payload.Session.ReconnectUrl)
+
+	case "notification":
+		if msg.Payload.Subscription == nil {
+			return nil
+		}
+		log.Printf("Received notification: %v\n", msg.Payload.Subscription.Type)
+		if c.OnNotification != nil {
+			err := c.OnNotification(msg.Metadata.MessageId, msg.Payload.Subscription, msg.Payload.Event)
+			if err != nil {
+				log.Printf("Notification callback failed: %v\n", err)
+			}
+		}
+
+	case "revocation":
+		log.Printf("Received revocation request: %v\n", msg.Payload.Subscription)
+		if c.OnRevocation != nil && msg.Payload.Subscription != nil {
+			c.OnRevocation(msg.Payload.Subscription)
+		}
+	}
+
+	return nil
+}
+
+// Remembers a message id, returning false if it was already seen. Ids past
+// the resend window are forgotten so the map doesn't grow forever.
+func (c *WebsocketClient) markSeen(messageId string, now time.Time) bool {
+	for id, at := range c.seen {
+		if now.Sub(at) > websocketSeenWindow {
+			delete(c.seen, id)
+		}
+	}
+	if _, ok := c.seen[messageId]; ok {
+		return false
+	}
+	c.seen[messageId] = now
+	return true
+}
+
+func (c *WebsocketClient) welcome(session *WebsocketSession) {
+	c.sessionId = session.Id
+	if session.KeepaliveTimeoutSeconds > 0 {
+		c.timeout = time.Duration(session.KeepaliveTimeoutSeconds)*time.Second + c.slack
+	}
+}
+
+// Moves to the url Twitch gave us. The old connection has to stay open
+// until the new one has been welcomed, or messages can be lost.
+func (c *WebsocketClient) reconnect(ctx context.Context, url string) error {
+	log.Printf("EventSub websocket reconnecting to %v\n", url)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	msg, err := c.read(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if msg.Metadata.MessageType != "session_welcome" || msg.Payload.Session == nil {
+		conn.Close()
+		return fmt.Errorf("expected session_welcome after reconnect, got %v", msg.Metadata.MessageType)
+	}
+	c.markSeen(msg.Metadata.MessageId, time.Now())
+	c.welcome(msg.Payload.Session)
+
+	c.setConn(conn)
+	log.Printf("EventSub websocket session resumed: %v\n", c.sessionId)
+
+	return nil
+}
+
+// Client for creating subscriptions bound to a websocket session. These need
+// a user access token rather than an app token. The API url can point at
+// the Twitch CLI's mock server.
+type WebsocketSubscriber struct {
+	tc *helix.Client
+}
+
+func NewWebsocketSubscriber(userAccessToken string, apiBaseUrl string) (*WebsocketSubscriber, error) {
+	tc, err := helix.NewClient(&helix.Options{
+		ClientID:        config.TwitchApiKey,
+		UserAccessToken: userAccessToken,
+		APIBaseURL:      apiBaseUrl,
+	})
+	if err != nil {
+		return &WebsocketSubscriber{}, err
+	}
+
+	return &WebsocketSubscriber{tc: tc}, nil
+}
+
+func (s *WebsocketSubscriber) Subscribe(subType string, version string, broadcasterId string, sessionId string) (string, error) {
+	resp, err := s.tc.CreateEventSubSubscription(&helix.EventSubSubscription{
+		Type:      subType,
+		Version:   version,
+		Condition: subscriptionCondition(subType, broadcasterId),
+		Transport: helix.EventSubTransport{
+			Method:    "websocket",
+			SessionID: sessionId,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.ErrorMessage != "" {
+		return "", fmt.Errorf("twitch returned %v: %v", resp.StatusCode, resp.ErrorMessage)
+	}
+	if len(resp.Data.EventSubSubscriptions) == 0 {
+		return "", errors.New("twitch returned no subscription")
+	}
+
+	return resp.Data.EventSubSubscriptions[0].ID, nil
+}
@@ -0,0 +1,141 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func welcomeMessage(id string, sessionId string) string {
+	return fmt.Sprintf(`{"metadata":{"message_id":"%v","message_type":"session_welcome"},"payload":{"session":{"id":"%v","status":"connected","keepalive_timeout_seconds":10}}}`, id, sessionId)
+}
+
+func notificationMessage(id string, subType string) string {
+	return fmt.Sprintf(`{"metadata":{"message_id":"%v","message_type":"notification","subscription_type":"%v"},"payload":{"subscription":{"id":"sub-%v","type":"%v","version":"1","condition":{"broadcaster_user_id":"1234"}},"event":{"broadcaster_user_id":"1234"}}}`, id, subType, id, subType)
+}
+
+// Stands in for Twitch: a welcome, a keepalive, a notification sent twice,
+// then a reconnect to a second endpoint which delivers one more.
+func newStandInServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	serve := func(messages ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("upgrade failed: %v", err)
+				return
+			}
+			defer conn.Close()
+			for _, msg := range messages {
+				conn.WriteMessage(websocket.TextMessage, []byte(msg))
+			}
+			// Hold the connection open until the client goes away
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	reconnectUrl := "ws" + strings.TrimPrefix(server.URL, "http") + "/reconnect"
+	mux.HandleFunc("/ws", serve(
+		welcomeMessage("m1", "session-1"),
+		`{"metadata":{"message_id":"m2","message_type":"session_keepalive"},"payload":{}}`,
+		notificationMessage("m3", "stream.online"),
+		notificationMessage("m3", "stream.online"),
+		fmt.Sprintf(`{"metadata":{"message_id":"m4","message_type":"session_reconnect"},"payload":{"session":{"id":"session-1","status":"reconnecting","reconnect_url":"%v"}}}`, reconnectUrl),
+	))
+	mux.HandleFunc("/reconnect", serve(
+		welcomeMessage("m5", "session-2"),
+		notificationMessage("m6", "stream.offline"),
+	))
+
+	return server
+}
+
+func TestWebsocketClient(t *testing.T) {
+	server := newStandInServer(t)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewWebsocketClient("ws" + strings.TrimPrefix(server.URL, "http") + "/ws")
+	welcomes := []string{}
+	notifications := []string{}
+	client.OnWelcome = func(sessionId string) error {
+		welcomes = append(welcomes, sessionId)
+		return nil
+	}
+	client.OnNotification = func(messageId string, sub *Subscription, event *map[string]any) error {
+		notifications = append(notifications, sub.Type)
+		assert.Equal(t, "1234", (*event)["broadcaster_user_id"])
+		if len(notifications) == 2 {
+			cancel()
+		}
+		return nil
+	}
+
+	err := client.Run(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"session-1"}, welcomes, "subscriptions are only created for the first session")
+	assert.Equal(t, []string{"stream.online", "stream.offline"}, notifications, "duplicates are dropped")
+	assert.Equal(t, "session-2", client.SessionId())
+}
+
+func TestWebsocketClientKeepaliveTimeout(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(
+			`{"metadata":{"message_id":"m1","message_type":"session_welcome"},"payload":{"session":{"id":"session-1","keepalive_timeout_seconds":1}}}`,
+		))
+		// Then say nothing, keepalives included
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewWebsocketClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	client.slack = 0
+
+	start := time.Now()
+	err := client.Run(context.Background())
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 3*time.Second, "silence past the keepalive timeout should drop the connection")
+}
+
+func TestWebsocketClientMarkSeen(t *testing.T) {
+	c := NewWebsocketClient("")
+	start := time.Now()
+
+	assert.True(t, c.markSeen("m1", start))
+	assert.False(t, c.markSeen("m1", start.Add(time.Minute)))
+	assert.True(t, c.markSeen("m2", start.Add(time.Minute)))
+
+	// m1 has aged out; m2 hasn't yet
+	later := start.Add(websocketSeenWindow + 30*time.Second)
+	assert.True(t, c.markSeen("m3", later))
+	assert.Len(t, c.seen, 2)
+	assert.False(t, c.markSeen("m2", later))
+}
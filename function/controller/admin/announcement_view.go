@@ -0,0 +1,262 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"shrampybot/connector"
+	"shrampybot/controller/event"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"shrampybot/utility/queue"
+	"slices"
+	"time"
+)
+
+// Delivery of stream announcements to each platform, and manual retries of
+// the ones that failed
+type AnnouncementView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type Announcement struct {
+	StreamId   string                          `json:"stream_id"`
+	UserLogin  string                          `json:"user_login"`
+	UserName   string                          `json:"user_name"`
+	Title      string                          `json:"title"`
+	GameName   string                          `json:"game_name"`
+	StartedAt  time.Time                       `json:"started_at"`
+	EndedAt    time.Time                       `json:"ended_at,omitempty"`
+	Posts      map[string]utility.PostResponse `json:"posts"`
+	Deliveries map[string]nosqldb.PostDelivery `json:"deliveries"`
+}
+
+type AnnouncementBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*Announcement `json:"data"`
+}
+
+// One of Login or StreamId
+type AnnouncementPreviewRequest struct {
+	Login    string `json:"login,omitempty"`
+	StreamId string `json:"stream_id,omitempty"`
+}
+
+type AnnouncementRetryRequest struct {
+	Platform string `json:"platform"`
+}
+
+type AnnouncementRetryResponse struct {
+	StreamId string                `json:"stream_id"`
+	Platform string                `json:"platform"`
+	Delivery *nosqldb.PostDelivery `json:"delivery,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+func NewAnnouncementView() *AnnouncementView {
+	c := AnnouncementView{}
+	return &c
+}
+
+func (v *AnnouncementView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func newAnnouncement(stream *nosqldb.StreamHistoryDatum) *Announcement {
+	a := Announcement{
+		StreamId:   stream.ID,
+		UserLogin:  stream.UserLogin,
+		UserName:   stream.UserName,
+		Title:      stream.Title,
+		GameName:   stream.GameName,
+		StartedAt:  stream.StartedAt,
+		EndedAt:    stream.EndedAt,
+		Posts:      stream.Posts,
+		Deliveries: stream.Deliveries,
+	}
+	if a.Posts == nil {
+		a.Posts = map[string]utility.PostResponse{}
+	}
+	if a.Deliveries == nil {
+		a.Deliveries = map[string]nosqldb.PostDelivery{}
+	}
+	return &a
+}
+
+// Lists announcements with failed or stalled deliveries, or a single stream's
+// announcement at admin/announcement/<stream_id>
+func (v *AnnouncementView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Announcement.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := AnnouncementBody{}
+	respBody.Data = []*Announcement{}
+
+	if len(route.Path) > 2 {
+		stream, err := n.GetStream(route.Path[2])
+		if err != nil {
+			log.Printf("Could not retrieve stream %v: %v\n", route.Path[2], err)
+			response.StatusCode = "500"
+			return response
+		}
+		if stream.ID == "" {
+			response.StatusCode = "404"
+			return response
+		}
+		respBody.Data = append(respBody.Data, newAnnouncement(stream))
+	} else {
+		streams, err := n.GetStreamsWithFailedDeliveries()
+		if err != nil {
+			log.Printf("Could not retrieve failed announcements: %v\n", err)
+			response.StatusCode = "500"
+			return response
+		}
+		// Pending deliveries only count once they've stalled
+		now := time.Now()
+		for _, stream := range streams {
+			if !stream.DeliveryNeedsAttention(now) {
+				continue
+			}
+			respBody.Data = append(respBody.Data, newAnnouncement(stream))
+		}
+	}
+	respBody.Count = len(respBody.Data)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Announcement.Get")
+	return response
+}
+
+// Previews a go-live at admin/announcement/preview, or posts a stream's
+// announcement to one platform again at admin/announcement/<stream_id>/retry
+func (v *AnnouncementView) Post(route *router.Route) *router.Response {
+	switch {
+	case len(route.Path) == 3 && route.Path[2] == "preview":
+		return v.preview(route)
+	case len(route.Path) == 4 && route.Path[3] == "retry":
+		return v.retry(route)
+	}
+
+	log.Println("Invalid path for announcement post.")
+	return router.NewResponse(router.GenericBodyDataFlat{}, "400")
+}
+
+// Runs a stream through the go-live checks and renders every platform's
+// post, without sending or storing anything
+func (v *AnnouncementView) preview(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Announcement.Preview")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	requestBody := AnnouncementPreviewRequest{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil || (requestBody.Login == "") == (requestBody.StreamId == "") {
+		log.Println("Preview needs either a login or a stream id.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	preview, err := event.PreviewAnnouncement(requestBody.Login, requestBody.StreamId)
+	if errors.Is(err, event.ErrStreamNotFound) || errors.Is(err, event.ErrUserNotFound) {
+		response.StatusCode = "404"
+		return response
+	}
+	if err != nil {
+		log.Printf("Could not preview announcement: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	bodyBytes, _ := json.Marshal(preview)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Announcement.Preview")
+	return response
+}
+
+func (v *AnnouncementView) retry(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Announcement.Retry")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	streamId := route.Path[2]
+
+	requestBody := AnnouncementRetryRequest{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if !slices.Contains(connector.PublisherNames(), requestBody.Platform) {
+		log.Printf("Unknown platform %v.\n", requestBody.Platform)
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	before, err := n.GetStream(streamId)
+	if err != nil {
+		log.Printf("Could not retrieve stream %v: %v\n", streamId, err)
+		response.StatusCode = "500"
+		return response
+	}
+	if before.ID == "" {
+		response.StatusCode = "404"
+		return response
+	}
+
+	respBody := AnnouncementRetryResponse{
+		StreamId: streamId,
+		Platform: requestBody.Platform,
+	}
+	respBody.Delivery, err = event.RetryAnnouncement(streamId, requestBody.Platform)
+	if err != nil {
+		respBody.Error = err.Error()
+	} else {
+		// The queued retry has nothing left to do once the post is out
+		err = queue.DefaultBackend().DeleteDeadLetter(event.AnnouncementRetryJobId(streamId, requestBody.Platform))
+		if err != nil && !errors.Is(err, queue.ErrNotFound) {
+			log.Printf("Could not clear dead letter for %v on stream %v: %v\n", requestBody.Platform, streamId, err)
+		}
+	}
+	recordAudit(route, n, "announcement.retry", streamId, before.Deliveries[requestBody.Platform], respBody)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Announcement.Retry")
+	return response
+}
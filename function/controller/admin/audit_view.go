@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+	"time"
+)
+
+type AuditView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type AuditBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*nosqldb.AuditLogDatum `json:"data"`
+}
+
+func NewAuditView() *AuditView {
+	c := AuditView{}
+	return &c
+}
+
+func (v *AuditView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Lists audit entries, optionally filtered with the actor, action, since
+// and until query parameters. Times are RFC3339.
+func (v *AuditView) Get(route *router.Route) *router.Response {
+	var err error
+	log.Println("Entered route: Admin.Audit.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	entries := []*nosqldb.AuditLogDatum{}
+	if len(route.Path) == 3 {
+		// Get single result
+		entry, err := n.GetAuditLog(route.Path[2])
+		if err != nil {
+			log.Println("Get audit entry failed.")
+			response.StatusCode = "500"
+			return response
+		}
+		if entry.Id != "" {
+			entries = append(entries, entry)
+		}
+	} else {
+		filter := nosqldb.AuditLogFilter{
+			ActorSub: route.Query.Get("actor"),
+			Action:   route.Query.Get("action"),
+		}
+		if since := route.Query.Get("since"); since != "" {
+			filter.Since, err = time.Parse(time.RFC3339, since)
+			if err != nil {
+				log.Printf("Invalid since value: %v\n", since)
+				response.StatusCode = "400"
+				return response
+			}
+		}
+		if until := route.Query.Get("until"); until != "" {
+			filter.Until, err = time.Parse(time.RFC3339, until)
+			if err != nil {
+				log.Printf("Invalid until value: %v\n", until)
+				response.StatusCode = "400"
+				return response
+			}
+		}
+
+		entries, err = n.GetAuditLogs(&filter)
+		if err != nil {
+			log.Printf("Could not retrieve audit log: %v\n", err)
+			response.StatusCode = "500"
+			return response
+		}
+	}
+
+	body := AuditBody{}
+	body.Count = len(entries)
+	body.Data = entries
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		log.Println("Could not marshal body bytes from body json.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	response.StatusCode = "200"
+	response.Body = string(bodyBytes)
+
+	log.Println("Exited route: Admin.Audit.Get")
+	return response
+}
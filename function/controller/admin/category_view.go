@@ -8,6 +8,10 @@ import (
 	"strings"
 )
 
+const (
+	categoryTableTarget = "category_map"
+)
+
 type CategoryView struct {
 	router.View `tstype:",extends,required"`
 }
@@ -125,6 +129,7 @@ func (v *CategoryView) Put(route *router.Route) *router.Response {
 	if originalCategory.Id != requestBody.Id {
 		requestBody.Id = originalCategory.Id
 	}
+	beforeCategory := *originalCategory
 
 	catList := append([]nosqldb.CategoryDatum{}, requestBody)
 	err = n.PutCategories(&catList)
@@ -140,6 +145,7 @@ func (v *CategoryView) Put(route *router.Route) *router.Response {
 		response.StatusCode = "500"
 		return response
 	}
+	recordAudit(route, n, "category.put", finalCategory.Id, beforeCategory, finalCategory)
 	returnList := append([]nosqldb.CategoryDatum{}, *finalCategory)
 
 	catBytes, err := json.Marshal(returnList)
@@ -229,6 +235,7 @@ func (v *CategoryView) Post(route *router.Route) *router.Response {
 		response.StatusCode = "500"
 		return response
 	}
+	recordAudit(route, n, "category.replace", categoryTableTarget, existingCategories, categories)
 	catBytes, _ := json.Marshal(categories)
 
 	body := map[string]any{}
@@ -263,12 +270,18 @@ func (v *CategoryView) Delete(route *router.Route) *router.Response {
 	if len(route.Path) == 3 {
 		ids := append([]string{}, route.Path[2])
 
-		err := n.RemoveCategory(&ids)
+		beforeCategory, err := n.GetCategory(route.Path[2])
+		if err != nil {
+			log.Println("Could not retrieve category before removal.")
+		}
+
+		err = n.RemoveCategory(&ids)
 		if err != nil {
 			log.Println("Remove category failed.")
 			response.StatusCode = "500"
 			return response
 		}
+		recordAudit(route, n, "category.delete", route.Path[2], beforeCategory, nil)
 	} else {
 		log.Println("No ID specified.")
 		response.StatusCode = "400"
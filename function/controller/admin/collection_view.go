@@ -99,6 +99,15 @@ func (c *CollectionView) Patch(route *router.Route) *router.Response {
 		return response
 	}
 
+	// Snapshot active logins before they get updated, for the audit log
+	beforeLogins := []string{}
+	for _, su := range storedUsers {
+		if su.ShrampybotActive {
+			beforeLogins = append(beforeLogins, su.Login)
+		}
+	}
+	sort.Strings(beforeLogins)
+
 	intersectUsers := []*nosqldb.TwitchUserDatum{}
 	extraUsers := []*nosqldb.TwitchUserDatum{}
 	diffUsers := []*nosqldb.TwitchUserDatum{}
@@ -174,6 +183,13 @@ func (c *CollectionView) Patch(route *router.Route) *router.Response {
 	body["data"] = data
 	bodyBytes, _ := json.Marshal(body)
 
+	afterLogins := slices.Clone(data)
+	sort.Strings(afterLogins)
+	recordAudit(route, n, "collection.patch", "twitch_users",
+		map[string][]string{"active_logins": beforeLogins},
+		map[string][]string{"active_logins": afterLogins},
+	)
+
 	response.StatusCode = "200"
 	response.Body = string(bodyBytes)
 
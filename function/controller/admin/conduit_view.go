@@ -0,0 +1,294 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/config"
+	"shrampybot/connector/twitch"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+)
+
+// Management of the EventSub conduits subscriptions can be routed through
+type ConduitView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type ConduitInfo struct {
+	twitch.Conduit `tstype:",extends,required"`
+	Shards         []*twitch.ConduitShard `json:"shards"`
+	// Filled in when shards are assigned
+	ShardErrors []*twitch.ShardError `json:"shard_errors,omitempty"`
+	// Whether new subscriptions are routed through this conduit
+	Active bool `json:"active"`
+}
+
+type ConduitBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*ConduitInfo `json:"data"`
+}
+
+type ConduitRequestBody struct {
+	ShardCount int `json:"shard_count"`
+}
+
+func NewConduitView() *ConduitView {
+	c := ConduitView{}
+	return &c
+}
+
+func (v *ConduitView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Lists the app's conduits along with their shards
+func (v *ConduitView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Conduit.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+	conduits := t.Conduits()
+
+	list, err := conduits.GetConduits()
+	if err != nil {
+		log.Printf("Could not retrieve conduits: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	activeId, _ := conduits.ActiveConduitId()
+
+	respBody := ConduitBody{}
+	respBody.Data = []*ConduitInfo{}
+	for _, conduit := range list {
+		if len(route.Path) > 2 && conduit.Id != route.Path[2] {
+			continue
+		}
+		shards, err := conduits.GetConduitShards(conduit.Id)
+		if err != nil {
+			log.Printf("Could not retrieve shards for conduit %v: %v\n", conduit.Id, err)
+			response.StatusCode = "500"
+			return response
+		}
+		respBody.Data = append(respBody.Data, &ConduitInfo{
+			Conduit: *conduit,
+			Shards:  shards,
+			Active:  conduit.Id == activeId,
+		})
+	}
+	respBody.Count = len(respBody.Data)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Conduit.Get")
+	return response
+}
+
+// Creates a conduit and points its shards at our webhook
+func (v *ConduitView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Conduit.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	requestBody := ConduitRequestBody{}
+	json.Unmarshal([]byte(route.Body), &requestBody)
+	if requestBody.ShardCount < 1 {
+		requestBody.ShardCount = 1
+	}
+	if config.EventsubUrl == "" {
+		log.Println("No EventSub callback url configured.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+	conduits := t.Conduits()
+
+	conduit, err := conduits.CreateConduit(requestBody.ShardCount)
+	if err != nil {
+		log.Printf("Could not create conduit: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	info, err := assignConduitShards(conduits, conduit)
+	if err != nil {
+		log.Printf("Could not assign shards for conduit %v: %v\n", conduit.Id, err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "conduit.create", conduit.Id, nil, info)
+
+	bodyBytes, _ := json.Marshal(info)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Conduit.Post")
+	return response
+}
+
+// Resizes a conduit at admin/conduit/<id> if a shard count is given, and
+// points all of its shards at our webhook again
+func (v *ConduitView) Patch(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Conduit.Patch")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+	requestBody := ConduitRequestBody{}
+	json.Unmarshal([]byte(route.Body), &requestBody)
+	if config.EventsubUrl == "" {
+		log.Println("No EventSub callback url configured.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+	conduits := t.Conduits()
+
+	list, err := conduits.GetConduits()
+	if err != nil {
+		log.Printf("Could not retrieve conduits: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	var before *twitch.Conduit
+	for _, conduit := range list {
+		if conduit.Id == route.Path[2] {
+			before = conduit
+		}
+	}
+	if before == nil {
+		response.StatusCode = "404"
+		return response
+	}
+
+	conduit := before
+	if requestBody.ShardCount > 0 && requestBody.ShardCount != before.ShardCount {
+		conduit, err = conduits.UpdateConduit(before.Id, requestBody.ShardCount)
+		if err != nil {
+			log.Printf("Could not update conduit %v: %v\n", before.Id, err)
+			response.StatusCode = "500"
+			return response
+		}
+	}
+	info, err := assignConduitShards(conduits, conduit)
+	if err != nil {
+		log.Printf("Could not assign shards for conduit %v: %v\n", conduit.Id, err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "conduit.update", conduit.Id, before, info)
+
+	bodyBytes, _ := json.Marshal(info)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Conduit.Patch")
+	return response
+}
+
+// Deletes a conduit, and with it every subscription routed through it
+func (v *ConduitView) Delete(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Conduit.Delete")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	err = t.Conduits().DeleteConduit(route.Path[2])
+	if err != nil {
+		log.Printf("Could not delete conduit %v: %v\n", route.Path[2], err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "conduit.delete", route.Path[2], &twitch.Conduit{Id: route.Path[2]}, nil)
+
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Conduit.Delete")
+	return response
+}
+
+func assignConduitShards(conduits *twitch.ConduitClient, conduit *twitch.Conduit) (*ConduitInfo, error) {
+	_, shardErrors, err := conduits.AssignWebhookShards(conduit.Id, conduit.ShardCount, config.EventsubUrl)
+	if err != nil {
+		return nil, err
+	}
+	for _, shardError := range shardErrors {
+		log.Printf("Shard %v of conduit %v was not assigned: %v\n", shardError.Id, conduit.Id, shardError.Message)
+	}
+	shards, err := conduits.GetConduitShards(conduit.Id)
+	if err != nil {
+		return nil, err
+	}
+	activeId, _ := conduits.ActiveConduitId()
+
+	return &ConduitInfo{
+		Conduit:     *conduit,
+		Shards:      shards,
+		ShardErrors: shardErrors,
+		Active:      conduit.Id == activeId,
+	}, nil
+}
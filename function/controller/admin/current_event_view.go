@@ -146,9 +146,11 @@ func (c *CurrentEventView) Put(route *router.Route) *router.Response {
 				Title:       responseBody.RetrievedEvent.Title,
 				Description: responseBody.RetrievedEvent.Description,
 			}
+			beforeEvent, _ := n.GetCurrentEvent(uint8(currentEventIndex))
 			if n.PutCurrentEvent(uint8(currentEventIndex), &currentEvent) == nil {
 				responseBody.Status = router.StatusText[router.StatusSuccess]
 				responseBody.CurrentEvent = currentEvent
+				recordAudit(route, n, "current_event.put", strconv.Itoa(int(currentEventIndex)), beforeEvent, currentEvent)
 			}
 		}
 	}
@@ -188,12 +190,14 @@ func (c *CurrentEventView) Delete(route *router.Route) *router.Response {
 	responseBody := CurrentEventDeleteResponseBody{}
 	responseBody.Status = router.StatusText[router.StatusUnknown]
 
+	beforeEvent, _ := n.GetCurrentEvent(uint8(currentEventIndex))
 	err = n.DeleteCurrentEvent(uint8(currentEventIndex))
 	if err != nil {
 		log.Printf("Could not delete current event by index %v: %v", currentEventIndex, err)
 		responseBody.Status = router.StatusText[router.StatusFailure]
 	} else {
 		responseBody.Status = router.StatusText[router.StatusSuccess]
+		recordAudit(route, n, "current_event.delete", strconv.Itoa(int(currentEventIndex)), beforeEvent, nil)
 	}
 
 	bodyBytes, _ := json.Marshal(responseBody)
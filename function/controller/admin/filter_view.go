@@ -8,6 +8,10 @@ import (
 	"strings"
 )
 
+const (
+	filterTableTarget = "filter"
+)
+
 type FilterView struct {
 	router.View `tstype:",extends,required"`
 }
@@ -107,6 +111,13 @@ func (v *FilterView) Put(route *router.Route) *router.Response {
 		response.StatusCode = "500"
 		return response
 	}
+	beforeFilter := &nosqldb.FilterDatum{}
+	if requestBody.Id != "" {
+		beforeFilter, err = n.GetFilterKeyword(requestBody.Id)
+		if err != nil {
+			log.Println("Could not retrieve existing filter.")
+		}
+	}
 
 	kwList := append([]*nosqldb.FilterDatum{}, &requestBody)
 	err = n.PutFilterKeywords(kwList)
@@ -115,6 +126,7 @@ func (v *FilterView) Put(route *router.Route) *router.Response {
 		response.StatusCode = "500"
 		return response
 	}
+	recordAudit(route, n, "filter.put", requestBody.Id, beforeFilter, &requestBody)
 
 	body := FilterBody{}
 	body.Count = 1
@@ -194,6 +206,7 @@ func (v *FilterView) Post(route *router.Route) *router.Response {
 		response.StatusCode = "500"
 		return response
 	}
+	recordAudit(route, n, "filter.replace", filterTableTarget, existingFilterKeywords, filterKeywords)
 	fkBytes, _ := json.Marshal(filterKeywords)
 
 	body := map[string]any{}
@@ -228,12 +241,18 @@ func (v *FilterView) Delete(route *router.Route) *router.Response {
 	if len(route.Path) == 3 {
 		ids := append([]string{}, route.Path[2])
 
-		err := n.RemoveFilterKeyword(&ids)
+		beforeFilter, err := n.GetFilterKeyword(route.Path[2])
+		if err != nil {
+			log.Println("Could not retrieve filter before removal.")
+		}
+
+		err = n.RemoveFilterKeyword(&ids)
 		if err != nil {
 			log.Println("Remove filter failed.")
 			response.StatusCode = "500"
 			return response
 		}
+		recordAudit(route, n, "filter.delete", route.Path[2], beforeFilter, nil)
 	} else {
 		log.Println("No ID specified.")
 		response.StatusCode = "400"
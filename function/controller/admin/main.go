@@ -1,12 +1,18 @@
 package admin
 
 import (
+	"encoding/json"
+	"log"
+	"reflect"
 	"shrampybot/config"
 	"shrampybot/router"
 	"shrampybot/utility"
 	"shrampybot/utility/nosqldb"
+	"sort"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 func AdminController(route *router.Route) *router.Response {
@@ -26,6 +32,16 @@ func AdminController(route *router.Route) *router.Response {
 	scopes := route.Router.Event.Scopes
 
 	switch route.Path[1] {
+	case "announcement":
+		if utility.MatchScope(scopes, "admin:announcements") {
+			c := NewAnnouncementView()
+			return c.CallMethod(route)
+		}
+	case "audit":
+		if utility.MatchScope(scopes, "admin:audit") {
+			c := NewAuditView()
+			return c.CallMethod(route)
+		}
 	case "category":
 		if utility.MatchScope(scopes, "admin:categories") {
 			c := NewCategoryView()
@@ -36,6 +52,11 @@ func AdminController(route *router.Route) *router.Response {
 			c := NewCollectionView()
 			return c.CallMethod(route)
 		}
+	case "conduit":
+		if utility.MatchScope(scopes, "admin:subscriptions") {
+			c := NewConduitView()
+			return c.CallMethod(route)
+		}
 	case "current_event":
 		if utility.MatchScope(scopes, "admin:events") {
 			c := NewCurrentEventView()
@@ -51,16 +72,46 @@ func AdminController(route *router.Route) *router.Response {
 			c := NewFilterView()
 			return c.CallMethod(route)
 		}
+	case "oidc_client":
+		if utility.MatchScope(scopes, "admin:oidc") {
+			c := NewOIDCClientView()
+			return c.CallMethod(route)
+		}
+	case "queue":
+		if utility.MatchScope(scopes, "admin:queue") {
+			c := NewQueueView()
+			return c.CallMethod(route)
+		}
+	case "replay":
+		if utility.MatchScope(scopes, "admin:replay") {
+			c := NewReplayView()
+			return c.CallMethod(route)
+		}
 	case "stream":
 		if utility.MatchScope(scopes, "admin:stream") {
 			c := NewStreamView()
 			return c.CallMethod(route)
 		}
+	case "subscription":
+		if utility.MatchScope(scopes, "admin:subscriptions") {
+			c := NewSubscriptionView()
+			return c.CallMethod(route)
+		}
+	case "template":
+		if utility.MatchScope(scopes, "admin:templates") {
+			c := NewTemplateView()
+			return c.CallMethod(route)
+		}
 	case "user":
 		if utility.MatchScope(scopes, "admin:users") {
 			c := NewUserView()
 			return c.CallMethod(route)
 		}
+	case "webhook":
+		if utility.MatchScope(scopes, "admin:webhooks") {
+			c := NewWebhookView()
+			return c.CallMethod(route)
+		}
 	case "token":
 		if utility.MatchScope(scopes, "admin:tokens") {
 			// Do not allow token management with a static token
@@ -87,3 +138,71 @@ func generateStaticToken(static *nosqldb.StaticTokenDatum) (string, error) {
 	})
 	return staticTokenRaw.SignedString([]byte(static.SecretKey))
 }
+
+// Appends an entry to the audit log for a mutating admin request.
+// Failures are logged but never block the request itself.
+func recordAudit(route *router.Route, n *nosqldb.NoSqlDb, action string, targetId string, before any, after any) {
+	claims := route.Router.Event.Claims
+
+	entry := nosqldb.AuditLogDatum{
+		Id:       uuid.NewString(),
+		Time:     time.Now().UTC(),
+		Action:   action,
+		TargetId: targetId,
+	}
+	entry.ActorSub, _ = claims["sub"].(string)
+	entry.TokenType, _ = claims["aud"].(string)
+	if entry.TokenType == "static" {
+		entry.TokenId, _ = claims["kid"].(string)
+	}
+
+	beforeMap := map[string]any{}
+	afterMap := map[string]any{}
+	if !isEmptyAuditValue(before) {
+		beforeBytes, _ := json.Marshal(before)
+		entry.Before = string(beforeBytes)
+		json.Unmarshal(beforeBytes, &beforeMap)
+	}
+	if !isEmptyAuditValue(after) {
+		afterBytes, _ := json.Marshal(after)
+		entry.After = string(afterBytes)
+		json.Unmarshal(afterBytes, &afterMap)
+	}
+	entry.Changed = changedFields(beforeMap, afterMap)
+
+	err := n.PutAuditLog(&entry)
+	if err != nil {
+		log.Printf("Could not record audit entry for %v on %v: %v\n", action, targetId, err)
+	}
+}
+
+func isEmptyAuditValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return true
+		}
+		rv = rv.Elem()
+	}
+	return rv.IsZero()
+}
+
+// Lists the top-level keys whose values differ between two json objects
+func changedFields(before map[string]any, after map[string]any) []string {
+	changed := []string{}
+	for k, v := range before {
+		if !reflect.DeepEqual(v, after[k]) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,291 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Registration of client apps which log users in through ShrampyBot
+type OIDCClientView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type OIDCClientRequestBody struct {
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirect_uris"`
+	Disabled     bool     `json:"disabled"`
+}
+
+type OutputOIDCClientInfo struct {
+	Id           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreatorId    string    `json:"creator_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Disabled     bool      `json:"disabled"`
+}
+
+type NewOIDCClientResponseBody struct {
+	OutputOIDCClientInfo `tstype:",extends,required"`
+	// Only ever returned on creation
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+type OIDCClientBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*OutputOIDCClientInfo `json:"data"`
+}
+
+func NewOIDCClientView() *OIDCClientView {
+	c := OIDCClientView{}
+	return &c
+}
+
+func (v *OIDCClientView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *OIDCClientView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.OIDCClient.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	clients, err := n.GetOIDCClients()
+	if err != nil {
+		log.Println("Could not retrieve OIDC clients from db.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := OIDCClientBody{}
+	respBody.Data = []*OutputOIDCClientInfo{}
+	for _, client := range clients {
+		if len(route.Path) > 2 && client.Id != route.Path[2] {
+			continue
+		}
+		respBody.Data = append(respBody.Data, oidcClientInfo(client))
+	}
+	respBody.Count = len(respBody.Data)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.OIDCClient.Get")
+	return response
+}
+
+func (v *OIDCClientView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.OIDCClient.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	claims := route.Router.Event.Claims
+
+	requestBody := OIDCClientRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if requestBody.Name == "" || !validRedirectUris(requestBody.RedirectUris) {
+		log.Println("OIDC clients need a name and valid redirect URIs.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	secret := utility.GenerateRandomHex(32)
+	client := nosqldb.OIDCClientDatum{
+		Id:           uuid.NewString(),
+		Name:         requestBody.Name,
+		CreatorId:    claims["sub"].(string),
+		CreatedAt:    time.Now(),
+		RedirectUris: requestBody.RedirectUris,
+		SecretHash:   utility.HashSecret(secret),
+		Disabled:     requestBody.Disabled,
+	}
+	err = n.PutOIDCClient(&client)
+	if err != nil {
+		log.Printf("Could not write OIDC client to table: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "oidc_client.create", client.Id, nil, oidcClientInfo(&client))
+
+	output := NewOIDCClientResponseBody{
+		OutputOIDCClientInfo: *oidcClientInfo(&client),
+		ClientSecret:         secret,
+	}
+	outBytes, _ := json.Marshal(output)
+
+	response.Body = string(outBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.OIDCClient.Post")
+	return response
+}
+
+// Updates name, redirect URIs or disabled state. The secret is untouched.
+func (v *OIDCClientView) Put(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.OIDCClient.Put")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	requestBody := OIDCClientRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if requestBody.Name == "" || !validRedirectUris(requestBody.RedirectUris) {
+		log.Println("OIDC clients need a name and valid redirect URIs.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	client, err := n.GetOIDCClient(route.Path[2])
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	if client.Id == "" {
+		response.StatusCode = "404"
+		return response
+	}
+	before := oidcClientInfo(client)
+
+	client.Name = requestBody.Name
+	client.RedirectUris = requestBody.RedirectUris
+	client.Disabled = requestBody.Disabled
+	err = n.PutOIDCClient(client)
+	if err != nil {
+		log.Printf("Could not write OIDC client to table: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "oidc_client.update", client.Id, before, oidcClientInfo(client))
+
+	outBytes, _ := json.Marshal(oidcClientInfo(client))
+
+	response.Body = string(outBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.OIDCClient.Put")
+	return response
+}
+
+func (v *OIDCClientView) Delete(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.OIDCClient.Delete")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	client, err := n.GetOIDCClient(route.Path[2])
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	if client.Id == "" {
+		response.StatusCode = "404"
+		return response
+	}
+
+	err = n.DeleteOIDCClient(client.Id)
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "oidc_client.delete", client.Id, oidcClientInfo(client), nil)
+
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.OIDCClient.Delete")
+	return response
+}
+
+// Redirect URIs must be absolute https URLs, or http on localhost for development
+func validRedirectUris(uris []string) bool {
+	if len(uris) == 0 {
+		return false
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || u.Host == "" || u.Fragment != "" {
+			return false
+		}
+		if u.Scheme != "https" && !(u.Scheme == "http" && u.Hostname() == "localhost") {
+			return false
+		}
+	}
+	return true
+}
+
+// Strips the secret hash from a client datum for logging or output
+func oidcClientInfo(client *nosqldb.OIDCClientDatum) *OutputOIDCClientInfo {
+	info := OutputOIDCClientInfo{}
+	clientBytes, _ := json.Marshal(client)
+	json.Unmarshal(clientBytes, &info)
+	return &info
+}
@@ -0,0 +1,162 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+	"shrampybot/utility/queue"
+)
+
+// Inspection and replay of event jobs that ran out of retries
+type QueueView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type DeadLetterBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*queue.Job `json:"data"`
+}
+
+func NewQueueView() *QueueView {
+	c := QueueView{}
+	return &c
+}
+
+func (v *QueueView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Lists dead letters, or a single one at admin/queue/<id>
+func (v *QueueView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Queue.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	dead, err := queue.DefaultBackend().GetDeadLetters()
+	if err != nil {
+		log.Printf("Could not retrieve dead letters: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := DeadLetterBody{}
+	respBody.Data = []*queue.Job{}
+	for _, job := range dead {
+		if len(route.Path) > 2 && job.Id != route.Path[2] {
+			continue
+		}
+		respBody.Data = append(respBody.Data, job)
+	}
+	respBody.Count = len(respBody.Data)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Queue.Get")
+	return response
+}
+
+// Puts a dead letter back on the queue at admin/queue/<id>/replay
+func (v *QueueView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Queue.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 4 || route.Path[3] != "replay" {
+		log.Println("Invalid path for queue post.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	job, err := queue.DefaultBackend().Replay(route.Path[2])
+	if errors.Is(err, queue.ErrNotFound) {
+		response.StatusCode = "404"
+		return response
+	}
+	if err != nil {
+		log.Printf("Could not replay job %v: %v\n", route.Path[2], err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "queue.replay", job.Id, nil, job)
+
+	bodyBytes, _ := json.Marshal(job)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Queue.Post")
+	return response
+}
+
+// Discards a dead letter for good
+func (v *QueueView) Delete(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Queue.Delete")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	backend := queue.DefaultBackend()
+	dead, err := backend.GetDeadLetters()
+	if err != nil {
+		log.Printf("Could not retrieve dead letters: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	var job *queue.Job
+	for _, d := range dead {
+		if d.Id == route.Path[2] {
+			job = d
+		}
+	}
+	if job == nil {
+		response.StatusCode = "404"
+		return response
+	}
+
+	err = backend.DeleteDeadLetter(job.Id)
+	if err != nil {
+		log.Printf("Could not delete dead letter %v: %v\n", job.Id, err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "queue.delete", job.Id, job, nil)
+
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Queue.Delete")
+	return response
+}
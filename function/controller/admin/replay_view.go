@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/controller/event"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+	"slices"
+)
+
+// Re-runs stored or hand-crafted EventSub notifications
+type ReplayView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type ReplayRequestBody struct {
+	// Either the id of a stored message or a raw notification body
+	MessageId string `json:"message_id,omitempty"`
+	Payload   string `json:"payload,omitempty"`
+	Mode      string `json:"mode"`
+}
+
+func NewReplayView() *ReplayView {
+	c := ReplayView{}
+	return &c
+}
+
+func (v *ReplayView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Shows the stored message at admin/replay/<message_id>
+func (v *ReplayView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Replay.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	message, err := n.GetEventsubMessage(route.Path[2])
+	if err != nil {
+		log.Printf("Could not retrieve eventsub message: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	if message.Id == "" {
+		response.StatusCode = "404"
+		return response
+	}
+
+	bodyBytes, _ := json.Marshal(message)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Replay.Get")
+	return response
+}
+
+// Replays a notification and reports what it did, or would have done
+func (v *ReplayView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Replay.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	requestBody := ReplayRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not parse replay request: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if requestBody.Mode == "" {
+		requestBody.Mode = event.PipelineModeDryRun
+	}
+	if !slices.Contains(event.PipelineModes, requestBody.Mode) {
+		log.Printf("Unknown replay mode %v\n", requestBody.Mode)
+		response.StatusCode = "400"
+		return response
+	}
+	if (requestBody.MessageId == "") == (requestBody.Payload == "") {
+		log.Println("Need exactly one of message_id or payload.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	payload := requestBody.Payload
+	if requestBody.MessageId != "" {
+		message, err := n.GetEventsubMessage(requestBody.MessageId)
+		if err != nil {
+			log.Printf("Could not retrieve eventsub message: %v\n", err)
+			response.StatusCode = "500"
+			return response
+		}
+		if message.Id == "" || message.Body == "" {
+			log.Printf("No stored payload for message %v\n", requestBody.MessageId)
+			response.StatusCode = "404"
+			return response
+		}
+		if message.Type != "notification" {
+			log.Printf("Message %v is a %v, not a notification.\n", message.Id, message.Type)
+			response.StatusCode = "400"
+			return response
+		}
+		payload = message.Body
+	}
+
+	report, err := event.ReplayNotification(requestBody.MessageId, payload, requestBody.Mode)
+	if err != nil {
+		log.Printf("Could not replay notification: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if report.Mode != event.PipelineModeDryRun {
+		recordAudit(route, n, "eventsub.replay", report.MessageId, nil, report)
+	}
+
+	bodyBytes, _ := json.Marshal(report)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Replay.Post")
+	return response
+}
@@ -3,8 +3,11 @@ package admin
 import (
 	"encoding/json"
 	"log"
+	"shrampybot/connector/twitch"
+	"shrampybot/controller/event"
 	"shrampybot/router"
 	"shrampybot/utility/nosqldb"
+	"slices"
 	"time"
 )
 
@@ -16,11 +19,29 @@ type StreamStatusPutRequest struct {
 	EndNow bool `json:"endNow,omitempty"`
 }
 
+// Subset of stream fields recorded in the audit log on status changes
+type StreamStatusAudit struct {
+	Id      string    `json:"id"`
+	EndedAt time.Time `json:"ended_at"`
+}
+
 type StreamPutResponse struct {
 	Id     string `json:"id"`
 	Status string `json:"status"`
 }
 
+type StreamPollResponse struct {
+	// Streams we have marked as live
+	Active int `json:"active"`
+	// Of those, how many Twitch reported as live
+	Live int `json:"live"`
+	// IDs of streams whose category, title or tags had changed
+	Updated []string `json:"updated"`
+	// IDs of changed streams that hadn't been announced and were checked
+	// for announcing again
+	Announcing []string `json:"announcing"`
+}
+
 func NewStreamView() *StreamView {
 	c := StreamView{}
 	return &c
@@ -43,6 +64,111 @@ func (v *StreamView) CallMethod(route *router.Route) *router.Response {
 	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
 }
 
+// Polls Twitch for the current state of every active stream and records
+// any changes on the stream timelines, in case notifications were missed.
+func (v *StreamView) Post(route *router.Route) *router.Response {
+	var err error
+	log.Println("Entered route: Admin.Stream.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) < 3 || route.Path[2] != "poll" {
+		log.Println("Invalid path for stream post.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	active, err := n.GetActiveStreams()
+	if err != nil {
+		log.Println("Could not get active streams.")
+		response.StatusCode = "500"
+		return response
+	}
+	userIds := []string{}
+	for _, stream := range *active {
+		userIds = append(userIds, stream.UserID)
+	}
+	tStreams, err := t.GetStreamsByUserIds(userIds)
+	if err != nil {
+		log.Printf("Could not get streams from Twitch: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	responseBody := StreamPollResponse{
+		Active:     len(*active),
+		Updated:    []string{},
+		Announcing: []string{},
+	}
+	now := time.Now()
+	for _, stream := range *active {
+		for _, tStream := range tStreams {
+			// Ended or restarted streams are left for the offline handler and tidy_live
+			if tStream.ID != stream.ID {
+				continue
+			}
+			responseBody.Live++
+
+			changed := stream.GameID != tStream.GameID || stream.Title != tStream.Title || !slices.Equal(stream.Tags, tStream.Tags)
+			retitled := stream.GameID != tStream.GameID || stream.Title != tStream.Title
+			stream.GameID = tStream.GameID
+			stream.GameName = tStream.GameName
+			stream.Title = tStream.Title
+			stream.Tags = tStream.Tags
+			stream.ViewerCount = tStream.ViewerCount
+
+			err = n.PutStream(&stream)
+			if err != nil {
+				log.Printf("Could not update stream %v: %v\n", stream.ID, err)
+				continue
+			}
+			if changed {
+				err = n.AdvanceStreamSegment(&stream, now, "poll")
+				if err != nil {
+					log.Printf("Could not update timeline for stream %v: %v\n", stream.ID, err)
+					continue
+				}
+				responseBody.Updated = append(responseBody.Updated, stream.ID)
+			}
+			// A missed channel.update can mean a missed announcement too
+			if retitled && !stream.Announced() && !stream.ShrampybotDebounced {
+				responseBody.Announcing = append(responseBody.Announcing, stream.ID)
+				err = event.AnnounceChangedStream(&stream)
+				if err != nil {
+					log.Printf("Could not announce stream %v: %v\n", stream.ID, err)
+				}
+			}
+		}
+	}
+
+	// Announcing queues webhook deliveries; send them now rather than
+	// leaving them for the next scheduled run
+	if len(responseBody.Announcing) > 0 {
+		event.DrainQueue(route.Router.Context())
+	}
+
+	response.StatusCode = "200"
+	bodyBytes, _ := json.Marshal(responseBody)
+	response.Body = string(bodyBytes)
+
+	log.Println("Exited route: Admin.Stream.Post")
+	return response
+}
+
 func (v *StreamView) Put(route *router.Route) *router.Response {
 	var err error
 	log.Println("Entered route: Admin.Stream.Put")
@@ -87,6 +213,7 @@ func (v *StreamView) Put(route *router.Route) *router.Response {
 				log.Printf("Already ended stream %v at %v, do nothing.", stream.ID, stream.EndedAt.Format(time.RFC3339))
 				responseBody.Status = router.StatusNotNeeded.String()
 			} else {
+				before := StreamStatusAudit{Id: stream.ID, EndedAt: stream.EndedAt}
 				stream.EndedAt = time.Now()
 				log.Printf("Setting stream %v end time to %v.", stream.ID, stream.EndedAt.Format(time.RFC3339))
 
@@ -96,6 +223,8 @@ func (v *StreamView) Put(route *router.Route) *router.Response {
 					responseBody.Status = router.StatusFailure.String()
 				} else {
 					responseBody.Status = router.StatusSuccess.String()
+					n.CloseStreamSegments(stream.ID, stream.EndedAt)
+					recordAudit(route, n, "stream.end", stream.ID, before, StreamStatusAudit{Id: stream.ID, EndedAt: stream.EndedAt})
 				}
 			}
 		}
@@ -0,0 +1,543 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/config"
+	"shrampybot/connector/twitch"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+	"sort"
+	"time"
+
+	"github.com/litui/helix/v3"
+)
+
+const (
+	// Stop making changes with enough time left to respond before the
+	// gateway times out. Callers can simply run the operation again.
+	subscriptionWorkBudget = 20 * time.Second
+)
+
+var (
+	// Statuses for subscriptions which are working or about to be
+	healthySubscriptionStatuses = []string{
+		"enabled",
+		"webhook_callback_verification_pending",
+	}
+)
+
+// Server-side counterpart to the shrampysubs report, reconcile and
+// unsubscribe tasks
+type SubscriptionView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type OutputSubscriptionInfo struct {
+	Id            string    `json:"id"`
+	Type          string    `json:"type"`
+	Version       string    `json:"version"`
+	Status        string    `json:"status"`
+	Cost          int       `json:"cost"`
+	BroadcasterId string    `json:"broadcaster_id"`
+	Login         string    `json:"login,omitempty"`
+	Callback      string    `json:"callback,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// A subscription we want but Twitch doesn't have (or has broken)
+type MissingSubscription struct {
+	BroadcasterId string `json:"broadcaster_id"`
+	Login         string `json:"login"`
+	Type          string `json:"type"`
+	Version       string `json:"version"`
+	// Set when an attempt to create it failed
+	Error string `json:"error,omitempty"`
+}
+
+type SubscriptionReport struct {
+	Total        int `json:"total"`
+	TotalCost    int `json:"total_cost"`
+	MaxTotalCost int `json:"max_total_cost"`
+	// Subscriptions per status, as reported by Twitch
+	StatusCounts map[string]int `json:"status_counts"`
+	// Number of subscriptions the active users should have between them
+	Desired int `json:"desired"`
+	// Active logins that Twitch no longer recognises
+	UnknownLogins []string `json:"unknown_logins"`
+
+	Subscriptions []*OutputSubscriptionInfo `json:"subscriptions,omitempty"`
+	Missing       []*MissingSubscription    `json:"missing"`
+	Orphans       []*OutputSubscriptionInfo `json:"orphans"`
+
+	// Filled in by reconcile and orphan deletion
+	DryRun  bool                      `json:"dry_run"`
+	Created []*MissingSubscription    `json:"created"`
+	Failed  []*MissingSubscription    `json:"failed"`
+	Deleted []*OutputSubscriptionInfo `json:"deleted"`
+	// True if we ran out of time before finishing; run it again
+	Incomplete bool `json:"incomplete"`
+}
+
+// A subscription's history as we've recorded it, brought up to date with
+// what Twitch currently says about it
+type SubscriptionLifecycle struct {
+	nosqldb.EventsubLifecycleDatum `tstype:",extends,required"`
+	Login                          string `json:"login,omitempty"`
+	// False once Twitch no longer lists the subscription
+	Listed bool `json:"listed"`
+}
+
+type SubscriptionLifecycleBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*SubscriptionLifecycle `json:"data"`
+}
+
+func NewSubscriptionView() *SubscriptionView {
+	c := SubscriptionView{}
+	return &c
+}
+
+func (v *SubscriptionView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Lists every subscription along with what's missing and what's orphaned,
+// or the verification and notification history at
+// admin/subscription/lifecycle
+func (v *SubscriptionView) Get(route *router.Route) *router.Response {
+	if len(route.Path) > 2 && route.Path[2] == "lifecycle" {
+		return v.getLifecycle(route)
+	}
+
+	log.Println("Entered route: Admin.Subscription.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	report, err := buildSubscriptionReport(n, t)
+	if err != nil {
+		log.Printf("Could not build subscription report: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	bodyBytes, _ := json.Marshal(report)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Subscription.Get")
+	return response
+}
+
+func (v *SubscriptionView) getLifecycle(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Subscription.Lifecycle.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	lifecycles, err := buildSubscriptionLifecycles(n, t)
+	if err != nil {
+		log.Printf("Could not build subscription lifecycles: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := SubscriptionLifecycleBody{}
+	respBody.Data = lifecycles
+	respBody.Count = len(lifecycles)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Subscription.Lifecycle.Get")
+	return response
+}
+
+// Creates the missing subscriptions at admin/subscription/reconcile.
+// Pass ?dry_run=true to only report what would be created.
+func (v *SubscriptionView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Subscription.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) < 3 || route.Path[2] != "reconcile" {
+		log.Println("Invalid path for subscription post.")
+		response.StatusCode = "400"
+		return response
+	}
+	if config.EventsubUrl == "" {
+		log.Println("No EventSub callback url configured.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	report, err := buildSubscriptionReport(n, t)
+	if err != nil {
+		log.Printf("Could not build subscription report: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	report.Subscriptions = nil
+	report.DryRun = route.Query.Get("dry_run") == "true"
+
+	deadline := time.Now().Add(subscriptionWorkBudget)
+	for _, missing := range report.Missing {
+		if report.DryRun {
+			report.Created = append(report.Created, missing)
+			continue
+		}
+		if time.Now().After(deadline) {
+			report.Incomplete = true
+			break
+		}
+
+		subId, status, err := t.Subscribe(missing.Type, missing.Version, missing.BroadcasterId, config.EventsubUrl)
+		if err != nil {
+			log.Printf("Could not subscribe %v to %v: %v\n", missing.Login, missing.Type, err)
+			missing.Error = err.Error()
+			report.Failed = append(report.Failed, missing)
+			continue
+		}
+		report.Created = append(report.Created, missing)
+
+		state, _ := n.GetEventsubSubscription(missing.BroadcasterId, missing.Type)
+		state.BroadcasterId = missing.BroadcasterId
+		state.Type = missing.Type
+		state.Version = missing.Version
+		state.SubscriptionId = subId
+		state.Status = status
+		n.PutEventsubSubscription(state)
+	}
+	if !report.DryRun && (len(report.Created) > 0 || len(report.Failed) > 0) {
+		recordAudit(route, n, "subscription.reconcile", "", nil, map[string]any{
+			"created":    report.Created,
+			"failed":     report.Failed,
+			"incomplete": report.Incomplete,
+		})
+	}
+
+	bodyBytes, _ := json.Marshal(report)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Subscription.Post")
+	return response
+}
+
+// Removes orphaned subscriptions at admin/subscription/orphans.
+// Pass ?dry_run=true to only report what would be removed.
+func (v *SubscriptionView) Delete(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Subscription.Delete")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) < 3 || route.Path[2] != "orphans" {
+		log.Println("Invalid path for subscription delete.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+	t, err := twitch.NewClient()
+	if err != nil {
+		log.Println("Could not connect to Twitch API.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	report, err := buildSubscriptionReport(n, t)
+	if err != nil {
+		log.Printf("Could not build subscription report: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	report.Subscriptions = nil
+	report.DryRun = route.Query.Get("dry_run") == "true"
+
+	deadline := time.Now().Add(subscriptionWorkBudget)
+	for _, orphan := range report.Orphans {
+		if report.DryRun {
+			report.Deleted = append(report.Deleted, orphan)
+			continue
+		}
+		if time.Now().After(deadline) {
+			report.Incomplete = true
+			break
+		}
+
+		err = t.RemoveEventSubSubscription(orphan.Id)
+		if err != nil {
+			log.Printf("Could not remove subscription %v: %v\n", orphan.Id, err)
+			continue
+		}
+		report.Deleted = append(report.Deleted, orphan)
+	}
+	if !report.DryRun && len(report.Deleted) > 0 {
+		recordAudit(route, n, "subscription.delete_orphans", "", report.Deleted, nil)
+	}
+
+	bodyBytes, _ := json.Marshal(report)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Subscription.Delete")
+	return response
+}
+
+// Compares what Twitch has against what the active users should have
+func buildSubscriptionReport(n *nosqldb.NoSqlDb, t *twitch.Client) (*SubscriptionReport, error) {
+	report := SubscriptionReport{
+		StatusCounts:  map[string]int{},
+		UnknownLogins: []string{},
+		Subscriptions: []*OutputSubscriptionInfo{},
+		Missing:       []*MissingSubscription{},
+		Orphans:       []*OutputSubscriptionInfo{},
+		Created:       []*MissingSubscription{},
+		Failed:        []*MissingSubscription{},
+		Deleted:       []*OutputSubscriptionInfo{},
+	}
+
+	logins, err := n.GetActiveTwitchLogins()
+	if err != nil {
+		return &report, err
+	}
+	users, err := t.GetUsers(logins)
+	if err != nil {
+		return &report, err
+	}
+	// broadcaster id -> login
+	desiredUsers := map[string]string{}
+	for _, user := range *users {
+		desiredUsers[user["id"]] = user["login"]
+	}
+	for _, login := range *logins {
+		found := false
+		for _, user := range *users {
+			if user["login"] == login {
+				found = true
+			}
+		}
+		if !found {
+			report.UnknownLogins = append(report.UnknownLogins, login)
+		}
+	}
+	report.Desired = len(desiredUsers) * len(twitch.DesiredSubscriptionTypes)
+
+	subs, err := t.GetEventSubSubscriptions()
+	if err != nil {
+		return &report, err
+	}
+	report.Total = subs.Total
+	report.TotalCost = subs.TotalCost
+	report.MaxTotalCost = subs.MaxTotalCost
+
+	// Healthy subscriptions by broadcaster id and type
+	covered := map[string]bool{}
+	for _, sub := range subs.Subscriptions {
+		info := subscriptionInfo(&sub, desiredUsers)
+		report.Subscriptions = append(report.Subscriptions, info)
+		report.StatusCounts[sub.Status]++
+
+		broadcasterId := twitch.ConditionBroadcasterId(sub.Condition)
+		key := nosqldb.EventsubSubscriptionId(broadcasterId, sub.Type)
+		_, userDesired := desiredUsers[broadcasterId]
+		switch {
+		case !userDesired || !subscriptionTypeDesired(sub.Type):
+			report.Orphans = append(report.Orphans, info)
+		case !subscriptionHealthy(sub.Status):
+			// Twitch keeps failed subscriptions around; they block nothing
+			// but clutter the list, and the user still needs a working one
+			report.Orphans = append(report.Orphans, info)
+		case sub.Transport.Method != twitch.EventsubTransportMethod():
+			// Left over from before switching transports; reconcile
+			// replaces it before orphan deletion removes it
+			report.Orphans = append(report.Orphans, info)
+		case covered[key]:
+			// Duplicates cost us twice and announce twice
+			report.Orphans = append(report.Orphans, info)
+		default:
+			covered[key] = true
+		}
+	}
+
+	for broadcasterId, login := range desiredUsers {
+		for _, subType := range twitch.DesiredSubscriptionTypes {
+			if covered[nosqldb.EventsubSubscriptionId(broadcasterId, subType.Type)] {
+				continue
+			}
+			report.Missing = append(report.Missing, &MissingSubscription{
+				BroadcasterId: broadcasterId,
+				Login:         login,
+				Type:          subType.Type,
+				Version:       subType.Version,
+			})
+		}
+	}
+	sort.Slice(report.Missing, func(i, j int) bool {
+		if report.Missing[i].Login == report.Missing[j].Login {
+			return report.Missing[i].Type < report.Missing[j].Type
+		}
+		return report.Missing[i].Login < report.Missing[j].Login
+	})
+
+	return &report, nil
+}
+
+// Joins our recorded history with Twitch's current list of subscriptions
+func buildSubscriptionLifecycles(n *nosqldb.NoSqlDb, t *twitch.Client) ([]*SubscriptionLifecycle, error) {
+	output := []*SubscriptionLifecycle{}
+
+	logins, err := n.GetTwitchIdLoginMap()
+	if err != nil {
+		return output, err
+	}
+	recorded, err := n.GetEventsubLifecycles()
+	if err != nil {
+		return output, err
+	}
+	subs, err := t.GetEventSubSubscriptions()
+	if err != nil {
+		return output, err
+	}
+
+	byId := map[string]*SubscriptionLifecycle{}
+	for _, record := range recorded {
+		lifecycle := &SubscriptionLifecycle{EventsubLifecycleDatum: *record}
+		byId[record.Id] = lifecycle
+		output = append(output, lifecycle)
+	}
+	for _, sub := range subs.Subscriptions {
+		lifecycle, ok := byId[sub.ID]
+		if !ok {
+			// Created before we started recording, or never verified
+			lifecycle = &SubscriptionLifecycle{}
+			lifecycle.Id = sub.ID
+			output = append(output, lifecycle)
+		}
+		lifecycle.Listed = true
+		lifecycle.BroadcasterId = twitch.ConditionBroadcasterId(sub.Condition)
+		lifecycle.Type = sub.Type
+		lifecycle.Version = sub.Version
+		lifecycle.Condition = conditionMap(sub.Condition)
+		lifecycle.Cost = int64(sub.Cost)
+		lifecycle.CreatedAt = sub.CreatedAt.Time.Format(time.RFC3339)
+		lifecycle.Status = sub.Status
+	}
+	for _, lifecycle := range output {
+		lifecycle.Login = logins[lifecycle.BroadcasterId]
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		if output[i].Login != output[j].Login {
+			return output[i].Login < output[j].Login
+		}
+		if output[i].Type != output[j].Type {
+			return output[i].Type < output[j].Type
+		}
+		return output[i].CreatedAt > output[j].CreatedAt
+	})
+
+	return output, nil
+}
+
+// Only the condition fields that apply to the subscription's type
+func conditionMap(condition helix.EventSubCondition) map[string]string {
+	output := map[string]string{}
+	conditionBytes, _ := json.Marshal(condition)
+	json.Unmarshal(conditionBytes, &output)
+	for k, v := range output {
+		if v == "" {
+			delete(output, k)
+		}
+	}
+	return output
+}
+
+func subscriptionTypeDesired(subType string) bool {
+	for _, desired := range twitch.DesiredSubscriptionTypes {
+		if desired.Type == subType {
+			return true
+		}
+	}
+	return false
+}
+
+func subscriptionHealthy(status string) bool {
+	for _, healthy := range healthySubscriptionStatuses {
+		if healthy == status {
+			return true
+		}
+	}
+	return false
+}
+
+func subscriptionInfo(sub *helix.EventSubSubscription, logins map[string]string) *OutputSubscriptionInfo {
+	return &OutputSubscriptionInfo{
+		Id:            sub.ID,
+		Type:          sub.Type,
+		Version:       sub.Version,
+		Status:        sub.Status,
+		Cost:          sub.Cost,
+		BroadcasterId: twitch.ConditionBroadcasterId(sub.Condition),
+		Login:         logins[twitch.ConditionBroadcasterId(sub.Condition)],
+		Callback:      sub.Transport.Callback,
+		CreatedAt:     sub.CreatedAt.Time,
+	}
+}
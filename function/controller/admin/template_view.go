@@ -0,0 +1,300 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/connector"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+	"slices"
+	"sort"
+)
+
+// Announcement templates per platform, with per-category overrides
+type TemplateView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type TemplateBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*nosqldb.AnnouncementTemplateDatum `json:"data"`
+	// Why a template was rejected
+	Error string `json:"error,omitempty"`
+}
+
+type TemplatePreviewRequestBody struct {
+	Platform   string `json:"platform"`
+	CategoryId string `json:"category_id,omitempty"`
+	// Unsaved template text to try out; the stored one is used if blank
+	Template string `json:"template,omitempty"`
+	StreamId string `json:"stream_id"`
+}
+
+type TemplatePreviewBody struct {
+	Platform string `json:"platform"`
+	// The stored template used, if any
+	TemplateId string `json:"template_id,omitempty"`
+	Message    string `json:"message"`
+	Error      string `json:"error,omitempty"`
+}
+
+func NewTemplateView() *TemplateView {
+	c := TemplateView{}
+	return &c
+}
+
+func (v *TemplateView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Lists templates, or a single one at admin/template/<id>
+func (v *TemplateView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Template.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	templates, err := n.GetAnnouncementTemplates()
+	if err != nil {
+		log.Printf("Could not retrieve announcement templates: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := TemplateBody{}
+	respBody.Data = []*nosqldb.AnnouncementTemplateDatum{}
+	for _, t := range templates {
+		if len(route.Path) > 2 && t.Id != route.Path[2] {
+			continue
+		}
+		respBody.Data = append(respBody.Data, t)
+	}
+	sort.Slice(respBody.Data, func(i, j int) bool {
+		return respBody.Data[i].Id < respBody.Data[j].Id
+	})
+	respBody.Count = len(respBody.Data)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Template.Get")
+	return response
+}
+
+// Creates or replaces the template for a platform and optional category
+func (v *TemplateView) Put(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Template.Put")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	requestBody := nosqldb.AnnouncementTemplateDatum{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := TemplateBody{}
+	switch {
+	case !slices.Contains(connector.PublisherNames(), requestBody.Platform):
+		respBody.Error = "unknown platform " + requestBody.Platform
+	case requestBody.CategoryId != "":
+		category, err := n.GetCategory(requestBody.CategoryId)
+		if err != nil || category.Id == "" {
+			respBody.Error = "unknown category " + requestBody.CategoryId
+		}
+	}
+	if respBody.Error == "" {
+		err = connector.ValidateAnnouncementTemplate(requestBody.Template)
+		if err != nil {
+			respBody.Error = err.Error()
+		}
+	}
+	if respBody.Error != "" {
+		log.Printf("Rejected announcement template: %v\n", respBody.Error)
+		bodyBytes, _ := json.Marshal(respBody)
+		response.Body = string(bodyBytes)
+		response.StatusCode = "400"
+		return response
+	}
+
+	before, _ := n.GetAnnouncementTemplate(nosqldb.AnnouncementTemplateId(requestBody.Platform, requestBody.CategoryId))
+	err = n.PutAnnouncementTemplate(&requestBody)
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "template.put", requestBody.Id, before, &requestBody)
+
+	respBody.Data = []*nosqldb.AnnouncementTemplateDatum{&requestBody}
+	respBody.Count = 1
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Template.Put")
+	return response
+}
+
+// Renders a template against a stored stream at admin/template/preview
+func (v *TemplateView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Template.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 || route.Path[2] != "preview" {
+		log.Println("Invalid path for template post.")
+		response.StatusCode = "400"
+		return response
+	}
+	requestBody := TemplatePreviewRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil || requestBody.StreamId == "" {
+		log.Println("Preview needs a stream id.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	if !slices.Contains(connector.PublisherNames(), requestBody.Platform) {
+		log.Printf("Unknown platform %v\n", requestBody.Platform)
+		response.StatusCode = "400"
+		return response
+	}
+	// Rendering doesn't need a login
+	pub, err := connector.NewOfflinePublisher(requestBody.Platform)
+	if err != nil {
+		log.Printf("Could not connect to %v: %v\n", requestBody.Platform, err)
+		response.StatusCode = "500"
+		return response
+	}
+	stream, err := n.GetStream(requestBody.StreamId)
+	if err != nil || stream.ID == "" {
+		response.StatusCode = "404"
+		return response
+	}
+	user, err := n.GetTwitchUser(stream.UserID)
+	if err != nil {
+		log.Printf("Could not find user record for %v\n", stream.UserID)
+		response.StatusCode = "500"
+		return response
+	}
+	category, err := n.GetCategoryByName(stream.GameName)
+	if err != nil {
+		log.Printf("Error looking for category %v in table: %v\n", stream.GameName, err)
+		response.StatusCode = "500"
+		return response
+	}
+	if requestBody.CategoryId != "" {
+		category, err = n.GetCategory(requestBody.CategoryId)
+		if err != nil || category.Id == "" {
+			response.StatusCode = "404"
+			return response
+		}
+	}
+	currentEvent, _ := n.GetCurrentEvent(1)
+
+	var tmpl *nosqldb.AnnouncementTemplateDatum
+	if requestBody.Template != "" {
+		tmpl = &nosqldb.AnnouncementTemplateDatum{Platform: pub.Name(), Template: requestBody.Template}
+	} else {
+		templates, err := n.GetAnnouncementTemplates()
+		if err != nil {
+			log.Printf("Could not retrieve announcement templates: %v\n", err)
+			response.StatusCode = "500"
+			return response
+		}
+		tmpl = nosqldb.FindAnnouncementTemplate(templates, pub.Name(), category.Id)
+	}
+
+	respBody := TemplatePreviewBody{Platform: pub.Name()}
+	if tmpl != nil {
+		respBody.TemplateId = tmpl.Id
+	}
+	data := connector.NewAnnouncementData(pub, user, stream, category, currentEvent)
+	respBody.Message, err = connector.FormatStreamAnnouncement(pub, tmpl, data)
+	if err != nil {
+		respBody.Error = err.Error()
+	}
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Template.Post")
+	return response
+}
+
+func (v *TemplateView) Delete(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Template.Delete")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	before, err := n.GetAnnouncementTemplate(route.Path[2])
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	if before.Id == "" {
+		response.StatusCode = "404"
+		return response
+	}
+
+	err = n.DeleteAnnouncementTemplate(before.Id)
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "template.delete", before.Id, before, nil)
+
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Template.Delete")
+	return response
+}
@@ -22,6 +22,10 @@ type NewTokenRequestBody struct {
 	ExpiresAt time.Time `json:"expires_at"`
 	Purpose   string    `json:"purpose"`
 	Scopes    []string  `json:"scopes"`
+	// Optional CIDR ranges (or bare addresses) the token may be used from
+	AllowedCidrs []string `json:"allowed_cidrs,omitempty"`
+	// Optional Origin header values the token may be used with
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
 }
 
 type OutputStaticTokenInfo struct {
@@ -32,6 +36,10 @@ type OutputStaticTokenInfo struct {
 	Revoked   bool      `json:"revoked"`
 	Scopes    string    `json:"scopes,omitempty"`
 	Purpose   string    `json:"purpose"`
+
+	AllowedCidrs             []string `json:"allowed_cidrs,omitempty"`
+	AllowedOrigins           []string `json:"allowed_origins,omitempty"`
+	nosqldb.StaticTokenUsage `tstype:",extends"`
 }
 
 type NewTokenResponseBody struct {
@@ -131,6 +139,28 @@ func (v *TokenView) Post(route *router.Route) *router.Response {
 		}
 	}
 
+	// Validate and normalize usage bounds
+	allowedCidrs := []string{}
+	for _, cidr := range requestBody.AllowedCidrs {
+		prefix, err := utility.ParseCidr(cidr)
+		if err != nil {
+			log.Printf("Invalid CIDR range %v: %v\n", cidr, err)
+			response.StatusCode = "400"
+			return response
+		}
+		allowedCidrs = append(allowedCidrs, prefix.String())
+	}
+	allowedOrigins := []string{}
+	for _, origin := range requestBody.AllowedOrigins {
+		normalized := utility.NormalizeOrigin(origin)
+		if normalized == "" {
+			log.Printf("Invalid origin %v\n", origin)
+			response.StatusCode = "400"
+			return response
+		}
+		allowedOrigins = append(allowedOrigins, normalized)
+	}
+
 	// Instantiate DynamoDB
 	n, err := nosqldb.NewClient()
 	if err != nil {
@@ -149,6 +179,8 @@ func (v *TokenView) Post(route *router.Route) *router.Response {
 	static.Revoked = false
 	static.SecretKey = utility.GenerateRandomHex(sha256.BlockSize)
 	static.Scopes = strings.Join(validScopes, " ")
+	static.AllowedCidrs = allowedCidrs
+	static.AllowedOrigins = allowedOrigins
 
 	err = n.PutStaticToken(&static)
 	if err != nil {
@@ -169,6 +201,7 @@ func (v *TokenView) Post(route *router.Route) *router.Response {
 
 	staticBytes, _ := json.Marshal(static)
 	json.Unmarshal(staticBytes, &output)
+	recordAudit(route, n, "token.create", static.Id, nil, output.OutputStaticTokenInfo)
 	output.Token = jwt
 
 	outBytes, err := json.Marshal(output)
@@ -207,6 +240,7 @@ func (v *TokenView) Delete(route *router.Route) *router.Response {
 		}
 
 		log.Printf("Revoking static token for ID: %v\n", route.Path[2])
+		before := staticTokenInfo(token)
 		token.Revoked = true
 
 		err = n.PutStaticToken(token)
@@ -215,6 +249,7 @@ func (v *TokenView) Delete(route *router.Route) *router.Response {
 			response.StatusCode = "500"
 			return response
 		}
+		recordAudit(route, n, "token.revoke", token.Id, before, staticTokenInfo(token))
 
 	} else {
 		log.Println("No ID specified.")
@@ -226,3 +261,11 @@ func (v *TokenView) Delete(route *router.Route) *router.Response {
 	log.Println("Exited route: Admin.Token.Delete")
 	return response
 }
+
+// Strips secret material from a token datum for logging or output
+func staticTokenInfo(static *nosqldb.StaticTokenDatum) *OutputStaticTokenInfo {
+	info := OutputStaticTokenInfo{}
+	staticBytes, _ := json.Marshal(static)
+	json.Unmarshal(staticBytes, &info)
+	return &info
+}
@@ -0,0 +1,347 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Most deliveries listed for a webhook at once
+	webhookDeliveryLimit = 100
+)
+
+// Outbound webhooks which tell other community tools about stream events
+type WebhookView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type WebhookRequestBody struct {
+	Name     string   `json:"name"`
+	Url      string   `json:"url"`
+	Events   []string `json:"events"`
+	Disabled bool     `json:"disabled"`
+	// Replaces the signing secret on update
+	RotateSecret bool `json:"rotate_secret,omitempty"`
+}
+
+type OutputWebhookInfo struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatorId string    `json:"creator_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Disabled  bool      `json:"disabled"`
+}
+
+type WebhookResponseBody struct {
+	OutputWebhookInfo `tstype:",extends,required"`
+	// Only returned on creation or when the secret is rotated
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*OutputWebhookInfo `json:"data"`
+}
+
+type WebhookDeliveryBody struct {
+	router.GenericBodyDataFlat `tstype:",extends,required"`
+	Data                       []*nosqldb.WebhookDeliveryDatum `json:"data"`
+}
+
+func NewWebhookView() *WebhookView {
+	c := WebhookView{}
+	return &c
+}
+
+func (v *WebhookView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Lists webhooks, or with admin/webhook/<id>/deliveries, the latest
+// deliveries to one of them
+func (v *WebhookView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Webhook.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	if len(route.Path) == 4 && route.Path[3] == "deliveries" {
+		if uuid.Validate(route.Path[2]) != nil {
+			response.StatusCode = "400"
+			return response
+		}
+		deliveries, err := n.GetWebhookDeliveries(route.Path[2])
+		if err != nil {
+			log.Printf("Could not retrieve webhook deliveries: %v\n", err)
+			response.StatusCode = "500"
+			return response
+		}
+		if len(deliveries) > webhookDeliveryLimit {
+			deliveries = deliveries[:webhookDeliveryLimit]
+		}
+
+		respBody := WebhookDeliveryBody{Data: deliveries}
+		respBody.Count = len(deliveries)
+		bodyBytes, _ := json.Marshal(respBody)
+
+		response.Body = string(bodyBytes)
+		response.StatusCode = "200"
+		log.Println("Exited route: Admin.Webhook.Get")
+		return response
+	}
+
+	webhooks, err := n.GetWebhooks()
+	if err != nil {
+		log.Println("Could not retrieve webhooks from db.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	respBody := WebhookBody{}
+	respBody.Data = []*OutputWebhookInfo{}
+	for _, webhook := range webhooks {
+		if len(route.Path) > 2 && webhook.Id != route.Path[2] {
+			continue
+		}
+		respBody.Data = append(respBody.Data, webhookInfo(webhook))
+	}
+	respBody.Count = len(respBody.Data)
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Webhook.Get")
+	return response
+}
+
+func (v *WebhookView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Webhook.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	claims := route.Router.Event.Claims
+
+	requestBody := WebhookRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if !validWebhookRequest(&requestBody) {
+		log.Println("Webhooks need a name, a valid URL and known events.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	webhook := nosqldb.WebhookDatum{
+		Id:        uuid.NewString(),
+		Name:      requestBody.Name,
+		Url:       requestBody.Url,
+		Events:    requestBody.Events,
+		CreatorId: claims["sub"].(string),
+		CreatedAt: time.Now(),
+		Disabled:  requestBody.Disabled,
+		Secret:    utility.GenerateRandomHex(32),
+	}
+	err = n.PutWebhook(&webhook)
+	if err != nil {
+		log.Printf("Could not write webhook to table: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "webhook.create", webhook.Id, nil, webhookInfo(&webhook))
+
+	output := WebhookResponseBody{
+		OutputWebhookInfo: *webhookInfo(&webhook),
+		Secret:            webhook.Secret,
+	}
+	outBytes, _ := json.Marshal(output)
+
+	response.Body = string(outBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Webhook.Post")
+	return response
+}
+
+// Updates name, URL, events or disabled state, and rotates the secret if
+// asked to
+func (v *WebhookView) Put(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Webhook.Put")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	requestBody := WebhookRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &requestBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if !validWebhookRequest(&requestBody) {
+		log.Println("Webhooks need a name, a valid URL and known events.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	webhook, err := n.GetWebhook(route.Path[2])
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	if webhook.Id == "" {
+		response.StatusCode = "404"
+		return response
+	}
+	before := webhookInfo(webhook)
+
+	webhook.Name = requestBody.Name
+	webhook.Url = requestBody.Url
+	webhook.Events = requestBody.Events
+	webhook.Disabled = requestBody.Disabled
+	if requestBody.RotateSecret {
+		webhook.Secret = utility.GenerateRandomHex(32)
+	}
+	err = n.PutWebhook(webhook)
+	if err != nil {
+		log.Printf("Could not write webhook to table: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "webhook.update", webhook.Id, before, webhookInfo(webhook))
+	if requestBody.RotateSecret {
+		recordAudit(route, n, "webhook.rotate_secret", webhook.Id, nil, nil)
+	}
+
+	output := WebhookResponseBody{OutputWebhookInfo: *webhookInfo(webhook)}
+	if requestBody.RotateSecret {
+		output.Secret = webhook.Secret
+	}
+	outBytes, _ := json.Marshal(output)
+
+	response.Body = string(outBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Webhook.Put")
+	return response
+}
+
+// Removes a webhook. Its delivery log is kept; queued deliveries are
+// dropped when they next come up.
+func (v *WebhookView) Delete(route *router.Route) *router.Response {
+	log.Println("Entered route: Admin.Webhook.Delete")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) != 3 {
+		log.Println("No ID specified.")
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	webhook, err := n.GetWebhook(route.Path[2])
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	if webhook.Id == "" {
+		response.StatusCode = "404"
+		return response
+	}
+
+	err = n.DeleteWebhook(webhook.Id)
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	recordAudit(route, n, "webhook.delete", webhook.Id, webhookInfo(webhook), nil)
+
+	response.StatusCode = "200"
+	log.Println("Exited route: Admin.Webhook.Delete")
+	return response
+}
+
+// Webhook URLs must be absolute https URLs, or http on localhost for
+// development, and at least one known event is required
+func validWebhookRequest(body *WebhookRequestBody) bool {
+	if body.Name == "" || len(body.Events) == 0 {
+		return false
+	}
+	for _, event := range body.Events {
+		if !slices.Contains(nosqldb.WebhookEvents, event) {
+			return false
+		}
+	}
+
+	u, err := url.Parse(body.Url)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "https" || (u.Scheme == "http" && u.Hostname() == "localhost")
+}
+
+// Strips the secret from a webhook datum for logging or output
+func webhookInfo(webhook *nosqldb.WebhookDatum) *OutputWebhookInfo {
+	info := OutputWebhookInfo{}
+	webhookBytes, _ := json.Marshal(webhook)
+	json.Unmarshal(webhookBytes, &info)
+	return &info
+}
@@ -1,11 +1,15 @@
 package auth
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"shrampybot/config"
 	"shrampybot/connector/discord"
 	"shrampybot/router"
+	"shrampybot/utility"
 	"shrampybot/utility/nosqldb"
 	"strings"
 	"time"
@@ -42,9 +46,36 @@ func AuthController(route *router.Route) *router.Response {
 		// Validate discord oAuth and produce new access & refresh tokens
 		c := NewValidateView()
 		return c.CallMethod(route)
+	case "validate_twitch":
+		// Validate twitch oAuth and produce new access & refresh tokens
+		c := NewValidateTwitchView()
+		return c.CallMethod(route)
 	case "self":
 		c := NewSelfView()
 		return c.CallMethod(route)
+	case "state":
+		// Issue a signed OAuth state with PKCE challenge
+		c := NewStateView()
+		return c.CallMethod(route)
+	case ".well-known":
+		// OIDC discovery metadata
+		c := NewDiscoveryView()
+		return c.CallMethod(route)
+	case "jwks":
+		// Public keys for verifying OIDC tokens
+		c := NewJWKSView()
+		return c.CallMethod(route)
+	case "authorize":
+		// Issue an OIDC authorization code for a logged in user
+		c := NewAuthorizeView()
+		return c.CallMethod(route)
+	case "token":
+		// Redeem an OIDC authorization code
+		c := NewOIDCTokenView()
+		return c.CallMethod(route)
+	case "userinfo":
+		c := NewUserinfoView()
+		return c.CallMethod(route)
 	}
 
 	return resp
@@ -142,6 +173,162 @@ func validateRefreshToken(refreshToken string) *jwt.Token {
 	return token
 }
 
+// Determines the scopes for a JWT subject, whichever provider it logged in with
+func scopesForSubject(sub string, n *nosqldb.NoSqlDb) ([]string, error) {
+	twitchId, isTwitch := utility.TwitchIdFromSubject(sub)
+	if isTwitch {
+		tu, err := n.GetTwitchUser(twitchId)
+		if err != nil {
+			return []string{}, err
+		}
+		return twitchScopesForUser(tu)
+	}
+
+	// Connect to discord with GSG bot credentials
+	dc, err := discord.NewBotClient()
+	if err != nil {
+		return []string{}, err
+	}
+	return dc.LocalScopesFromMembership(sub)
+}
+
+// Twitch logins are only available to active members of the team
+func twitchScopesForUser(tu *nosqldb.TwitchUserDatum) ([]string, error) {
+	if tu == nil || tu.ID == "" {
+		return []string{}, errors.New("no twitch user record found")
+	}
+	if !tu.ShrampybotActive {
+		return []string{}, fmt.Errorf("twitch user %v is not an active team member", tu.Login)
+	}
+	return []string{"login", "self", "gsg:streamer"}, nil
+}
+
+const (
+	oAuthStateCookieName = "OAuthStateNonce"
+)
+
+// The cookie that ties a state to the browser it was issued to, so that a
+// state can't be handed to somebody else to finish a login with
+func newOAuthStateCookie(nonce string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     oAuthStateCookieName,
+		Value:    nonce,
+		HttpOnly: true,
+		// The frontend and API are on different sites, so Lax would keep
+		// the cookie from being sent with the validate request
+		SameSite:    http.SameSiteNoneMode,
+		Secure:      true,
+		Partitioned: true,
+		Expires:     expires,
+	}
+}
+
+// The state nonce from a request's cookies, or blank if there isn't one
+func oAuthStateNonce(cookieHeader string) string {
+	cookies, err := http.ParseCookie(cookieHeader)
+	if err != nil {
+		return ""
+	}
+	for _, c := range cookies {
+		if c.Name == oAuthStateCookieName {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func generateStateToken(state *nosqldb.OAuthStateDatum) (string, error) {
+	stateTokenRaw := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":      config.BotName,
+		"aud":      "oauth_state",
+		"iat":      state.CreatedAt.Unix(),
+		"exp":      state.ExpiresAt.Unix(),
+		"jti":      state.Id,
+		"provider": state.Provider,
+	})
+	return stateTokenRaw.SignedString([]byte(config.OAuthStateSecret))
+}
+
+// Checks the signature and expiry of a state value issued by StateView, and
+// that it came back from the browser it was issued to, and returns the
+// stored state. The stored state is removed so it can't be replayed.
+func consumeStateToken(stateToken string, nonce string, provider string, n *nosqldb.NoSqlDb) (*nosqldb.OAuthStateDatum, error) {
+	if config.OAuthStateSecret == "" {
+		return nil, errors.New("no oauth state secret configured")
+	}
+
+	token, err := jwt.Parse(stateToken, func(token *jwt.Token) (interface{}, error) {
+		_, res := token.Method.(*jwt.SigningMethodHMAC)
+		if !res {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(config.OAuthStateSecret), nil
+	},
+		jwt.WithIssuer(config.BotName),
+		jwt.WithAudience("oauth_state"),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, res := token.Claims.(jwt.MapClaims)
+	if !res {
+		return nil, errors.New("could not retrieve state claims")
+	}
+	if claims["provider"] != provider {
+		return nil, fmt.Errorf("state was not issued for provider %v", provider)
+	}
+	jti, _ := claims["jti"].(string)
+
+	state, err := n.GetOAuthState(jti)
+	if err != nil {
+		return nil, err
+	}
+	if state.Id == "" || state.Id != jti {
+		return nil, errors.New("state not found or already used")
+	}
+	// Single use, regardless of what happens next
+	n.DeleteOAuthState(state.Id)
+
+	if time.Now().After(state.ExpiresAt) {
+		return nil, errors.New("state has expired")
+	}
+	err = checkStateNonce(state, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// A state only counts when it comes back with the cookie nonce it was
+// issued with
+func checkStateNonce(state *nosqldb.OAuthStateDatum, nonce string) error {
+	if nonce == "" || state.NonceHash == "" ||
+		subtle.ConstantTimeCompare([]byte(utility.HashSecret(nonce)), []byte(state.NonceHash)) != 1 {
+		return errors.New("state was issued to a different browser")
+	}
+	return nil
+}
+
+// Only frontend base URLs on the configured allowlist may receive OAuth redirects
+func redirectBaseAllowed(base string) bool {
+	if base == "" {
+		return false
+	}
+	for _, allowed := range strings.Split(config.OAuthRedirectAllowlist, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "" {
+			continue
+		}
+		if strings.TrimSuffix(allowed, "/") == strings.TrimSuffix(base, "/") {
+			return true
+		}
+	}
+	return false
+}
+
 func mapDiscordConnections(discordId string, discordUsername string, n *nosqldb.NoSqlDb, d *discord.OAuthClient) error {
 	// Look up user connections and map to Twitch table if an entry exists
 	connections, err := d.GetConnections()
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"shrampybot/config"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcCodeLifetime     = 5 * time.Minute
+	oidcTokenLifetime    = time.Hour
+	oidcUserinfoAudience = "oidc_userinfo"
+)
+
+var (
+	oidcSupportedScopes = []string{"openid", "profile"}
+)
+
+// Public half of an OIDC signing key, as published on the JWKS endpoint
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Linked identities for a ShrampyBot user, as carried in ID tokens
+type OIDCDiscordIdentity struct {
+	Id       string `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+type OIDCTwitchIdentity struct {
+	Id          string `json:"id"`
+	Login       string `json:"login,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+type OIDCIdentities struct {
+	Discord *OIDCDiscordIdentity `json:"discord,omitempty"`
+	Twitch  *OIDCTwitchIdentity  `json:"twitch,omitempty"`
+}
+
+// Returns the current signing key, creating one the first time around
+func currentOIDCKey(n *nosqldb.NoSqlDb) (*rsa.PrivateKey, string, error) {
+	keys, err := n.GetOIDCKeys()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, key := range keys {
+		if key.Retired {
+			continue
+		}
+		privateKey, err := parseOIDCKey(key)
+		if err != nil {
+			return nil, "", err
+		}
+		return privateKey, key.Id, nil
+	}
+
+	log.Println("No OIDC signing key available; generating a new one.")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, "", err
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, "", err
+	}
+	key := nosqldb.OIDCKeyDatum{
+		Id:         uuid.NewString(),
+		Algorithm:  "RS256",
+		CreatedAt:  time.Now(),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})),
+	}
+	err = n.PutOIDCKey(&key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return privateKey, key.Id, nil
+}
+
+func parseOIDCKey(key *nosqldb.OIDCKeyDatum) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("could not decode oidc key %v", key.Id)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("oidc key %v is not an rsa key", key.Id)
+	}
+	return privateKey, nil
+}
+
+// Publishes every key, including retired ones, so that tokens signed
+// before a rotation can still be verified.
+func oidcKeySet(n *nosqldb.NoSqlDb) (*JWKSet, error) {
+	keys, err := n.GetOIDCKeys()
+	if err != nil {
+		return &JWKSet{}, err
+	}
+
+	output := JWKSet{Keys: []JWK{}}
+	for _, key := range keys {
+		privateKey, err := parseOIDCKey(key)
+		if err != nil {
+			log.Printf("Skipping unreadable OIDC key: %v\n", err)
+			continue
+		}
+		output.Keys = append(output.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: key.Algorithm,
+			Kid: key.Id,
+			N:   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+		})
+	}
+
+	return &output, nil
+}
+
+func signOIDCToken(claims jwt.MapClaims, n *nosqldb.NoSqlDb) (string, error) {
+	privateKey, kid, err := currentOIDCKey(n)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(privateKey)
+}
+
+// Verifies a token we signed with one of our OIDC keys
+func parseOIDCToken(tokenString string, audience string, n *nosqldb.NoSqlDb) (jwt.MapClaims, error) {
+	keys, err := n.GetOIDCKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, key := range keys {
+			if key.Id == kid {
+				privateKey, err := parseOIDCKey(key)
+				if err != nil {
+					return nil, err
+				}
+				return &privateKey.PublicKey, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown signing key: %v", kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(config.OIDCIssuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("could not retrieve token claims")
+	}
+	return claims, nil
+}
+
+// Looks up the Discord and Twitch accounts behind a subject, whichever one
+// they logged in with.
+func oidcIdentitiesForSubject(sub string, n *nosqldb.NoSqlDb) (*OIDCIdentities, error) {
+	output := OIDCIdentities{}
+	var tu *nosqldb.TwitchUserDatum
+	var err error
+
+	twitchId, isTwitch := utility.TwitchIdFromSubject(sub)
+	if isTwitch {
+		tu, err = n.GetTwitchUser(twitchId)
+		if err != nil {
+			return &output, err
+		}
+		if tu.DiscordUserId != "" {
+			output.Discord = &OIDCDiscordIdentity{
+				Id:       tu.DiscordUserId,
+				Username: tu.DiscordUsername,
+			}
+		}
+	} else {
+		dOAuth, err := n.GetDiscordOAuth(sub)
+		if err != nil {
+			return &output, err
+		}
+		output.Discord = &OIDCDiscordIdentity{
+			Id:       sub,
+			Username: dOAuth.Username,
+		}
+		tu, err = n.GetTwitchUserByDiscordId(sub)
+		if err != nil {
+			return &output, err
+		}
+	}
+	if tu.ID != "" {
+		output.Twitch = &OIDCTwitchIdentity{
+			Id:          tu.ID,
+			Login:       tu.Login,
+			DisplayName: tu.DisplayName,
+		}
+	}
+
+	return &output, nil
+}
+
+func oidcClientAllowsRedirect(client *nosqldb.OIDCClientDatum, redirectUri string) bool {
+	for _, uri := range client.RedirectUris {
+		// Exact matches only, per the OAuth 2.0 security recommendations
+		if uri == redirectUri {
+			return true
+		}
+	}
+	return false
+}
+
+// Drops anything we don't support from a requested scope string
+func filterOIDCScope(scope string) (string, bool) {
+	output := []string{}
+	hasOpenId := false
+	for _, s := range strings.Fields(scope) {
+		for _, supported := range oidcSupportedScopes {
+			if s == supported {
+				output = append(output, s)
+			}
+		}
+		if s == "openid" {
+			hasOpenId = true
+		}
+	}
+	return strings.Join(output, " "), hasOpenId
+}
+
+// The user's scopes as shared with OIDC clients. Admin scopes only mean
+// something to this API, so they're left out.
+func oidcUserScopes(scopes []string) []string {
+	output := []string{}
+	for _, s := range scopes {
+		if s == "admin" || strings.HasPrefix(s, "admin:") {
+			continue
+		}
+		output = append(output, s)
+	}
+	return output
+}
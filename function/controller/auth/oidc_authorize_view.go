@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"time"
+)
+
+// Issues OIDC authorization codes. The frontend's authorize page calls this
+// on behalf of a logged in user who has approved the client app.
+type AuthorizeView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type AuthorizeRequestBody struct {
+	ClientId            string `json:"client_id"`
+	RedirectUri         string `json:"redirect_uri"`
+	ResponseType        string `json:"response_type"`
+	Scope               string `json:"scope"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+}
+
+type AuthorizeResponseBody struct {
+	ClientName string `json:"client_name"`
+	// Where the frontend should send the user next
+	RedirectTo string `json:"redirect_to"`
+}
+
+func NewAuthorizeView() *AuthorizeView {
+	c := AuthorizeView{}
+	return &c
+}
+
+func (v *AuthorizeView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *AuthorizeView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.Authorize.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if !route.Router.Event.CheckAuthorizationJWT() {
+		log.Println("Failed JWT Auth check.")
+		response.StatusCode = "401"
+		return response
+	}
+	claims := route.Router.Event.Claims
+	// Only interactive user logins can authorize client apps
+	if claims["aud"] != "access" {
+		log.Println("Static tokens cannot authorize OIDC clients.")
+		response.StatusCode = "403"
+		return response
+	}
+
+	reqBody := AuthorizeRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &reqBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+
+	client, err := n.GetOIDCClient(reqBody.ClientId)
+	if err != nil || client.Id == "" || client.Disabled {
+		log.Printf("Unknown or disabled OIDC client: %v\n", reqBody.ClientId)
+		response.StatusCode = "400"
+		return response
+	}
+	// Never redirect anywhere the client hasn't registered
+	if !oidcClientAllowsRedirect(client, reqBody.RedirectUri) {
+		log.Printf("Redirect URI %v not registered for client %v\n", reqBody.RedirectUri, client.Id)
+		response.StatusCode = "400"
+		return response
+	}
+	redirectTo, code := authorizeOIDCRequest(client, claims["sub"].(string), route.Router.Event.Scopes, &reqBody, time.Now())
+	if code != nil {
+		err = n.PutOIDCCode(code)
+		if err != nil {
+			log.Printf("Could not store OIDC code: %v\n", err)
+			response.StatusCode = "500"
+			return response
+		}
+	}
+
+	body := AuthorizeResponseBody{
+		ClientName: client.Name,
+		RedirectTo: redirectTo.String(),
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.Authorize.Post")
+	return response
+}
+
+// Works out where to send the browser back to for an authorization request
+// from a client whose redirect URI has been checked, and the code to store
+// when the request is valid
+func authorizeOIDCRequest(client *nosqldb.OIDCClientDatum, sub string, userScopes []string, reqBody *AuthorizeRequestBody, now time.Time) (*url.URL, *nosqldb.OIDCCodeDatum) {
+	var code *nosqldb.OIDCCodeDatum
+
+	redirectTo, _ := url.Parse(reqBody.RedirectUri)
+	query := redirectTo.Query()
+	if reqBody.State != "" {
+		query.Set("state", reqBody.State)
+	}
+
+	scope, hasOpenId := filterOIDCScope(reqBody.Scope)
+	if reqBody.ResponseType != "code" {
+		query.Set("error", "unsupported_response_type")
+	} else if !hasOpenId {
+		query.Set("error", "invalid_scope")
+	} else if reqBody.CodeChallenge != "" && reqBody.CodeChallengeMethod != "S256" {
+		query.Set("error", "invalid_request")
+		query.Set("error_description", "only S256 code challenges are supported")
+	} else {
+		code = &nosqldb.OIDCCodeDatum{
+			Id:                  utility.GenerateRandomHex(32),
+			ClientId:            client.Id,
+			Sub:                 sub,
+			RedirectUri:         reqBody.RedirectUri,
+			Scope:               scope,
+			UserScopes:          oidcUserScopes(userScopes),
+			Nonce:               reqBody.Nonce,
+			CodeChallenge:       reqBody.CodeChallenge,
+			CodeChallengeMethod: reqBody.CodeChallengeMethod,
+			AuthTime:            now,
+			ExpiresAt:           now.Add(oidcCodeLifetime),
+		}
+		query.Set("code", code.Id)
+	}
+	redirectTo.RawQuery = query.Encode()
+
+	return redirectTo, code
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/config"
+	"shrampybot/router"
+	"strings"
+)
+
+// OpenID Connect discovery metadata, served from
+// <issuer>/.well-known/openid-configuration
+type DiscoveryView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type DiscoveryResponseBody struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+func NewDiscoveryView() *DiscoveryView {
+	c := DiscoveryView{}
+	return &c
+}
+
+func (v *DiscoveryView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *DiscoveryView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.Discovery.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	if len(route.Path) < 3 || route.Path[2] != "openid-configuration" {
+		response.StatusCode = "404"
+		return response
+	}
+	if config.OIDCIssuer == "" {
+		log.Println("No OIDC issuer configured.")
+		response.StatusCode = "404"
+		return response
+	}
+	issuer := strings.TrimSuffix(config.OIDCIssuer, "/")
+
+	body := DiscoveryResponseBody{
+		Issuer:                            config.OIDCIssuer,
+		AuthorizationEndpoint:             config.OIDCAuthorizeUrl,
+		TokenEndpoint:                     issuer + "/token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JwksUri:                           issuer + "/jwks",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"RS256"},
+		ScopesSupported:                   oidcSupportedScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "name", "preferred_username", "scopes", "discord", "twitch"},
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.Discovery.Get")
+	return response
+}
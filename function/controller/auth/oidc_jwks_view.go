@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+)
+
+// Public keys for verifying OIDC tokens
+type JWKSView struct {
+	router.View `tstype:",extends,required"`
+}
+
+func NewJWKSView() *JWKSView {
+	c := JWKSView{}
+	return &c
+}
+
+func (v *JWKSView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *JWKSView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.JWKS.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+
+	// Make sure there's always at least one key to publish
+	_, _, err = currentOIDCKey(n)
+	if err != nil {
+		log.Printf("Could not retrieve OIDC signing key: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	keySet, err := oidcKeySet(n)
+	if err != nil {
+		log.Printf("Could not build JWKS: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	bodyBytes, _ := json.Marshal(keySet)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.JWKS.Get")
+	return response
+}
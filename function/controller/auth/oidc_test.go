@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"shrampybot/utility/nosqldb"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestAuthorizeOIDCRequest(t *testing.T) {
+	client := &nosqldb.OIDCClientDatum{Id: "client", RedirectUris: []string{"https://example.com/cb"}}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		body    AuthorizeRequestBody
+		wantErr string
+	}{
+		{"valid", AuthorizeRequestBody{ResponseType: "code", Scope: "openid profile"}, ""},
+		{"not code flow", AuthorizeRequestBody{ResponseType: "token", Scope: "openid"}, "unsupported_response_type"},
+		{"no openid", AuthorizeRequestBody{ResponseType: "code", Scope: "profile"}, "invalid_scope"},
+		{"plain challenge", AuthorizeRequestBody{ResponseType: "code", Scope: "openid", CodeChallenge: "abc", CodeChallengeMethod: "plain"}, "invalid_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.body.ClientId = client.Id
+			tt.body.RedirectUri = "https://example.com/cb"
+			tt.body.State = "xyz"
+
+			redirectTo, code := authorizeOIDCRequest(client, "user", []string{"login", "self", "admin"}, &tt.body, now)
+			assert.Equal(t, "xyz", redirectTo.Query().Get("state"))
+			assert.Equal(t, tt.wantErr, redirectTo.Query().Get("error"))
+			if tt.wantErr != "" {
+				assert.Nil(t, code)
+				return
+			}
+			assert.Equal(t, code.Id, redirectTo.Query().Get("code"))
+			assert.Equal(t, "user", code.Sub)
+			assert.Equal(t, "openid profile", code.Scope)
+			assert.Equal(t, []string{"login", "self"}, code.UserScopes)
+		})
+	}
+}
+
+// Runs codes from the authorize step through the token checks, with codes
+// being removed when they're redeemed as they are in DynamoDB
+func TestOIDCCodeFlow(t *testing.T) {
+	client := &nosqldb.OIDCClientDatum{Id: "client", RedirectUris: []string{"https://example.com/cb"}}
+	verifier := "a-verifier-long-enough-to-be-used-for-pkce-in-tests"
+
+	tests := []struct {
+		name        string
+		challenge   string
+		clientId    string
+		redirectUri string
+		verifier    string
+		redeemAt    time.Duration
+		wantDesc    string
+		wantErr     string
+	}{
+		{"valid with pkce", testChallenge(verifier), "client", "https://example.com/cb", verifier, 0, "", ""},
+		{"valid without pkce", "", "client", "https://example.com/cb", "", 0, "", ""},
+		{"pkce mismatch", testChallenge(verifier), "client", "https://example.com/cb", "something-else", 0, "code_verifier mismatch", "invalid_grant"},
+		{"pkce missing", testChallenge(verifier), "client", "https://example.com/cb", "", 0, "code_verifier mismatch", "invalid_grant"},
+		{"redirect mismatch", "", "client", "https://example.com/other", "", 0, "redirect_uri mismatch", "invalid_grant"},
+		{"other client", "", "intruder", "https://example.com/cb", "", 0, "", "invalid_grant"},
+		{"expired", "", "client", "https://example.com/cb", "", oidcCodeLifetime + time.Second, "", "invalid_grant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			body := AuthorizeRequestBody{
+				ClientId:            client.Id,
+				RedirectUri:         "https://example.com/cb",
+				ResponseType:        "code",
+				Scope:               "openid",
+				CodeChallenge:       tt.challenge,
+				CodeChallengeMethod: "S256",
+			}
+			if tt.challenge == "" {
+				body.CodeChallengeMethod = ""
+			}
+			_, code := authorizeOIDCRequest(client, "user", []string{"login"}, &body, now)
+			codes := map[string]nosqldb.OIDCCodeDatum{code.Id: *code}
+
+			redeem := func() (string, string) {
+				stored := codes[code.Id]
+				delete(codes, code.Id)
+				return checkOIDCCodeGrant(&stored, tt.clientId, tt.redirectUri, tt.verifier, now.Add(tt.redeemAt))
+			}
+
+			errCode, desc := redeem()
+			assert.Equal(t, tt.wantErr, errCode)
+			assert.Equal(t, tt.wantDesc, desc)
+
+			// A code can only be redeemed once, whether or not it worked
+			errCode, _ = redeem()
+			assert.Equal(t, "invalid_grant", errCode)
+		})
+	}
+}
+
+func TestOIDCUserScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		expected []string
+	}{
+		{"member", []string{"login", "self"}, []string{"login", "self"}},
+		{"dev", []string{"login", "self", "dev"}, []string{"login", "self", "dev"}},
+		{"admin", []string{"login", "self", "admin"}, []string{"login", "self"}},
+		{"narrow admin", []string{"login", "admin:oidc", "admin:queue"}, []string{"login"}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, oidcUserScopes(tt.scopes))
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/url"
+	"shrampybot/config"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Redeems OIDC authorization codes for ID and access tokens. Client apps
+// call this directly with a form-encoded body.
+type OIDCTokenView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type OIDCTokenResponseBody struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IdToken     string `json:"id_token"`
+	Scope       string `json:"scope"`
+}
+
+type OIDCErrorBody struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func NewOIDCTokenView() *OIDCTokenView {
+	c := OIDCTokenView{}
+	return &c
+}
+
+func (v *OIDCTokenView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *OIDCTokenView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.OIDCToken.Post")
+	response := &router.Response{}
+	// Token responses must never be cached
+	headers := router.DefaultResponseHeaders
+	headers.CacheControl = "no-store"
+	response.Headers = &headers
+
+	rawBody := route.Body
+	if route.Router.Event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(rawBody)
+		if err != nil {
+			return oidcErrorResponse(response, "400", "invalid_request", "could not decode body")
+		}
+		rawBody = string(decoded)
+	}
+	form, err := url.ParseQuery(rawBody)
+	if err != nil {
+		return oidcErrorResponse(response, "400", "invalid_request", "could not parse body")
+	}
+	if form.Get("grant_type") != "authorization_code" {
+		return oidcErrorResponse(response, "400", "unsupported_grant_type", "")
+	}
+
+	// Accept client_secret_basic or client_secret_post
+	clientId, clientSecret := form.Get("client_id"), form.Get("client_secret")
+	if basicId, basicSecret, ok := parseBasicAuth(route.Router.Event.Headers.Authorization); ok {
+		clientId, clientSecret = basicId, basicSecret
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+
+	client, err := n.GetOIDCClient(clientId)
+	if err != nil || client.Id == "" || client.Disabled {
+		return oidcErrorResponse(response, "401", "invalid_client", "")
+	}
+	if subtle.ConstantTimeCompare([]byte(utility.HashSecret(clientSecret)), []byte(client.SecretHash)) == 0 {
+		log.Printf("Bad client secret for OIDC client %v\n", client.Id)
+		return oidcErrorResponse(response, "401", "invalid_client", "")
+	}
+
+	code, err := n.ConsumeOIDCCode(form.Get("code"))
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+	errorCode, description := checkOIDCCodeGrant(code, client.Id, form.Get("redirect_uri"), form.Get("code_verifier"), time.Now())
+	if errorCode != "" {
+		return oidcErrorResponse(response, "400", errorCode, description)
+	}
+
+	identities, err := oidcIdentitiesForSubject(code.Sub, n)
+	if err != nil {
+		log.Printf("Could not look up identities for %v: %v\n", code.Sub, err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(oidcTokenLifetime)
+	idClaims := jwt.MapClaims{
+		"iss":       config.OIDCIssuer,
+		"sub":       code.Sub,
+		"aud":       client.Id,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+		"auth_time": code.AuthTime.Unix(),
+		"scopes":    strings.Join(code.UserScopes, " "),
+	}
+	if code.Nonce != "" {
+		idClaims["nonce"] = code.Nonce
+	}
+	addIdentityClaims(idClaims, identities, code.Scope)
+
+	idToken, err := signOIDCToken(idClaims, n)
+	if err != nil {
+		log.Printf("Could not sign ID token: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	accessToken, err := signOIDCToken(jwt.MapClaims{
+		"iss":       config.OIDCIssuer,
+		"sub":       code.Sub,
+		"aud":       oidcUserinfoAudience,
+		"client_id": client.Id,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+		"jti":       uuid.NewString(),
+		"scope":     code.Scope,
+		"scopes":    strings.Join(code.UserScopes, " "),
+	}, n)
+	if err != nil {
+		log.Printf("Could not sign OIDC access token: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	body := OIDCTokenResponseBody{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oidcTokenLifetime.Seconds()),
+		IdToken:     idToken,
+		Scope:       code.Scope,
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.OIDCToken.Post")
+	return response
+}
+
+// Checks a code redeemed at the token endpoint against the request. A code
+// that's already been redeemed comes back blank. Returns the OAuth error
+// code and description when the grant isn't valid.
+func checkOIDCCodeGrant(code *nosqldb.OIDCCodeDatum, clientId string, redirectUri string, codeVerifier string, now time.Time) (string, string) {
+	if code.Id == "" || code.ClientId != clientId || now.After(code.ExpiresAt) {
+		return "invalid_grant", ""
+	}
+	if code.RedirectUri != redirectUri {
+		return "invalid_grant", "redirect_uri mismatch"
+	}
+	if code.CodeChallenge != "" {
+		sum := sha256.Sum256([]byte(codeVerifier))
+		challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) == 0 {
+			return "invalid_grant", "code_verifier mismatch"
+		}
+	}
+	return "", ""
+}
+
+// Identity claims shared by ID tokens and the userinfo endpoint
+func addIdentityClaims(claims jwt.MapClaims, identities *OIDCIdentities, scope string) {
+	if identities.Discord != nil {
+		claims["discord"] = identities.Discord
+	}
+	if identities.Twitch != nil {
+		claims["twitch"] = identities.Twitch
+	}
+	if !strings.Contains(" "+scope+" ", " profile ") {
+		return
+	}
+	if identities.Twitch != nil {
+		claims["preferred_username"] = identities.Twitch.Login
+		claims["name"] = identities.Twitch.DisplayName
+	} else if identities.Discord != nil {
+		claims["preferred_username"] = identities.Discord.Username
+		claims["name"] = identities.Discord.Username
+	}
+}
+
+func parseBasicAuth(header string) (string, string, bool) {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "basic" {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+	// Credentials are form-encoded before being joined, per RFC 6749
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	return id, secret, true
+}
+
+func oidcErrorResponse(response *router.Response, statusCode string, errorCode string, description string) *router.Response {
+	bodyBytes, _ := json.Marshal(OIDCErrorBody{
+		Error:            errorCode,
+		ErrorDescription: description,
+	})
+	response.Body = string(bodyBytes)
+	response.StatusCode = statusCode
+	return response
+}
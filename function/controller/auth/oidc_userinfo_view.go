@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/router"
+	"shrampybot/utility/nosqldb"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Standard OIDC userinfo endpoint, authorized with an OIDC access token
+type UserinfoView struct {
+	router.View `tstype:",extends,required"`
+}
+
+func NewUserinfoView() *UserinfoView {
+	c := UserinfoView{}
+	return &c
+}
+
+func (v *UserinfoView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *UserinfoView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.Userinfo.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	bearer := strings.SplitN(route.Router.Event.Headers.Authorization, " ", 2)
+	if len(bearer) < 2 || strings.ToLower(bearer[0]) != "bearer" {
+		response.StatusCode = "401"
+		return response
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+
+	claims, err := parseOIDCToken(bearer[1], oidcUserinfoAudience, n)
+	if err != nil {
+		log.Printf("Invalid OIDC access token: %v\n", err)
+		response.StatusCode = "401"
+		return response
+	}
+	sub, _ := claims["sub"].(string)
+	scope, _ := claims["scope"].(string)
+
+	identities, err := oidcIdentitiesForSubject(sub, n)
+	if err != nil {
+		log.Printf("Could not look up identities for %v: %v\n", sub, err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	body := jwt.MapClaims{
+		"sub":    sub,
+		"scopes": claims["scopes"],
+	}
+	addIdentityClaims(body, identities, scope)
+	bodyBytes, _ := json.Marshal(body)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.Userinfo.Get")
+	return response
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"shrampybot/connector/discord"
 	"shrampybot/router"
 	"shrampybot/utility/nosqldb"
 	"time"
@@ -102,15 +101,8 @@ func (v *RefreshView) Post(route *router.Route) *router.Response {
 		return response
 	}
 
-	// Connect to discord with GSG bot credentials
-	dc, err := discord.NewBotClient()
-	if err != nil {
-		response.StatusCode = "500"
-		return response
-	}
-
 	// Determine JWT scopes
-	scopes, err := dc.LocalScopesFromMembership(claims["sub"].(string))
+	scopes, err := scopesForSubject(claims["sub"].(string), n)
 	if err != nil {
 		log.Printf("No scopes could be built for user %v: %v\n", claims["sub"].(string), err)
 		response.StatusCode = "403"
@@ -5,6 +5,7 @@ import (
 	"log"
 	"shrampybot/connector/discord"
 	"shrampybot/router"
+	"shrampybot/utility"
 	"shrampybot/utility/nosqldb"
 
 	"github.com/bwmarrin/discordgo"
@@ -18,6 +19,8 @@ type SelfResponseBody struct {
 	discordgo.User `tstype:",extends,required"`
 	Member         *discordgo.Member           `json:"member,omitempty" tstype:"discordgo.Member"`
 	Connections    []*discordgo.UserConnection `json:"connections,omitempty" tstype:"discordgo.UserConnection"`
+	// Set instead of the Discord fields for users who logged in with Twitch
+	TwitchUser *nosqldb.TwitchUserDatum `json:"twitch_user,omitempty"`
 }
 
 func NewSelfView() *SelfView {
@@ -64,6 +67,28 @@ func (v *SelfView) Get(route *router.Route) *router.Response {
 		return response
 	}
 
+	// Twitch logins have no Discord identity to report
+	if twitchId, isTwitch := utility.TwitchIdFromSubject(claims["sub"].(string)); isTwitch {
+		tu, err := n.GetTwitchUser(twitchId)
+		if err != nil || tu.ID == "" {
+			log.Printf("Could not get twitch user record for %v\n", twitchId)
+			response.StatusCode = "500"
+			return response
+		}
+		body := SelfResponseBody{
+			TwitchUser: tu,
+		}
+		body.ID = claims["sub"].(string)
+		body.Username = tu.Login
+		body.GlobalName = tu.DisplayName
+		bodyBytes, _ := json.Marshal(body)
+		response.Body = string(bodyBytes)
+
+		response.StatusCode = "200"
+		log.Println("Exiting route: Auth.Self.Get")
+		return response
+	}
+
 	dOAuth, err := n.GetDiscordOAuth(claims["sub"].(string))
 	if err != nil {
 		log.Printf("Could not get Discord OAuth record")
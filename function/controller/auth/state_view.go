@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/config"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// How long a login has to complete once a state has been issued
+	oAuthStateLifetime = 10 * time.Minute
+)
+
+var (
+	// Paths on the frontend which receive the OAuth code, per provider
+	oAuthRedirectPaths = map[string]string{
+		"discord": "shrampybot/auth/validate_oauth",
+		"twitch":  "shrampybot/auth/validate_twitch",
+	}
+)
+
+type StateView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type StateRequestBody struct {
+	Provider     string `json:"provider,omitempty"`
+	RedirectBase string `json:"redirect_base"`
+	RedirectPath string `json:"redirect_path,omitempty"`
+}
+
+type StateResponseBody struct {
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+	RedirectUri         string `json:"redirect_uri"`
+	// Client ID to authorize against, where the frontend doesn't already know it
+	ClientId  string    `json:"client_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewStateView() *StateView {
+	c := StateView{}
+	return &c
+}
+
+func (v *StateView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// Issues a signed, short-lived state value and PKCE challenge for the
+// frontend to include in its authorization request.
+func (v *StateView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.State.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	reqBody := StateRequestBody{}
+	err := json.Unmarshal([]byte(route.Body), &reqBody)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+	if reqBody.Provider == "" {
+		reqBody.Provider = "discord"
+	}
+	redirectPath, ok := oAuthRedirectPaths[reqBody.Provider]
+	if !ok {
+		log.Printf("Unsupported OAuth provider: %v\n", reqBody.Provider)
+		response.StatusCode = "400"
+		return response
+	}
+
+	if !redirectBaseAllowed(reqBody.RedirectBase) {
+		log.Printf("Redirect base %v is not on the allowlist.\n", reqBody.RedirectBase)
+		response.StatusCode = "403"
+		return response
+	}
+	// Only allow relative redirects within the frontend after login
+	if !strings.HasPrefix(reqBody.RedirectPath, "/") || strings.HasPrefix(reqBody.RedirectPath, "//") {
+		reqBody.RedirectPath = "/streams"
+	}
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+
+	state := nosqldb.OAuthStateDatum{
+		Id:           uuid.NewString(),
+		Provider:     reqBody.Provider,
+		RedirectUri:  strings.TrimSuffix(reqBody.RedirectBase, "/") + "/" + redirectPath,
+		RedirectPath: reqBody.RedirectPath,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(oAuthStateLifetime),
+	}
+	var codeChallenge string
+	state.CodeVerifier, codeChallenge = utility.GeneratePKCEPair()
+	nonce := utility.GenerateRandomHex(32)
+	state.NonceHash = utility.HashSecret(nonce)
+
+	err = n.PutOAuthState(&state)
+	if err != nil {
+		log.Printf("Could not store OAuth state: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	stateToken, err := generateStateToken(&state)
+	if err != nil {
+		log.Printf("Could not sign OAuth state: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	body := StateResponseBody{
+		State:       stateToken,
+		RedirectUri: state.RedirectUri,
+		ExpiresAt:   state.ExpiresAt,
+	}
+	// Twitch doesn't support PKCE, so it relies on the state alone
+	if state.Provider == "twitch" {
+		body.ClientId = config.TwitchApiKey
+	} else {
+		body.CodeChallenge = codeChallenge
+		body.CodeChallengeMethod = "S256"
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	// A copy, so that the cookie isn't sent with every later response
+	headers := router.DefaultResponseHeaders
+	headers.SetCookie = newOAuthStateCookie(nonce, state.ExpiresAt).String()
+	response.Headers = &headers
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.State.Post")
+	return response
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"net/http"
+	"shrampybot/connector/twitch"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Login through Twitch, for team members without a Discord identity
+type ValidateTwitchView struct {
+	router.View `tstype:",extends,required"`
+}
+
+func NewValidateTwitchView() *ValidateTwitchView {
+	c := ValidateTwitchView{}
+	return &c
+}
+
+func (v *ValidateTwitchView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+func (v *ValidateTwitchView) Post(route *router.Route) *router.Response {
+	log.Println("Entered route: Auth.ValidateTwitch.Post")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	// Instantiate DynamoDB
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		response.StatusCode = "500"
+		return response
+	}
+
+	reqBody := ValidateRequestBody{}
+	json.Unmarshal([]byte(route.Body), &reqBody)
+
+	// Twitch has no PKCE, so the state and its cookie are all that stop a
+	// login being completed in somebody else's browser
+	nonce := oAuthStateNonce(route.Router.Event.Headers.Cookie)
+	state, err := consumeStateToken(reqBody.State, nonce, "twitch", n)
+	if err != nil {
+		log.Printf("Invalid OAuth state: %v\n", err)
+		response.StatusCode = "403"
+		return response
+	}
+
+	t, err := twitch.NewOAuthClientFromCode(reqBody.Code, state.RedirectUri)
+	if err != nil {
+		log.Println("Could not complete Twitch token exchange.")
+		response.StatusCode = "403"
+		return response
+	}
+	user, err := t.GetSelf()
+	if err != nil || user.ID == "" {
+		log.Println("Could not retrieve Twitch user with new OAuth credentials.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	// Only users we already track, and who are active, may log in this way
+	tu, err := n.GetTwitchUser(user.ID)
+	if err != nil {
+		log.Printf("Could not retrieve twitch user record for %v: %v\n", user.Login, err)
+		response.StatusCode = "500"
+		return response
+	}
+	scopes, err := twitchScopesForUser(tu)
+	if err != nil {
+		log.Printf("No scopes could be built for twitch user %v: %v\n", user.ID, err)
+		response.StatusCode = "403"
+		return response
+	}
+
+	// Retrieve/produce signing key for shrampybot JWT for the user
+	sub := utility.SubjectForTwitchUser(user.ID)
+	sbOAuth, err := n.GetOAuth(sub)
+	if err != nil || sbOAuth.SecretKey == "" {
+		sbOAuth.Id = sub
+		sbOAuth.SecretKey = utility.GenerateRandomHex(sha256.BlockSize)
+	}
+	// Update refresh UID and store since we'll be generating new tokens
+	sbOAuth.RefreshUID = uuid.NewString()
+	err = n.PutOAuth(sbOAuth)
+	if err != nil {
+		log.Println("Could not store OAuth record.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	accessToken, err := generateAccessToken(sbOAuth, scopes)
+	if err != nil {
+		log.Printf("Could not generate access token: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	refreshToken, err := generateRefreshToken(sbOAuth)
+	if err != nil {
+		log.Printf("Could not generate refresh token: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	body := ValidateResponseBody{
+		UserID:       sbOAuth.Id,
+		Username:     user.Login,
+		AccessToken:  accessToken,
+		RedirectPath: state.RedirectPath,
+	}
+	bodyBytes, _ := json.Marshal(body)
+
+	// RefreshToken in httponly cookie
+	cookie := http.Cookie{
+		Name:        "RefreshToken",
+		Value:       refreshToken,
+		HttpOnly:    true,
+		SameSite:    http.SameSiteNoneMode,
+		Secure:      true,
+		Partitioned: true,
+		Expires:     time.Now().Add(336 * time.Hour),
+	}
+	response.Headers.SetCookie = cookie.String()
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exiting route: Auth.ValidateTwitch.Post")
+	return response
+}
@@ -25,13 +25,15 @@ type ValidateView struct {
 
 type ValidateRequestBody struct {
 	Code      string `json:"code"`
+	State     string `json:"state"`
 	GrantType string `json:"grant_type"`
 }
 
 type ValidateResponseBody struct {
-	UserID      string `json:"user_id"`
-	Username    string `json:"username"`
-	AccessToken string `json:"access"`
+	UserID       string `json:"user_id"`
+	Username     string `json:"username"`
+	AccessToken  string `json:"access"`
+	RedirectPath string `json:"redirect_path,omitempty"`
 	// Commenting out RefreshToken as it will be handled with httponly cookies
 	// RefreshToken string `json:"refresh"`
 }
@@ -79,14 +81,17 @@ func (v *ValidateView) Post(route *router.Route) *router.Response {
 	reqBody := ValidateRequestBody{}
 	json.Unmarshal([]byte(route.Body), &reqBody)
 
-	referer := route.Router.Event.Headers.Referer
-	if referer == "" {
-		log.Println("No referer header.")
+	// The state must be one we issued, and carries the PKCE verifier and
+	// the allowlisted redirect URI for the token exchange.
+	nonce := oAuthStateNonce(route.Router.Event.Headers.Cookie)
+	state, err := consumeStateToken(reqBody.State, nonce, "discord", n)
+	if err != nil {
+		log.Printf("Invalid OAuth state: %v\n", err)
 		response.StatusCode = "403"
 		return response
 	}
 
-	dOAuth, err := discordTokenExchange(reqBody.Code, referer)
+	dOAuth, err := discordTokenExchange(reqBody.Code, state.RedirectUri, state.CodeVerifier)
 	if err != nil {
 		log.Println("Could not complete Discord token exchange.")
 		response.StatusCode = "403"
@@ -174,8 +179,9 @@ func (v *ValidateView) Post(route *router.Route) *router.Response {
 	// about the tokens themselves. Shit's going to be handled dynamically yo.
 
 	body := ValidateResponseBody{
-		UserID:      sbOAuth.Id,
-		AccessToken: accessToken,
+		UserID:       sbOAuth.Id,
+		AccessToken:  accessToken,
+		RedirectPath: state.RedirectPath,
 	}
 	bodyBytes, _ := json.Marshal(body)
 
@@ -197,15 +203,14 @@ func (v *ValidateView) Post(route *router.Route) *router.Response {
 	return response
 }
 
-func discordTokenExchange(code string, redirect_base string) (*nosqldb.DiscordOAuthDatum, error) {
+func discordTokenExchange(code string, redirectUri string, codeVerifier string) (*nosqldb.DiscordOAuthDatum, error) {
 	oAuthResponse := nosqldb.DiscordOAuthDatum{}
 
-	log.Printf("Code: %v\n", code)
-
 	query_data := url.Values{}
 	query_data.Set("grant_type", "authorization_code")
 	query_data.Set("code", code)
-	query_data.Set("redirect_uri", fmt.Sprintf("%vshrampybot/auth/validate_oauth", redirect_base))
+	query_data.Set("redirect_uri", redirectUri)
+	query_data.Set("code_verifier", codeVerifier)
 	query_data.Set("client_id", config.DiscordClientId)
 	query_data.Set("client_secret", config.DiscordClientSecret)
 
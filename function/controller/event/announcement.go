@@ -0,0 +1,277 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"shrampybot/connector"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"shrampybot/utility/queue"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AnnouncementRetryJobType = "announcement.retry"
+)
+
+var (
+	ErrStreamNotFound = errors.New("stream not found")
+	// A "now streaming" post is no use once the stream is over
+	ErrStreamEnded = errors.New("stream ended before it could be announced")
+)
+
+type AnnouncementRetryPayload struct {
+	StreamId string `json:"stream_id"`
+	Platform string `json:"platform"`
+}
+
+// One job per stream and platform, so a retry is only ever queued once
+func AnnouncementRetryJobId(streamId string, platform string) string {
+	return fmt.Sprintf("announcement:%v:%v", streamId, platform)
+}
+
+// Fetches the thumbnail that goes with every post about a stream
+func streamPreviewImage(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum) *utility.Image {
+	altText := fmt.Sprintf("Preview of %v's stream on Twitch.", user.DisplayName)
+	dimensions := strings.Split(config.StreamThumbResolution, "x")
+	width, _ := strconv.Atoi(dimensions[0])
+	height, _ := strconv.Atoi(dimensions[1])
+
+	previewImage, _ := utility.NewFromThumbnailURL(
+		stream.ThumbnailURL,
+		width,
+		height,
+		altText,
+	)
+	return previewImage
+}
+
+// Renders each platform's announcement from its template, falling back on
+// the platform's default text
+func announcementFormatter(n *nosqldb.NoSqlDb, user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum) func(pub connector.Publisher) string {
+	templates, err := n.GetAnnouncementTemplates()
+	if err != nil {
+		log.Printf("Could not load announcement templates, using defaults: %v\n", err)
+	}
+	currentEvent, _ := n.GetCurrentEvent(1)
+
+	return func(pub connector.Publisher) string {
+		tmpl := nosqldb.FindAnnouncementTemplate(templates, pub.Name(), category.Id)
+		data := connector.NewAnnouncementData(pub, user, stream, category, currentEvent)
+		msg, err := connector.FormatStreamAnnouncement(pub, tmpl, data)
+		if err != nil {
+			log.Printf("Template %v failed to render, using default: %v\n", tmpl.Id, err)
+		}
+		return msg
+	}
+}
+
+// Marks every platform as pending as soon as the stream is claimed, so that
+// an announcement cut off part way through still shows up
+func (p *Pipeline) recordPendingDeliveries(stream *nosqldb.StreamHistoryDatum, platforms []string) {
+	if !p.Posting() {
+		return
+	}
+
+	now := time.Now()
+	for _, platform := range platforms {
+		p.recordDelivery(stream, platform, nosqldb.PostDelivery{
+			Status:        nosqldb.DeliveryStatusPending,
+			LastAttemptAt: now,
+		}, nil)
+	}
+}
+
+// Notes how the first round of posts went. Nothing is recorded for posts
+// that were skipped.
+func (p *Pipeline) recordDeliveries(stream *nosqldb.StreamHistoryDatum, posts []utility.PostResponse, failures map[string]error) {
+	if !p.Posting() {
+		return
+	}
+
+	now := time.Now()
+	for _, post := range posts {
+		p.recordDelivery(stream, post.Platform, nosqldb.PostDelivery{
+			Status:        nosqldb.DeliveryStatusSent,
+			LastAttemptAt: now,
+		}, &post)
+	}
+	for platform, err := range failures {
+		p.recordDelivery(stream, platform, nosqldb.PostDelivery{
+			Status:        nosqldb.DeliveryStatusFailed,
+			Attempts:      1,
+			LastError:     err.Error(),
+			LastAttemptAt: now,
+		}, nil)
+	}
+}
+
+// Writes one platform's delivery, and its post if there is one, to both the
+// stream in hand and the stored stream
+func (p *Pipeline) recordDelivery(stream *nosqldb.StreamHistoryDatum, platform string, delivery nosqldb.PostDelivery, post *utility.PostResponse) {
+	if post != nil {
+		if stream.Posts == nil {
+			stream.Posts = map[string]utility.PostResponse{}
+		}
+		stream.Posts[platform] = *post
+	}
+	stream.SetDelivery(platform, delivery)
+
+	err := p.n.SetStreamDelivery(stream.ID, platform, delivery, post)
+	if err != nil {
+		log.Printf("Could not record %v delivery for stream %v: %v\n", platform, stream.ID, err)
+	}
+}
+
+// Queues a retry for every platform the first round failed on
+func (p *Pipeline) scheduleAnnouncementRetries(streamId string, failures map[string]error) {
+	if !p.Posting() || len(failures) == 0 {
+		return
+	}
+
+	backend := queue.DefaultBackend()
+	for platform, err := range failures {
+		payloadBytes, _ := json.Marshal(AnnouncementRetryPayload{
+			StreamId: streamId,
+			Platform: platform,
+		})
+		now := time.Now()
+		enqueueErr := backend.Enqueue(&queue.Job{
+			Id:            AnnouncementRetryJobId(streamId, platform),
+			Type:          AnnouncementRetryJobType,
+			Payload:       string(payloadBytes),
+			Attempts:      1,
+			CreatedAt:     now,
+			NextAttemptAt: now.Add(queue.Backoff(1)),
+			LastError:     err.Error(),
+		})
+		if enqueueErr != nil {
+			log.Printf("Could not queue a retry of %v for stream %v: %v\n", platform, streamId, enqueueErr)
+		}
+	}
+}
+
+func handleAnnouncementRetryJob(ctx context.Context, job *queue.Job) error {
+	payload := AnnouncementRetryPayload{}
+	err := json.Unmarshal([]byte(job.Payload), &payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = RetryAnnouncement(payload.StreamId, payload.Platform)
+	if errors.Is(err, ErrStreamEnded) || errors.Is(err, ErrStreamNotFound) {
+		log.Printf("Giving up on %v announcement for stream %v: %v\n", payload.Platform, payload.StreamId, err)
+		return nil
+	}
+	return err
+}
+
+// Announces a live stream that hasn't been announced yet, for changes that
+// are picked up outside of an eventsub notification, such as polling. It's
+// the same check channel.update makes for a change of category or title.
+func AnnounceChangedStream(stream *nosqldb.StreamHistoryDatum) error {
+	if stream.Announced() || stream.ShrampybotDebounced || !stream.EndedAt.IsZero() {
+		return nil
+	}
+
+	p, err := NewPipeline(PipelineModeFull)
+	if err != nil {
+		return err
+	}
+	user, err := p.n.GetTwitchUser(stream.UserID)
+	if err != nil {
+		return err
+	}
+	if user.ID == "" {
+		return ErrUserNotFound
+	}
+
+	log.Printf("Unannounced stream %v changed to %v; checking whether to announce.\n", stream.ID, stream.GameName)
+	return announceStream(p, user, stream)
+}
+
+// Posts one platform's announcement for a stream again, recording the
+// outcome on the stream. Announcements that already went out are left alone.
+func RetryAnnouncement(streamId string, platform string) (*nosqldb.PostDelivery, error) {
+	p, err := NewPipeline(PipelineModeFull)
+	if err != nil {
+		return nil, err
+	}
+	n := p.n
+
+	stream, err := n.GetStream(streamId)
+	if err != nil {
+		return nil, err
+	}
+	if stream.ID == "" {
+		return nil, ErrStreamNotFound
+	}
+	if post, ok := stream.Posts[platform]; ok && post.Id != "" {
+		delivery := stream.Deliveries[platform]
+		delivery.Status = nosqldb.DeliveryStatusSent
+		return &delivery, nil
+	}
+	if !stream.Announced() {
+		return nil, fmt.Errorf("stream %v has not been announced", streamId)
+	}
+
+	delivery := stream.Deliveries[platform]
+	if !stream.EndedAt.IsZero() {
+		delivery.Status = nosqldb.DeliveryStatusFailed
+		delivery.LastError = ErrStreamEnded.Error()
+		err = n.SetStreamDelivery(streamId, platform, delivery, nil)
+		if err != nil {
+			return &delivery, err
+		}
+		return &delivery, ErrStreamEnded
+	}
+
+	user, err := n.GetTwitchUser(stream.UserID)
+	if err != nil {
+		return nil, err
+	}
+	category, err := n.GetCategoryByName(stream.GameName)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		category = &nosqldb.CategoryDatum{}
+	}
+
+	log.Printf("Retrying %v announcement for stream %v.\n", platform, streamId)
+	post, postErr := p.publishTo(
+		platform,
+		announcementFormatter(n, user, stream, category),
+		streamPreviewImage(user, stream),
+		&announcedStream{user: user, stream: stream, category: category},
+	)
+
+	delivery.LastAttemptAt = time.Now()
+	var sent *utility.PostResponse
+	if postErr != nil {
+		delivery.Status = nosqldb.DeliveryStatusFailed
+		delivery.Attempts++
+		delivery.LastError = postErr.Error()
+	} else {
+		sent = &post
+		delivery.Status = nosqldb.DeliveryStatusSent
+		delivery.LastError = ""
+	}
+
+	// Only this platform's entries are written, so other platforms' retries
+	// running at the same time aren't overwritten
+	err = n.SetStreamDelivery(streamId, platform, delivery, sent)
+	if err != nil {
+		log.Printf("Could not record %v delivery for stream %v: %v\n", platform, streamId, err)
+		if postErr == nil {
+			return &delivery, err
+		}
+	}
+	return &delivery, postErr
+}
@@ -0,0 +1,223 @@
+package event
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"shrampybot/utility/queue"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookDeliveryJobType = "webhook.delivery"
+
+	webhookTimeout = 10 * time.Second
+	// Only enough of a response body is read to reuse the connection
+	webhookResponseLimit = 64 * 1024
+)
+
+// The stream an outbound webhook event is about
+type WebhookStream struct {
+	Id        string     `json:"id"`
+	UserId    string     `json:"user_id"`
+	UserLogin string     `json:"user_login"`
+	UserName  string     `json:"user_name"`
+	Title     string     `json:"title"`
+	Category  string     `json:"category"`
+	Url       string     `json:"url"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// Announcement posts, once there are any
+	Posts []utility.PostResponse `json:"posts,omitempty"`
+}
+
+// The body POSTed to webhooks. Id is unique to each webhook and stays the
+// same across retries, so receivers can use it to ignore repeats.
+type WebhookPayload struct {
+	Id     string        `json:"id"`
+	Event  string        `json:"event"`
+	Time   time.Time     `json:"time"`
+	Stream WebhookStream `json:"stream"`
+}
+
+type WebhookDeliveryJobPayload struct {
+	DeliveryId string `json:"delivery_id"`
+}
+
+func WebhookDeliveryJobId(deliveryId string) string {
+	return fmt.Sprintf("webhook:%v", deliveryId)
+}
+
+func newWebhookStream(stream *nosqldb.StreamHistoryDatum) WebhookStream {
+	output := WebhookStream{
+		Id:        stream.ID,
+		UserId:    stream.UserID,
+		UserLogin: stream.UserLogin,
+		UserName:  stream.UserName,
+		Title:     stream.Title,
+		Category:  stream.GameName,
+		Url:       fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin),
+		StartedAt: stream.StartedAt,
+	}
+	if !stream.EndedAt.IsZero() {
+		endedAt := stream.EndedAt
+		output.EndedAt = &endedAt
+	}
+	for _, post := range stream.Posts {
+		output.Posts = append(output.Posts, post)
+	}
+	sort.Slice(output.Posts, func(i, j int) bool {
+		return output.Posts[i].Platform < output.Posts[j].Platform
+	})
+	return output
+}
+
+// Queues a delivery of the event to every webhook that wants it. Sending
+// happens in the queue worker, which retries failures with backoff.
+func (p *Pipeline) notifyWebhooks(event string, stream *nosqldb.StreamHistoryDatum) {
+	webhooks, err := p.n.GetWebhooksForEvent(event)
+	if err != nil {
+		log.Printf("Could not load webhooks for %v event: %v\n", event, err)
+		return
+	}
+
+	now := time.Now()
+	backend := queue.DefaultBackend()
+	for _, webhook := range webhooks {
+		if !p.Posting() {
+			log.Printf("Not sending %v event to webhook %v in %v mode.\n", event, webhook.Name, p.Mode)
+			p.noteSkipped(SkippedPost{Platform: "webhook:" + webhook.Name, Action: event, Message: webhook.Url})
+			continue
+		}
+
+		payload := WebhookPayload{
+			Id:     uuid.NewString(),
+			Event:  event,
+			Time:   now,
+			Stream: newWebhookStream(stream),
+		}
+		payloadBytes, _ := json.Marshal(payload)
+
+		delivery := nosqldb.WebhookDeliveryDatum{
+			Id:        payload.Id,
+			WebhookId: webhook.Id,
+			Event:     event,
+			Payload:   string(payloadBytes),
+			Status:    nosqldb.WebhookDeliveryStatusPending,
+			CreatedAt: now,
+		}
+		err = p.n.PutWebhookDelivery(&delivery)
+		if err != nil {
+			log.Printf("Could not log delivery to webhook %v: %v\n", webhook.Name, err)
+			continue
+		}
+
+		jobBytes, _ := json.Marshal(WebhookDeliveryJobPayload{DeliveryId: delivery.Id})
+		err = backend.Enqueue(&queue.Job{
+			Id:      WebhookDeliveryJobId(delivery.Id),
+			Type:    WebhookDeliveryJobType,
+			Payload: string(jobBytes),
+		})
+		if err != nil {
+			log.Printf("Could not queue delivery to webhook %v: %v\n", webhook.Name, err)
+		}
+	}
+}
+
+func handleWebhookDeliveryJob(ctx context.Context, job *queue.Job) error {
+	payload := WebhookDeliveryJobPayload{}
+	err := json.Unmarshal([]byte(job.Payload), &payload)
+	if err != nil {
+		return err
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return err
+	}
+	delivery, err := n.GetWebhookDelivery(payload.DeliveryId)
+	if err != nil {
+		return err
+	}
+	if delivery.Id == "" {
+		log.Printf("Webhook delivery %v no longer exists. Dropping it.\n", payload.DeliveryId)
+		return nil
+	}
+	webhook, err := n.GetWebhook(delivery.WebhookId)
+	if err != nil {
+		return err
+	}
+	if webhook.Id == "" || webhook.Disabled {
+		log.Printf("Webhook %v was removed or disabled. Dropping delivery %v.\n", delivery.WebhookId, delivery.Id)
+		return nil
+	}
+
+	code, sendErr := sendWebhook(ctx, webhook, delivery)
+	delivery.Attempts++
+	delivery.LastAttemptAt = time.Now()
+	delivery.ResponseCode = code
+	if sendErr == nil {
+		delivery.Status = nosqldb.WebhookDeliveryStatusDelivered
+		delivery.LastError = ""
+	} else {
+		delivery.Status = nosqldb.WebhookDeliveryStatusFailed
+		delivery.LastError = sendErr.Error()
+	}
+
+	err = n.PutWebhookDelivery(delivery)
+	if err != nil {
+		log.Printf("Could not update webhook delivery %v: %v\n", delivery.Id, err)
+	}
+	return sendErr
+}
+
+// Signature of a payload sent at the given unix timestamp, in the same
+// sha256=<hex> form Twitch uses
+func SignWebhookPayload(secret string, timestamp string, body string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "." + body))
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// POSTs a delivery, returning the response code. Anything but a 2xx is an
+// error.
+func sendWebhook(ctx context.Context, webhook *nosqldb.WebhookDatum, delivery *nosqldb.WebhookDeliveryDatum) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", webhook.Url, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "ShrampyBot-Webhook")
+	request.Header.Set("X-Shrampybot-Event", delivery.Event)
+	request.Header.Set("X-Shrampybot-Delivery", delivery.Id)
+	request.Header.Set("X-Shrampybot-Timestamp", timestamp)
+	request.Header.Set("X-Shrampybot-Signature", SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	hc := http.Client{Timeout: webhookTimeout}
+	resp, err := hc.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookResponseLimit))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,60 @@
+package event
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"shrampybot/utility/nosqldb"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '1700000000.{"id":"abc"}' | openssl dgst -sha256 -hmac shrimp
+	assert.Equal(t,
+		"sha256=9ff20024c1898894c7cb89494e60f5a17ea848b5504905bceb230405ebca04ae",
+		SignWebhookPayload("shrimp", "1700000000", `{"id":"abc"}`),
+	)
+}
+
+func TestSendWebhook(t *testing.T) {
+	webhook := &nosqldb.WebhookDatum{Id: "hook", Secret: "shrimp"}
+	delivery := &nosqldb.WebhookDeliveryDatum{Id: "delivery", Event: nosqldb.WebhookEventOnline, Payload: `{"id":"delivery"}`}
+
+	testCases := []struct {
+		name     string
+		status   int
+		expected string
+	}{
+		{name: "Accepted", status: http.StatusNoContent},
+		{name: "Rejected", status: http.StatusGone, expected: "webhook responded with status 410"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, delivery.Payload, string(body))
+				assert.Equal(t, "online", r.Header.Get("X-Shrampybot-Event"))
+				assert.Equal(t, "delivery", r.Header.Get("X-Shrampybot-Delivery"))
+				assert.Equal(t,
+					SignWebhookPayload("shrimp", r.Header.Get("X-Shrampybot-Timestamp"), string(body)),
+					r.Header.Get("X-Shrampybot-Signature"),
+				)
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+			webhook.Url = server.URL
+
+			code, err := sendWebhook(context.Background(), webhook, delivery)
+			assert.Equal(t, tc.status, code)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package event
+
+import (
+	"fmt"
+	"log"
+	"shrampybot/connector/twitch"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"sync"
+)
+
+const (
+	// Everything happens as it would for a live notification
+	PipelineModeFull = "full"
+	// Records are written but nothing is posted
+	PipelineModeStorageOnly = "storage_only"
+	// Nothing is posted or written; writes are only reported
+	PipelineModeDryRun = "dry_run"
+)
+
+var (
+	PipelineModes = []string{
+		PipelineModeFull,
+		PipelineModeStorageOnly,
+		PipelineModeDryRun,
+	}
+)
+
+// A post the pipeline would have made had it been running in full
+type SkippedPost struct {
+	Platform string `json:"platform"`
+	// Blank for new posts; edit or delete for changes to existing ones, or
+	// the event for webhooks
+	Action  string `json:"action,omitempty"`
+	Message string `json:"message"`
+}
+
+// Carries the database client and the mode through a notification's
+// callbacks, so that replays can run them without side effects.
+type Pipeline struct {
+	Mode         string
+	n            *nosqldb.NoSqlDb
+	lock         sync.Mutex
+	skippedPosts []SkippedPost
+}
+
+func NewPipeline(mode string) (*Pipeline, error) {
+	var err error
+	p := Pipeline{Mode: mode, skippedPosts: []SkippedPost{}}
+
+	switch mode {
+	case PipelineModeFull, PipelineModeStorageOnly:
+		p.n, err = nosqldb.NewClient()
+	case PipelineModeDryRun:
+		p.n, err = nosqldb.NewDryRunClient()
+	default:
+		return nil, fmt.Errorf("unknown pipeline mode %v", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Hands a notification to the callback for its subscription type
+func (p *Pipeline) Dispatch(sub *twitch.Subscription, event *map[string]any) error {
+	callback, ok := eventMap[sub.Type]
+	if !ok {
+		log.Printf("No callback for notification type %v\n", sub.Type)
+		return nil
+	}
+	return callback(p, sub, event)
+}
+
+// Whether posts should actually go out to social media
+func (p *Pipeline) Posting() bool {
+	return p.Mode == PipelineModeFull
+}
+
+// Notes down a post instead of making it. The empty response is treated the
+// same as a failed post by the callers.
+func (p *Pipeline) skipPost(platform string, msg string) *utility.PostResponse {
+	log.Printf("Not posting to %v in %v mode.\n", platform, p.Mode)
+	p.noteSkipped(SkippedPost{Platform: platform, Message: msg})
+	return &utility.PostResponse{Platform: platform}
+}
+
+func (p *Pipeline) noteSkipped(post SkippedPost) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.skippedPosts = append(p.skippedPosts, post)
+}
+
+func (p *Pipeline) SkippedPosts() []SkippedPost {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]SkippedPost{}, p.skippedPosts...)
+}
+
+// Writes skipped by a dry run
+func (p *Pipeline) Writes() []string {
+	return p.n.DryRunWrites()
+}
@@ -0,0 +1,202 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"shrampybot/connector"
+	"shrampybot/connector/twitch"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strings"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+)
+
+type ImagePreview struct {
+	Url      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	// Size in bytes; zero if the image couldn't be fetched
+	Length  int    `json:"length"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	AltText string `json:"alt_text"`
+}
+
+// What one platform would be sent
+type PostPreview struct {
+	Platform string            `json:"platform"`
+	Message  string            `json:"message"`
+	Facets   []connector.Facet `json:"facets"`
+	Hashtags []string          `json:"hashtags"`
+	Image    *ImagePreview     `json:"image,omitempty"`
+	// How platforms with more than one way to post would send it, e.g. as
+	// text or an embed, and what would go with the text
+	Mode  string `json:"mode,omitempty"`
+	Extra any    `json:"extra,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type AnnouncementPreview struct {
+	StreamId  string `json:"stream_id"`
+	UserLogin string `json:"user_login"`
+	// twitch if the stream is live right now, history if it was stored
+	Source   string                 `json:"source"`
+	Category *nosqldb.CategoryDatum `json:"category,omitempty"`
+	// Why nothing would be posted; blank if it would be
+	Suppressed string        `json:"suppressed,omitempty"`
+	Posts      []PostPreview `json:"posts"`
+}
+
+// Works out what going live would announce for a streamer, or for a stored
+// stream, without posting or writing anything. A live stream is taken from
+// Twitch; otherwise the streamer's latest stored stream is used. Publishers
+// are offline, so nothing is logged in to or looked up on the platforms.
+func PreviewAnnouncement(login string, streamId string) (*AnnouncementPreview, error) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var stream *nosqldb.StreamHistoryDatum
+	debounced := false
+	source := "history"
+
+	if streamId != "" {
+		stream, err = n.GetStream(streamId)
+		if err != nil {
+			return nil, err
+		}
+		if stream.ID == "" {
+			return nil, ErrStreamNotFound
+		}
+		debounced = stream.ShrampybotDebounced
+	} else {
+		ids, err := n.GetTwitchLoginIdMap()
+		if err != nil {
+			return nil, err
+		}
+		userId, ok := ids[strings.ToLower(login)]
+		if !ok {
+			return nil, ErrUserNotFound
+		}
+		stream, debounced, source, err = previewStreamForUser(n, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := n.GetTwitchUser(stream.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == "" {
+		return nil, ErrUserNotFound
+	}
+
+	preview := AnnouncementPreview{
+		StreamId:  stream.ID,
+		UserLogin: stream.UserLogin,
+		Source:    source,
+		Posts:     []PostPreview{},
+	}
+
+	check, err := checkAnnouncement(n, stream)
+	if err != nil {
+		return nil, err
+	}
+	preview.Category = check.category
+	switch {
+	case check.reason != "":
+		preview.Suppressed = check.reason
+	case debounced:
+		preview.Suppressed = "Stream resumed too soon after the last one ended"
+	default:
+		preview.Suppressed, err = preferenceSuppression(n, user, stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if preview.Suppressed != "" {
+		return &preview, nil
+	}
+
+	image := newImagePreview(streamPreviewImage(user, stream))
+	format := announcementFormatter(n, user, stream, check.category)
+	for _, name := range announcementPlatforms(user) {
+		post := PostPreview{
+			Platform: name,
+			Facets:   []connector.Facet{},
+			Hashtags: []string{},
+			Image:    image,
+		}
+		pub, err := connector.NewOfflinePublisher(name)
+		if err != nil {
+			post.Error = err.Error()
+			preview.Posts = append(preview.Posts, post)
+			continue
+		}
+
+		post.Message = format(pub)
+		post.Hashtags = connector.Hashtags(post.Message)
+		if fp, ok := pub.(connector.FacetPublisher); ok {
+			post.Facets = fp.Facets(post.Message)
+		}
+		if sp, ok := pub.(connector.StreamPreviewer); ok {
+			post.Mode, post.Extra = sp.PreviewStream(user, stream, check.category)
+		}
+		preview.Posts = append(preview.Posts, post)
+	}
+
+	return &preview, nil
+}
+
+// The stream to preview for a streamer. A live stream that hasn't been
+// stored yet goes through the same debounce check as a go-live would.
+func previewStreamForUser(n *nosqldb.NoSqlDb, userId string) (*nosqldb.StreamHistoryDatum, bool, string, error) {
+	latest, err := n.GetLatestStreamByUserId(userId)
+	if err != nil {
+		return nil, false, "", err
+	}
+
+	t, err := twitch.NewClient()
+	if err != nil {
+		return nil, false, "", err
+	}
+	tStream, err := t.GetStreamByUserId(userId)
+	if err != nil {
+		return nil, false, "", err
+	}
+
+	if tStream.ID == "" {
+		if latest.ID == "" {
+			return nil, false, "", ErrStreamNotFound
+		}
+		return latest, latest.ShrampybotDebounced, "history", nil
+	}
+
+	stream := nosqldb.StreamHistoryDatum{}
+	tsBytes, _ := json.Marshal(tStream)
+	json.Unmarshal(tsBytes, &stream)
+	stream.ID = tStream.ID
+
+	if latest.ID == stream.ID {
+		return &stream, latest.ShrampybotDebounced, "twitch", nil
+	}
+	return &stream, shouldDebounce(latest), "twitch", nil
+}
+
+func newImagePreview(image *utility.Image) *ImagePreview {
+	if image == nil {
+		return nil
+	}
+	return &ImagePreview{
+		Url:      image.Url,
+		MimeType: image.MimeType,
+		Length:   len(image.Data),
+		Width:    image.Width,
+		Height:   image.Height,
+		AltText:  image.AltText,
+	}
+}
@@ -0,0 +1,91 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"shrampybot/connector"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+
+	// Connectors register themselves as publishers when imported
+	_ "shrampybot/connector/bluesky"
+	_ "shrampybot/connector/discord"
+	_ "shrampybot/connector/mastodon"
+)
+
+// The stream an announcement is about, for publishers that can present it as
+// more than text. Posts that aren't about a single stream, like raids, go
+// without one.
+type announcedStream struct {
+	user     *nosqldb.TwitchUserDatum
+	stream   *nosqldb.StreamHistoryDatum
+	category *nosqldb.CategoryDatum
+}
+
+type publishResult struct {
+	post utility.PostResponse
+	err  error
+}
+
+// Posts to the named platforms at once, with the message for each coming
+// from format. Platforms that fail are left out of the posts and returned
+// with their errors instead. Skipped posts are in neither.
+func (p *Pipeline) publish(names []string, format func(pub connector.Publisher) string, image *utility.Image, about *announcedStream) ([]utility.PostResponse, map[string]error) {
+	resultChan := make(chan publishResult, len(names))
+	for _, name := range names {
+		go func(name string) {
+			post, err := p.publishTo(name, format, image, about)
+			resultChan <- publishResult{post: post, err: err}
+		}(name)
+	}
+
+	posts := []utility.PostResponse{}
+	failures := map[string]error{}
+	for range names {
+		result := <-resultChan
+		if result.err != nil {
+			failures[result.post.Platform] = result.err
+		} else if result.post.Id != "" {
+			posts = append(posts, result.post)
+		}
+	}
+	return posts, failures
+}
+
+// Publishers are only logged in to when the pipeline is going to post;
+// otherwise an offline one is enough to format the message
+func (p *Pipeline) newPublisher(name string) (connector.Publisher, error) {
+	if !p.Posting() {
+		return connector.NewOfflinePublisher(name)
+	}
+	return connector.NewPublisher(name)
+}
+
+func (p *Pipeline) publishTo(name string, format func(pub connector.Publisher) string, image *utility.Image, about *announcedStream) (utility.PostResponse, error) {
+	pub, err := p.newPublisher(name)
+	if err != nil {
+		log.Printf("Could not connect to %v: %v\n", name, err)
+		return utility.PostResponse{Platform: name}, fmt.Errorf("could not connect: %w", err)
+	}
+
+	msg := format(pub)
+	if !p.Posting() {
+		return *p.skipPost(name, msg), nil
+	}
+
+	var resp *utility.PostResponse
+	if sp, ok := pub.(connector.StreamPublisher); ok && about != nil {
+		resp, err = sp.PostStream(msg, image, about.user, about.stream, about.category)
+	} else {
+		resp, err = pub.Post(msg, image)
+	}
+	if err == nil && (resp == nil || resp.Id == "") {
+		err = errors.New("no post id returned")
+	}
+	if err != nil {
+		log.Printf("Error posting to %v: %v\n", name, err)
+		return utility.PostResponse{Platform: name}, err
+	}
+	return *resp, nil
+}
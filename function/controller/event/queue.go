@@ -0,0 +1,101 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"shrampybot/connector/twitch"
+	"shrampybot/utility/queue"
+	"time"
+)
+
+const (
+	// Leave this much of the invocation for writing back job state
+	workerDeadlineMargin = 10 * time.Second
+	// Twitch wants webhook responses within a few seconds, so don't start
+	// jobs beyond this after one is queued by a request
+	enqueueDrainBudget = 3 * time.Second
+)
+
+// What gets queued for each notification
+type NotificationJobPayload struct {
+	Subscription *twitch.Subscription `json:"subscription"`
+	Event        *map[string]any      `json:"event"`
+}
+
+// Queues a notification for the worker. The message id doubles as the job
+// id so that a notification Twitch resends is only queued once.
+func EnqueueNotification(backend queue.Backend, messageId string, sub *twitch.Subscription, event *map[string]any) error {
+	payloadBytes, _ := json.Marshal(NotificationJobPayload{
+		Subscription: sub,
+		Event:        event,
+	})
+	return backend.Enqueue(&queue.Job{
+		Id:      messageId,
+		Type:    sub.Type,
+		Payload: string(payloadBytes),
+	})
+}
+
+// Worker with a handler for every notification type in eventMap, and for
+// announcement retries and webhook deliveries
+func NewNotificationWorker(backend queue.Backend) *queue.Worker {
+	w := queue.NewWorker(backend)
+	for subType := range eventMap {
+		w.Handle(subType, handleNotificationJob)
+	}
+	w.Handle(AnnouncementRetryJobType, handleAnnouncementRetryJob)
+	w.Handle(WebhookDeliveryJobType, handleWebhookDeliveryJob)
+	return w
+}
+
+func handleNotificationJob(ctx context.Context, job *queue.Job) error {
+	payload := NotificationJobPayload{}
+	err := json.Unmarshal([]byte(job.Payload), &payload)
+	if err != nil {
+		return err
+	}
+	if payload.Subscription == nil {
+		payload.Subscription = &twitch.Subscription{Type: job.Type}
+	}
+	return DispatchNotification(payload.Subscription, payload.Event)
+}
+
+// Entry point for invocations that aren't HTTP requests, such as a schedule
+// or the event queue table's stream. Works through whatever is due.
+func RunQueueWorker(ctx context.Context) {
+	ctx, cancel := workerContext(ctx)
+	defer cancel()
+
+	drainQueue(ctx)
+}
+
+// Works through the queue straight after something was queued, so that it's
+// handled by the invocation that queued it rather than waiting for the next
+// scheduled run. Capped so a backlog can't hold up the response; whatever's
+// left, or fails and backs off, waits for a later invocation.
+func DrainQueue(ctx context.Context) {
+	ctx, cancel := workerContext(ctx)
+	defer cancel()
+	ctx, cancelBudget := context.WithTimeout(ctx, enqueueDrainBudget)
+	defer cancelBudget()
+
+	drainQueue(ctx)
+}
+
+// Stops the worker short of the invocation's deadline
+func workerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(ctx, deadline.Add(-workerDeadlineMargin))
+	}
+	return context.WithCancel(ctx)
+}
+
+func drainQueue(ctx context.Context) {
+	log.Println("Draining event queue.")
+	w := NewNotificationWorker(queue.DefaultBackend())
+	err := w.Drain(ctx)
+	if err != nil {
+		log.Printf("Could not drain event queue: %v\n", err)
+	}
+}
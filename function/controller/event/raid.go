@@ -0,0 +1,178 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"shrampybot/connector"
+	"shrampybot/connector/twitch"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/litui/helix/v3"
+)
+
+const (
+	defaultRaidAnnouncementFormat = "{from} just raided {to} with {viewers} viewers! Catch {to} streaming {category} on Twitch: {url}\n\n{title}"
+	// A raid within this long of a recorded one with the same ends is the same raid
+	raidDuplicateWindow = 5 * time.Minute
+)
+
+// Records raids on both streams and announces raids between two active
+// team members.
+func channelRaidCallback(p *Pipeline, sub *twitch.Subscription, eventMap *map[string]any) error {
+	var err error
+	log.Println("Entered channelRaidCallback")
+
+	// Unmarshal event data into helix struct
+	event := helix.EventSubChannelRaidEvent{}
+	evBytes, _ := json.Marshal(eventMap)
+	json.Unmarshal(evBytes, &event)
+
+	n := p.n
+
+	raid := nosqldb.StreamRaid{
+		Time:       time.Now(),
+		FromUserId: event.FromBroadcasterUserID,
+		FromLogin:  event.FromBroadcasterUserLogin,
+		ToUserId:   event.ToBroadcasterUserID,
+		ToLogin:    event.ToBroadcasterUserLogin,
+		Viewers:    event.Viewers,
+	}
+
+	fromStream, err := n.GetLatestStreamByUserId(raid.FromUserId)
+	if err != nil {
+		log.Printf("Error getting recent history record for user %v: %v\n", raid.FromLogin, err)
+		return err
+	}
+	toStream, err := n.GetLatestStreamByUserId(raid.ToUserId)
+	if err != nil {
+		log.Printf("Error getting recent history record for user %v: %v\n", raid.ToLogin, err)
+		return err
+	}
+	hasFromStream := fromStream != nil && fromStream.ID != ""
+	hasToStream := toStream != nil && toStream.ID != "" && toStream.EndedAt.IsZero()
+
+	// Use the record on the receiving end to tell whether a retried
+	// notification has been handled already
+	if hasToStream {
+		if existing := findRaid(toStream.Raids, &raid); existing != nil {
+			if existing.Announced {
+				log.Println("Raid has already been announced. Stopping processing.")
+				return nil
+			}
+			raid = *existing
+		}
+	}
+
+	if hasFromStream {
+		outgoing := raid
+		outgoing.Direction = "outgoing"
+		if hasToStream {
+			outgoing.OtherStreamId = toStream.ID
+		}
+		upsertRaid(fromStream, &outgoing)
+		err = n.PutStream(fromStream)
+		if err != nil {
+			log.Printf("Could not record raid on stream %v: %v\n", fromStream.ID, err)
+			return err
+		}
+	}
+	if !hasToStream {
+		log.Printf("%v is not live as far as we know. Not announcing raid.\n", raid.ToLogin)
+		return nil
+	}
+
+	raid.Direction = "incoming"
+	if hasFromStream {
+		raid.OtherStreamId = fromStream.ID
+	}
+
+	fromUser, err := n.GetTwitchUser(raid.FromUserId)
+	if err != nil {
+		return err
+	}
+	toUser, err := n.GetTwitchUser(raid.ToUserId)
+	if err != nil {
+		return err
+	}
+	announce := fromUser.ShrampybotActive && toUser.ShrampybotActive && !toStream.ShrampybotFiltered
+	if !announce {
+		log.Printf("Raid from %v to %v is not between active team members. Recording only.\n", raid.FromLogin, raid.ToLogin)
+	}
+
+	// Save before posting so that a retry can't announce it twice
+	raid.Announced = announce
+	upsertRaid(toStream, &raid)
+	err = n.PutStream(toStream)
+	if err != nil {
+		log.Printf("Could not record raid on stream %v: %v\n", toStream.ID, err)
+		return err
+	}
+	if !announce {
+		return nil
+	}
+
+	raid.Posts = postRaidAnnouncement(p, fromUser, toUser, toStream, &raid)
+	upsertRaid(toStream, &raid)
+	err = n.PutStream(toStream)
+	if err != nil {
+		log.Printf("Failed to write raid posts to stream %v.\n", toStream.ID)
+		return err
+	}
+
+	return nil
+}
+
+func findRaid(raids []nosqldb.StreamRaid, raid *nosqldb.StreamRaid) *nosqldb.StreamRaid {
+	for i := range raids {
+		r := &raids[i]
+		if r.FromUserId == raid.FromUserId && r.ToUserId == raid.ToUserId && raid.Time.Sub(r.Time).Abs() < raidDuplicateWindow {
+			return r
+		}
+	}
+	return nil
+}
+
+// Adds a raid to a stream, replacing the matching entry if there is one
+func upsertRaid(stream *nosqldb.StreamHistoryDatum, raid *nosqldb.StreamRaid) {
+	if existing := findRaid(stream.Raids, raid); existing != nil {
+		*existing = *raid
+		return
+	}
+	stream.Raids = append(stream.Raids, *raid)
+}
+
+// Fills in a raid announcement. Names are passed in so that each platform
+// can mention people its own way.
+func formatRaidMsg(from string, to string, raid *nosqldb.StreamRaid, stream *nosqldb.StreamHistoryDatum) string {
+	format := config.RaidAnnouncementFormat
+	if format == "" {
+		format = defaultRaidAnnouncementFormat
+	}
+	// Allow literal newlines to be written as \n in the environment
+	format = strings.ReplaceAll(format, `\n`, "\n")
+
+	return strings.NewReplacer(
+		"{from}", from,
+		"{to}", to,
+		"{viewers}", strconv.Itoa(raid.Viewers),
+		"{category}", stream.GameName,
+		"{title}", stream.Title,
+		"{url}", fmt.Sprintf("https://twitch.tv/%v", raid.ToLogin),
+	).Replace(format)
+}
+
+func postRaidAnnouncement(p *Pipeline, fromUser *nosqldb.TwitchUserDatum, toUser *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, raid *nosqldb.StreamRaid) []utility.PostResponse {
+	previewImage := streamPreviewImage(toUser, stream)
+
+	log.Printf("Starting raid posts.")
+	posts, _ := p.publish(connector.PublisherNames(), func(pub connector.Publisher) string {
+		return formatRaidMsg(pub.Mention(fromUser), pub.Mention(toUser), raid, stream)
+	}, previewImage, nil)
+	return posts
+}
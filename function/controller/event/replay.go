@@ -0,0 +1,52 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"shrampybot/connector/twitch"
+)
+
+// What happened when a notification was run through the pipeline again
+type ReplayReport struct {
+	Mode             string        `json:"mode"`
+	MessageId        string        `json:"message_id,omitempty"`
+	SubscriptionType string        `json:"subscription_type"`
+	Error            string        `json:"error,omitempty"`
+	Writes           []string      `json:"writes"`
+	SkippedPosts     []SkippedPost `json:"skipped_posts"`
+}
+
+// Runs a notification body, as Twitch would have sent it to the webhook,
+// through the same callbacks as a live one. Skips the signature and
+// duplicate checks, which is the point of a replay.
+func ReplayNotification(messageId string, body string, mode string) (*ReplayReport, error) {
+	requestBody := twitch.NotificationWebhook{}
+	err := json.Unmarshal([]byte(body), &requestBody)
+	if err != nil {
+		return nil, err
+	}
+	if requestBody.Subscription == nil || requestBody.Subscription.Type == "" || requestBody.Event == nil {
+		return nil, errors.New("payload is not an eventsub notification")
+	}
+
+	p, err := NewPipeline(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	report := ReplayReport{
+		Mode:             mode,
+		MessageId:        messageId,
+		SubscriptionType: requestBody.Subscription.Type,
+	}
+	log.Printf("Replaying %v notification %v in %v mode.\n", report.SubscriptionType, messageId, mode)
+	err = p.Dispatch(requestBody.Subscription, requestBody.Event)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Writes = p.Writes()
+	report.SkippedPosts = p.SkippedPosts()
+
+	return &report, nil
+}
@@ -0,0 +1,140 @@
+package event
+
+import (
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"shrampybot/connector"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EndedActionLeave  = "leave"
+	EndedActionEdit   = "edit"
+	EndedActionDelete = "delete"
+)
+
+// Reads END_OF_STREAM_ACTIONS into a map of platform to action. Unknown
+// actions are logged and left out, so those platforms keep their posts.
+func parseEndedActions(setting string) map[string]string {
+	actions := map[string]string{}
+	for _, pair := range strings.Split(setting, ",") {
+		platform, action, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			continue
+		}
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		action = strings.ToLower(strings.TrimSpace(action))
+		switch action {
+		case EndedActionLeave, EndedActionEdit, EndedActionDelete:
+			actions[platform] = action
+		default:
+			log.Printf("Unknown end of stream action %v for %v\n", action, platform)
+		}
+	}
+	return actions
+}
+
+// Stream length to the minute, e.g. 2h 5m
+func formatStreamDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%vm", minutes)
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("%vh", hours)
+	}
+	return fmt.Sprintf("%vh %vm", hours, minutes)
+}
+
+func formatEndedMsg(name string, stream *nosqldb.StreamHistoryDatum) string {
+	return fmt.Sprintf(
+		"%v was live streaming %v on Twitch for %v:\n%v\n\n%v",
+		name,
+		stream.GameName,
+		formatStreamDuration(stream.EndedAt.Sub(stream.StartedAt)),
+		stream.Title,
+		fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin),
+	)
+}
+
+// Edits or deletes a finished stream's announcements as configured. Posts
+// that have been handled are marked so that a retry leaves them alone.
+func (p *Pipeline) endAnnouncements(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum) {
+	actions := parseEndedActions(config.EndOfStreamActions)
+
+	wg := sync.WaitGroup{}
+	lock := sync.Mutex{}
+	changed := []utility.PostResponse{}
+	for platform, post := range stream.Posts {
+		action := actions[platform]
+		if action == "" || action == EndedActionLeave || post.EndedAction != "" || post.Id == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(post utility.PostResponse, action string) {
+			defer wg.Done()
+			if p.endAnnouncement(user, stream, &post, action) {
+				lock.Lock()
+				changed = append(changed, post)
+				lock.Unlock()
+			}
+		}(post, action)
+	}
+	wg.Wait()
+
+	for _, post := range changed {
+		stream.Posts[post.Platform] = post
+	}
+}
+
+// Applies one action to one post. Returns whether the post was changed.
+func (p *Pipeline) endAnnouncement(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, post *utility.PostResponse, action string) bool {
+	pub, err := p.newPublisher(post.Platform)
+	if err != nil {
+		log.Printf("Could not connect to %v: %v\n", post.Platform, err)
+		return false
+	}
+
+	msg := ""
+	if action == EndedActionEdit {
+		msg = formatEndedMsg(pub.Mention(user), stream)
+	}
+	if !p.Posting() {
+		log.Printf("Not changing %v post in %v mode.\n", post.Platform, p.Mode)
+		p.noteSkipped(SkippedPost{Platform: post.Platform, Action: action, Message: msg})
+		return false
+	}
+
+	switch action {
+	case EndedActionEdit:
+		editor, ok := pub.(connector.Editor)
+		if !ok {
+			log.Printf("%v posts can't be edited. Leaving post %v.\n", post.Platform, post.Id)
+			return false
+		}
+		err = editor.Edit(*post, msg)
+	case EndedActionDelete:
+		deleter, ok := pub.(connector.Deleter)
+		if !ok {
+			log.Printf("%v posts can't be deleted. Leaving post %v.\n", post.Platform, post.Id)
+			return false
+		}
+		err = deleter.Delete(*post)
+	}
+	if err != nil {
+		log.Printf("Could not %v %v post %v: %v\n", action, post.Platform, post.Id, err)
+		return false
+	}
+
+	log.Printf("Applied %v to %v post %v.\n", action, post.Platform, post.Id)
+	post.EndedAction = action
+	return true
+}
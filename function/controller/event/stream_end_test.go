@@ -0,0 +1,65 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndedActions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		setting  string
+		expected map[string]string
+	}{
+		{
+			name:     "Unset",
+			setting:  "",
+			expected: map[string]string{},
+		},
+		{
+			name:    "Every action",
+			setting: "discord=edit,mastodon=delete,bluesky=leave",
+			expected: map[string]string{
+				"discord":  EndedActionEdit,
+				"mastodon": EndedActionDelete,
+				"bluesky":  EndedActionLeave,
+			},
+		},
+		{
+			name:     "Spacing and case",
+			setting:  " Discord = EDIT , ",
+			expected: map[string]string{"discord": EndedActionEdit},
+		},
+		{
+			name:     "Unknown action and missing value",
+			setting:  "discord=archive,mastodon,bluesky=delete",
+			expected: map[string]string{"bluesky": EndedActionDelete},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseEndedActions(tc.setting))
+		})
+	}
+}
+
+func TestFormatStreamDuration(t *testing.T) {
+	testCases := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{duration: 20 * time.Second, expected: "0m"},
+		{duration: 45 * time.Minute, expected: "45m"},
+		{duration: 2 * time.Hour, expected: "2h"},
+		{duration: 3*time.Hour + 5*time.Minute + 40*time.Second, expected: "3h 6m"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatStreamDuration(tc.duration))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package event
+
+import (
+	"fmt"
+	"log"
+	"shrampybot/config"
+	"shrampybot/connector/discord"
+	"shrampybot/connector/twitch"
+	"shrampybot/utility/nosqldb"
+	"time"
+)
+
+const (
+	// Give up re-creating after this many attempts without a verification
+	maxRecreateAttempts = 3
+)
+
+var (
+	// Revocation reasons where subscribing again can be expected to work.
+	// The others (user_removed, authorization_revoked, version_removed,
+	// moderator_removed) need a person to look at them first.
+	recreatableRevocations = []string{
+		"notification_failures_exceeded",
+	}
+)
+
+// Marks a subscription as working once Twitch has verified our callback
+func recordSubscriptionVerified(sub *twitch.Subscription) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		return
+	}
+	n.RecordEventsubVerification(subscriptionLifecycle(sub))
+
+	broadcasterId := sub.BroadcasterId()
+	if broadcasterId == "" {
+		return
+	}
+
+	state, err := n.GetEventsubSubscription(broadcasterId, sub.Type)
+	if err != nil {
+		log.Printf("Could not retrieve subscription state: %v\n", err)
+		return
+	}
+	state.BroadcasterId = broadcasterId
+	state.Type = sub.Type
+	state.Version = sub.Version
+	state.SubscriptionId = sub.Id
+	state.Status = "enabled"
+	state.VerifiedAt = time.Now()
+	state.RecreateAttempts = 0
+	state.LastRecreateError = ""
+	n.PutEventsubSubscription(state)
+}
+
+// Counts a notification against its subscription
+func recordSubscriptionNotification(sub *twitch.Subscription, messageId string) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		return
+	}
+	n.RecordEventsubNotification(subscriptionLifecycle(sub), messageId)
+}
+
+// Records a revocation, lets the admins know and subscribes again if the
+// reason allows it. Exported for the websocket client, which gets
+// revocations as messages rather than webhooks.
+func HandleRevocation(sub *twitch.Subscription) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		return
+	}
+	n.RecordEventsubRevocation(subscriptionLifecycle(sub), sub.Status)
+
+	broadcasterId := sub.BroadcasterId()
+	if broadcasterId == "" {
+		log.Printf("Revoked subscription %v has no broadcaster; nothing more to record.\n", sub.Id)
+		return
+	}
+
+	state, err := n.GetEventsubSubscription(broadcasterId, sub.Type)
+	if err != nil {
+		log.Printf("Could not retrieve subscription state: %v\n", err)
+		return
+	}
+	state.BroadcasterId = broadcasterId
+	state.Type = sub.Type
+	state.Version = sub.Version
+	state.SubscriptionId = sub.Id
+	state.Status = sub.Status
+	state.RevokedAt = time.Now()
+	state.RevocationReason = sub.Status
+
+	user, err := n.GetTwitchUser(broadcasterId)
+	if err != nil {
+		log.Printf("Could not find user record for %v\n", broadcasterId)
+	}
+	userName := broadcasterId
+	if user != nil && user.Login != "" {
+		userName = user.Login
+	}
+
+	outcome := "Not re-subscribing automatically; run `shrampysubs -t reconcile` once resolved."
+	recreatable := false
+	for _, reason := range recreatableRevocations {
+		if reason == sub.Status {
+			recreatable = true
+		}
+	}
+	switch {
+	case !recreatable:
+	case user == nil || !user.ShrampybotActive:
+		outcome = "User is no longer active; not re-subscribing."
+	case state.RecreateAttempts >= maxRecreateAttempts:
+		outcome = fmt.Sprintf("Gave up re-subscribing after %v attempts.", state.RecreateAttempts)
+	default:
+		state.RecreateAttempts++
+		newId, status, err := recreateSubscription(sub)
+		if err != nil {
+			log.Printf("Could not re-create subscription: %v\n", err)
+			state.LastRecreateError = err.Error()
+			outcome = fmt.Sprintf("Re-subscribing failed: %v", err)
+		} else {
+			state.SubscriptionId = newId
+			state.Status = status
+			state.LastRecreateError = ""
+			outcome = fmt.Sprintf("Re-subscribed as %v (attempt %v).", newId, state.RecreateAttempts)
+		}
+	}
+	n.PutEventsubSubscription(state)
+
+	msg := fmt.Sprintf(
+		"Twitch revoked the **%v** subscription for **%v** (%v).\n%v",
+		sub.Type,
+		userName,
+		sub.Status,
+		outcome,
+	)
+	log.Println(msg)
+	d, err := discord.NewBotClient()
+	if err != nil {
+		log.Printf("Could not connect to Discord to alert admins: %v\n", err)
+		return
+	}
+	err = d.AlertAdmins(msg)
+	if err != nil {
+		log.Printf("Could not alert admins: %v\n", err)
+	}
+}
+
+// Subscribes again the same way the revoked subscription was delivered.
+// Returns the new subscription's id and status.
+func recreateSubscription(sub *twitch.Subscription) (string, string, error) {
+	t, err := twitch.NewClient()
+	if err != nil {
+		return "", "", err
+	}
+
+	if sub.Transport != nil && sub.Transport.Method == twitch.TransportConduit {
+		return t.Conduits().Subscribe(sub.Type, sub.Version, sub.BroadcasterId(), sub.Transport.ConduitId)
+	}
+
+	callback := config.EventsubUrl
+	if sub.Transport != nil && sub.Transport.Callback != "" {
+		callback = sub.Transport.Callback
+	}
+	if callback == "" {
+		return "", "", fmt.Errorf("no callback url known for %v", sub.Id)
+	}
+
+	id, err := t.CreateEventSubSubscription(sub.Type, sub.Version, sub.BroadcasterId(), callback)
+	return id, "webhook_callback_verification_pending", err
+}
+
+// The subscription metadata Twitch sends with every message
+func subscriptionLifecycle(sub *twitch.Subscription) *nosqldb.EventsubLifecycleDatum {
+	return &nosqldb.EventsubLifecycleDatum{
+		Id:            sub.Id,
+		BroadcasterId: sub.BroadcasterId(),
+		Type:          sub.Type,
+		Version:       sub.Version,
+		Condition:     sub.Condition,
+		Cost:          sub.Cost,
+		CreatedAt:     sub.CreatedAt,
+	}
+}
@@ -7,13 +7,11 @@ import (
 	"log"
 	"regexp"
 	"shrampybot/config"
-	"shrampybot/connector/bluesky"
-	"shrampybot/connector/discord"
-	"shrampybot/connector/mastodon"
+	"shrampybot/connector"
 	"shrampybot/connector/twitch"
 	"shrampybot/router"
-	"shrampybot/utility"
 	"shrampybot/utility/nosqldb"
+	"shrampybot/utility/queue"
 	"strconv"
 	"strings"
 	"time"
@@ -22,9 +20,11 @@ import (
 )
 
 var (
-	eventMap = map[string]func(sub *twitch.Subscription, event *map[string]string) error{
+	eventMap = map[string]func(p *Pipeline, sub *twitch.Subscription, event *map[string]any) error{
 		"stream.online":  streamOnlineCallback,
 		"stream.offline": streamOfflineCallback,
+		"channel.update": channelUpdateCallback,
+		"channel.raid":   channelRaidCallback,
 	}
 )
 
@@ -82,12 +82,24 @@ func (c *WebhookView) Post(route *router.Route) *router.Response {
 		doNotProcess = true
 	} else {
 		// Record new eventsub message for duplicate checking
+		headers := route.Router.Event.Headers
 		n, _ := nosqldb.NewClient()
 		n.PutEventsubMessage(&nosqldb.EventsubMessageDatum{
-			Id:    route.Router.Event.Headers.TwitchEventsubMessageId,
-			Time:  route.Router.Event.Headers.TwitchEventsubMessageTimestamp,
-			Type:  route.Router.Event.Headers.TwitchEventsubMessageType,
-			Retry: route.Router.Event.Headers.TwitchEventsubMessageRetry,
+			Id:               headers.TwitchEventsubMessageId,
+			Time:             headers.TwitchEventsubMessageTimestamp,
+			Type:             headers.TwitchEventsubMessageType,
+			Retry:            headers.TwitchEventsubMessageRetry,
+			SubscriptionType: headers.TwitchEventsubSubscriptionType,
+			// The signature is left out as it's no use once verified
+			Headers: map[string]string{
+				"twitch-eventsub-message-id":           headers.TwitchEventsubMessageId,
+				"twitch-eventsub-message-retry":        headers.TwitchEventsubMessageRetry,
+				"twitch-eventsub-message-timestamp":    headers.TwitchEventsubMessageTimestamp,
+				"twitch-eventsub-message-type":         headers.TwitchEventsubMessageType,
+				"twitch-eventsub-subscription-type":    headers.TwitchEventsubSubscriptionType,
+				"twitch-eventsub-subscription-version": headers.TwitchEventsubSubscriptionVersion,
+			},
+			Body: route.Router.Event.Body,
 		})
 	}
 	// Continue running so our responses align, but doNotProcess should
@@ -99,13 +111,15 @@ func (c *WebhookView) Post(route *router.Route) *router.Response {
 	case "webhook_callback_verification":
 		requestBody := twitch.ChallengeWebhook{}
 		json.Unmarshal([]byte(route.Body), &requestBody)
-		// sub = requestBody.Subscription
+		sub = requestBody.Subscription
 
 		log.Println("Received webhook_callback_verification request.")
 		response.Body = requestBody.Challenge
 		response.StatusCode = "200"
 
-		// TODO: Record
+		if !doNotProcess && sub != nil {
+			recordSubscriptionVerified(sub)
+		}
 
 	case "revocation":
 		requestBody := twitch.RevocationWebhook{}
@@ -114,6 +128,10 @@ func (c *WebhookView) Post(route *router.Route) *router.Response {
 
 		log.Printf("Received revocation request: %v\n", sub)
 
+		if !doNotProcess && sub != nil {
+			HandleRevocation(sub)
+		}
+
 	case "notification":
 		requestBody := twitch.NotificationWebhook{}
 		json.Unmarshal([]byte(route.Router.Event.Body), &requestBody)
@@ -122,12 +140,21 @@ func (c *WebhookView) Post(route *router.Route) *router.Response {
 		log.Printf("Received notification: %v\n", sub.Type)
 
 		if !doNotProcess {
-			log.Println("Processing event notification.")
-			for subType, callback := range eventMap {
-				if subType == sub.Type {
-					callback(sub, requestBody.Event)
-					break
-				}
+			recordSubscriptionNotification(sub, route.Router.Event.Headers.TwitchEventsubMessageId)
+
+			log.Println("Queueing event notification.")
+			err := EnqueueNotification(
+				queue.DefaultBackend(),
+				route.Router.Event.Headers.TwitchEventsubMessageId,
+				sub,
+				requestBody.Event,
+			)
+			if err != nil {
+				// Better late than never; handle it here as we used to
+				log.Printf("Could not queue notification, processing it now: %v\n", err)
+				DispatchNotification(sub, requestBody.Event)
+			} else {
+				DrainQueue(route.Router.Context())
 			}
 		} else {
 			log.Println("Not processing notification due to duplicate notice.")
@@ -137,7 +164,18 @@ func (c *WebhookView) Post(route *router.Route) *router.Response {
 	return &response
 }
 
-func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string) error {
+// Hands a notification to the callback for its subscription type. Shared by
+// the webhook and websocket transports.
+func DispatchNotification(sub *twitch.Subscription, event *map[string]any) error {
+	p, err := NewPipeline(PipelineModeFull)
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		return err
+	}
+	return p.Dispatch(sub, event)
+}
+
+func streamOnlineCallback(p *Pipeline, sub *twitch.Subscription, eventMap *map[string]any) error {
 	log.Println("Entered streamOnlineCallback")
 
 	// Unmarshal event data into helix struct
@@ -146,7 +184,7 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 	json.Unmarshal(evBytes, &event)
 
 	// Connect to the systems we'll need for lookups/storage
-	n, _ := nosqldb.NewClient()
+	n := p.n
 	t, err := twitch.NewClient()
 	if err != nil {
 		log.Println("Could not connect to Twitch API. Can't continue.")
@@ -174,15 +212,9 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 		log.Printf("Couldn't retrieve latest stream: %v\n", err)
 		return err
 	}
-	if rStream != nil && rStream.ID != "" {
-		debounceInterval, _ := strconv.Atoi(config.StreamupDebounceInterval)
-		debounceTime := time.Now().Add(-(time.Duration(debounceInterval) * time.Second))
-
-		// Check if the last stream ended less than n seconds ago and mark it for debounce
-		if rStream.EndedAt.After(debounceTime) {
-			log.Printf("Last stream ended less than %v seconds ago. Marking for debounce.\n", config.StreamupDebounceInterval)
-			needsDebounce = true
-		}
+	if shouldDebounce(rStream) {
+		log.Printf("Last stream ended less than %v seconds ago. Marking for debounce.\n", config.StreamupDebounceInterval)
+		needsDebounce = true
 	}
 
 	// Contact Twitch for actual stream info
@@ -193,6 +225,10 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 		log.Printf("Error fetching stream from twitch.")
 		return err
 	}
+	if tStream.ID == "" {
+		// Twitch's API can lag behind the notification; the queue will retry
+		return fmt.Errorf("stream for %v is not visible on twitch yet", user.Login)
+	}
 
 	// Lookup stream in our history using Twitch stream ID
 	stream, err := n.GetStream(tStream.ID)
@@ -220,6 +256,7 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 
 				// Save here
 				n.PutStream(rStream)
+				n.CloseStreamSegments(rStream.ID, rStream.EndedAt)
 			}
 		}
 
@@ -257,16 +294,28 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 
 					// Save here
 					n.PutStream(rStream)
+					n.CloseStreamSegments(rStream.ID, rStream.EndedAt)
 				}
 			}
 		}
 
+		// A stream that was stored but never announced means an earlier
+		// attempt failed part way through, so carry on from there.
+		if !stream.Announced() && !stream.ShrampybotDebounced && stream.EndedAt.IsZero() {
+			log.Println("Found unannounced stream in our history. Retrying announcement.")
+			return announceStream(p, user, stream)
+		}
+
 		// If stream is already in our history then we've sent a notice
 		// for it already. Stop processing.
 		log.Println("Found duplicate stream in our history. Stopping processing.")
 		return nil
 	}
 
+	// Debounced streams are a continuation of the last one, so they never
+	// get announced, even if they change category later on.
+	stream.ShrampybotDebounced = needsDebounce
+
 	// Add/update stream information in table
 	// We do this ASAP so that we can debounce if duplicate notices come in
 	err = n.PutStream(stream)
@@ -274,6 +323,11 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 		log.Println("Could not save stream information to table. Stopping processing.")
 		return err
 	}
+	err = n.AdvanceStreamSegment(stream, stream.StartedAt, "online")
+	if err != nil {
+		log.Printf("Could not start timeline for stream %v: %v\n", stream.ID, err)
+	}
+	p.notifyWebhooks(nosqldb.WebhookEventOnline, stream)
 
 	// Check if we caught a debounce check and return if so.
 	if needsDebounce {
@@ -281,90 +335,175 @@ func streamOnlineCallback(sub *twitch.Subscription, eventMap *map[string]string)
 		return nil
 	}
 
+	return announceStream(p, user, stream)
+}
+
+// Whether a stream starting now follows on too closely from the previous
+// one to be announced
+func shouldDebounce(previous *nosqldb.StreamHistoryDatum) bool {
+	if previous == nil || previous.ID == "" {
+		return false
+	}
+	debounceInterval, _ := strconv.Atoi(config.StreamupDebounceInterval)
+	debounceTime := time.Now().Add(-(time.Duration(debounceInterval) * time.Second))
+
+	// Check if the last stream ended less than n seconds ago
+	return previous.EndedAt.After(debounceTime)
+}
+
+type announcementCheck struct {
+	// Nil when the stream's category isn't mapped
+	category *nosqldb.CategoryDatum
+	filtered bool
+	// Why the stream won't be announced; blank if it will
+	reason string
+}
+
+// Matches a stream's category and runs its title and tags through the
+// keyword filters
+func checkAnnouncement(n *nosqldb.NoSqlDb, stream *nosqldb.StreamHistoryDatum) (*announcementCheck, error) {
+	check := announcementCheck{}
+
 	// Filtering by category before announcing
 	category, err := n.GetCategoryByName(stream.GameName)
 	if err != nil {
 		log.Printf("Error looking for category %v in table: %v\n", stream.GameName, err)
-		return err
+		return nil, err
 	}
 	if category == nil || category.Id == "" {
-		log.Printf("Category %v is not in our map. Stopping processing.\n", stream.GameName)
-		return nil
+		check.reason = fmt.Sprintf("Category %v is not in our map", stream.GameName)
+		return &check, nil
 	}
+	check.category = category
 
-	// Stop processing if keyword matches
-	// This is AFTER saving to the stream table because it prevents an update edge case from occurring
-	// when the stream goes offline
-	if checkKeywordFilter(tStream.Title, n) {
-		log.Printf("Found banned keyword in title \"%v\". Stopping processing.\n", tStream.Title)
-
-		stream.ShrampybotFiltered = true
-		err = n.PutStream(stream)
-		if err != nil {
-			log.Printf("Failed to update stream filtered flag.")
-		}
-
-		return nil
+	if checkKeywordFilter(stream.Title, n) {
+		check.filtered = true
+		check.reason = fmt.Sprintf("Found banned keyword in title \"%v\"", stream.Title)
+		return &check, nil
 	}
 
 	// Search through tags for keyword matches as well
-	for _, tag := range tStream.Tags {
+	for _, tag := range stream.Tags {
 		if checkKeywordFilter(tag, n) {
-			log.Printf("Found banned keyword in tag \"%v\". Stopping processing.\n", tag)
-			return nil
+			check.filtered = true
+			check.reason = fmt.Sprintf("Found banned keyword in tag \"%v\"", tag)
+			return &check, nil
 		}
 	}
 
-	// Fetch image data to use in each social media post
-	previewImage := &utility.Image{}
-	altText := fmt.Sprintf("Preview of %v's stream on Twitch.", user.DisplayName)
-	dimensions := strings.Split(config.StreamThumbResolution, "x")
-	width, _ := strconv.Atoi(dimensions[0])
-	height, _ := strconv.Atoi(dimensions[1])
+	return &check, nil
+}
 
-	previewImage, _ = utility.NewFromThumbnailURL(
-		stream.ThumbnailURL,
-		width,
-		height,
-		altText,
-	)
+// Why a member's own preferences hold back announcing a stream; blank if
+// they don't. Judged on when the stream started, so a stream that began in
+// quiet hours stays unannounced even if its category changes later.
+func preferenceSuppression(n *nosqldb.NoSqlDb, user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum) (string, error) {
+	prefs := user.AnnouncementPreferences()
+	if prefs.InQuietHours(stream.StartedAt) {
+		return fmt.Sprintf("Stream started during %v's quiet hours", user.Login), nil
+	}
 
-	log.Printf("Starting message post goroutines.")
+	if prefs.MinGapMinutes > 0 {
+		last, err := n.GetLatestAnnouncedStreamByUserId(user.ID, stream.ID)
+		if err != nil {
+			return "", err
+		}
+		if prefs.WithinMinGap(last.StartedAt, stream.StartedAt) {
+			return fmt.Sprintf("Stream started within %v minutes of %v's last announced stream", prefs.MinGapMinutes, user.Login), nil
+		}
+	}
 
-	postChan := make(chan utility.PostResponse)
-	go discordPostRoutine(stream, previewImage, postChan)
-	go mastodonPostRoutine(user, stream, category, previewImage, postChan)
-	go blueskyPostRoutine(stream, category, previewImage, postChan)
+	return "", nil
+}
 
-	postRoutines := 3 // increase based on number of goroutines above
-	for i := 0; i < postRoutines; i++ {
-		resp := <-postChan
-		switch resp.Platform {
+// Registered platforms the member hasn't turned off
+func announcementPlatforms(user *nosqldb.TwitchUserDatum) []string {
+	prefs := user.AnnouncementPreferences()
+	names := []string{}
+	for _, name := range connector.PublisherNames() {
+		if prefs.PlatformEnabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
 
-		case discord.PlatformName:
-			stream.DiscordPostId = resp.Id
-			stream.DiscordPostUrl = resp.Url
+// Announces a live stream if its category is mapped and it passes the
+// filters. Called when a stream goes online and again whenever an
+// unannounced stream changes its title or category.
+func announceStream(p *Pipeline, user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum) error {
+	var err error
+	n := p.n
 
-		case bluesky.PlatformName:
-			stream.BlueskyPostId = resp.Id
-			stream.BlueskyPostUrl = resp.Url
+	check, err := checkAnnouncement(n, stream)
+	if err != nil {
+		return err
+	}
+	if check.reason != "" {
+		log.Printf("%v. Stopping processing.\n", check.reason)
+	}
+	if check.category == nil {
+		return nil
+	}
+	category := check.category
 
-		case mastodon.PlatformName:
-			stream.MastodonPostId = resp.Id
-			stream.MastodonPostUrl = resp.Url
+	// Keep the flag current, since a later title change can clear it
+	if check.filtered != stream.ShrampybotFiltered {
+		stream.ShrampybotFiltered = check.filtered
+		err = n.PutStream(stream)
+		if err != nil {
+			log.Printf("Failed to update stream filtered flag.")
+		}
+		if check.filtered {
+			p.notifyWebhooks(nosqldb.WebhookEventFiltered, stream)
 		}
 	}
+	if check.filtered {
+		return nil
+	}
 
-	err = n.PutStream(stream)
+	reason, err := preferenceSuppression(n, user, stream)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		log.Printf("%v. Stopping processing.\n", reason)
+		return nil
+	}
+
+	// Make sure no other notification is already announcing this stream
+	claimed, err := n.ClaimStreamAnnouncement(stream.ID)
 	if err != nil {
-		log.Printf("Failed to write stream updates after posting.")
 		return err
 	}
+	if !claimed {
+		log.Printf("Stream %v has already been announced. Stopping processing.\n", stream.ID)
+		return nil
+	}
+	stream.ShrampybotAnnounced = true
+	platforms := announcementPlatforms(user)
+	p.recordPendingDeliveries(stream, platforms)
+
+	log.Printf("Starting message posts.")
+	posts, failures := p.publish(
+		platforms,
+		announcementFormatter(n, user, stream, category),
+		streamPreviewImage(user, stream),
+		&announcedStream{user: user, stream: stream, category: category},
+	)
+	p.recordDeliveries(stream, posts, failures)
+	// Retries read the stream back, where failed platforms are at worst
+	// still pending, so they go ahead even if recording a failure didn't
+	p.scheduleAnnouncementRetries(stream.ID, failures)
+
+	if len(posts) > 0 {
+		p.notifyWebhooks(nosqldb.WebhookEventAnnounced, stream)
+	}
 
 	return nil
 }
 
-func streamOfflineCallback(sub *twitch.Subscription, eventMap *map[string]string) error {
+func streamOfflineCallback(p *Pipeline, sub *twitch.Subscription, eventMap *map[string]any) error {
 	var err error
 	log.Println("Entered streamOfflineCallback")
 
@@ -373,8 +512,7 @@ func streamOfflineCallback(sub *twitch.Subscription, eventMap *map[string]string
 	evBytes, _ := json.Marshal(eventMap)
 	json.Unmarshal(evBytes, &event)
 
-	// Connect to DynamoDB
-	n, _ := nosqldb.NewClient()
+	n := p.n
 
 	// Fetch latest stream for user from db
 	stream, err := n.GetLatestStreamByUserId(event.BroadcasterUserID)
@@ -395,81 +533,91 @@ func streamOfflineCallback(sub *twitch.Subscription, eventMap *map[string]string
 		log.Printf("Error writing to stream %v.\n", stream.ID)
 		return err
 	}
+	err = n.CloseStreamSegments(stream.ID, stream.EndedAt)
+	if err != nil {
+		log.Printf("Could not close timeline for stream %v: %v\n", stream.ID, err)
+	}
+	p.notifyWebhooks(nosqldb.WebhookEventOffline, stream)
+
+	if len(stream.Posts) == 0 {
+		return nil
+	}
+	user, err := n.GetTwitchUser(event.BroadcasterUserID)
+	if err != nil {
+		log.Printf("Could not get user %v to update their announcements: %v\n", event.BroadcasterUserLogin, err)
+		return err
+	}
+	p.endAnnouncements(user, stream)
+	err = n.PutStream(stream)
+	if err != nil {
+		log.Printf("Failed to write announcement changes to stream %v.\n", stream.ID)
+		return err
+	}
 
 	return nil
 }
 
-func messageIsDuplicate(messageId string) bool {
-	// Instantiate DynamoDB
-	n, _ := nosqldb.NewClient()
-	eventsub, _ := n.GetEventsubMessage(messageId)
+// Records title and category changes on live streams, and announces
+// streams which have only now moved into a mapped category.
+func channelUpdateCallback(p *Pipeline, sub *twitch.Subscription, eventMap *map[string]any) error {
+	var err error
+	log.Println("Entered channelUpdateCallback")
 
-	return eventsub.Id != ""
-}
+	// Unmarshal event data into helix struct
+	event := helix.EventSubChannelUpdateEvent{}
+	evBytes, _ := json.Marshal(eventMap)
+	json.Unmarshal(evBytes, &event)
+
+	n := p.n
 
-func discordPostRoutine(stream *nosqldb.StreamHistoryDatum, image *utility.Image, c chan utility.PostResponse) {
-	dc, _ := discord.NewBotClient()
-	streamUrl := fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin)
-	resp, err := dc.Post(dc.FormatMsg(
-		stream.UserName,
-		stream.GameName,
-		stream.Title,
-		streamUrl,
-	), image)
+	stream, err := n.GetLatestStreamByUserId(event.BroadcasterUserID)
 	if err != nil {
-		log.Printf("Error posting to discord: %v\n", err)
+		log.Printf("Error getting recent history record for user %v: %v\n", event.BroadcasterUserLogin, err)
+		return err
+	}
+	if stream == nil || stream.ID == "" || !stream.EndedAt.IsZero() {
+		log.Printf("%v is not live. Nothing to update.\n", event.BroadcasterUserLogin)
+		return nil
 	}
 
-	c <- *resp
-}
-
-func blueskyPostRoutine(stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum, image *utility.Image, c chan utility.PostResponse) {
-	bc, _ := bluesky.NewClient()
-	streamUrl := fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin)
-
-	msg := fmt.Sprintf(
-		"%v is now streaming %v on Twitch: %v\n\n%v\n\n%v",
-		stream.UserName,
-		stream.GameName,
-		streamUrl,
-		stream.Title,
-		strings.Join(category.BlueskyTags, " "),
-	)
+	if stream.Title == event.Title && stream.GameID == event.CategoryID {
+		log.Println("No title or category change. Stopping processing.")
+		return nil
+	}
+	stream.Title = event.Title
+	stream.GameID = event.CategoryID
+	stream.GameName = event.CategoryName
 
-	resp, err := bc.Post(msg, image)
+	err = n.PutStream(stream)
 	if err != nil {
-		log.Printf("Error posting to bluesky: %v\n", err)
+		log.Printf("Error writing to stream %v.\n", stream.ID)
+		return err
+	}
+	err = n.AdvanceStreamSegment(stream, time.Now(), "channel.update")
+	if err != nil {
+		log.Printf("Could not update timeline for stream %v: %v\n", stream.ID, err)
 	}
 
-	c <- *resp
-}
-
-func mastodonPostRoutine(user *nosqldb.TwitchUserDatum, stream *nosqldb.StreamHistoryDatum, category *nosqldb.CategoryDatum, image *utility.Image, c chan utility.PostResponse) {
-	mc, _ := mastodon.NewClient()
-	streamUrl := fmt.Sprintf("https://twitch.tv/%v", stream.UserLogin)
+	if stream.Announced() || stream.ShrampybotDebounced {
+		return nil
+	}
 
-	streamer := ""
-	if user.MastodonUserId != "" {
-		streamer = fmt.Sprintf("@%v", user.MastodonUserId)
-	} else {
-		streamer = stream.UserName
+	user, err := n.GetTwitchUser(event.BroadcasterUserID)
+	if err != nil || user.ID == "" {
+		log.Printf("Could not find user record for %v\n", event.BroadcasterUserID)
+		return err
 	}
 
-	msg := fmt.Sprintf(
-		"%v is now streaming %v on Twitch: %v\n\n%v\n\n%v",
-		streamer,
-		stream.GameName,
-		streamUrl,
-		stream.Title,
-		strings.Join(category.MastodonTags, " "),
-	)
+	log.Printf("Unannounced stream %v changed to %v; checking whether to announce.\n", stream.ID, stream.GameName)
+	return announceStream(p, user, stream)
+}
 
-	resp, err := mc.Post(msg, image)
-	if err != nil {
-		log.Printf("Error posting to mastodon: %v\n", err)
-	}
+func messageIsDuplicate(messageId string) bool {
+	// Instantiate DynamoDB
+	n, _ := nosqldb.NewClient()
+	eventsub, _ := n.GetEventsubMessage(messageId)
 
-	c <- *resp
+	return eventsub.Id != ""
 }
 
 func checkKeywordFilter(title string, db *nosqldb.NoSqlDb) bool {
@@ -21,6 +21,11 @@ func GSGController(route *router.Route) *router.Response {
 	scopes := route.Router.Event.Scopes
 
 	switch route.Path[1] {
+	case "preferences":
+		if utility.MatchScope(scopes, "gsg:streamer") {
+			c := NewPreferencesView()
+			return c.CallMethod(route)
+		}
 	case "streamer":
 		if utility.MatchScope(scopes, "gsg:streamer") {
 			c := NewStreamerView()
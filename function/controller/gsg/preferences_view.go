@@ -0,0 +1,156 @@
+package gsg
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/connector"
+	"shrampybot/router"
+	"shrampybot/utility"
+	"shrampybot/utility/nosqldb"
+
+	// Connectors register themselves as publishers when imported
+	_ "shrampybot/connector/bluesky"
+	_ "shrampybot/connector/discord"
+	_ "shrampybot/connector/mastodon"
+)
+
+// Lets members manage how their own streams are announced
+type PreferencesView struct {
+	router.View `tstype:",extends,required"`
+}
+
+type PreferencesBody struct {
+	Preferences *nosqldb.AnnouncementPreferences `json:"preferences"`
+	// Platforms that can be turned on or off
+	Platforms []string `json:"platforms"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func NewPreferencesView() *PreferencesView {
+	c := PreferencesView{}
+	return &c
+}
+
+func (v *PreferencesView) CallMethod(route *router.Route) *router.Response {
+	switch route.Method {
+	case "GET":
+		return v.Get(route)
+	case "POST":
+		return v.Post(route)
+	case "PUT":
+		return v.Put(route)
+	case "PATCH":
+		return v.Patch(route)
+	case "DELETE":
+		return v.Delete(route)
+	}
+
+	return router.NewResponse(router.GenericBodyDataFlat{}, "500")
+}
+
+// The Twitch user behind the request, whichever provider they logged in with
+func selfTwitchUser(route *router.Route, n *nosqldb.NoSqlDb) (*nosqldb.TwitchUserDatum, error) {
+	sub, _ := route.Router.Event.Claims["sub"].(string)
+	if twitchId, isTwitch := utility.TwitchIdFromSubject(sub); isTwitch {
+		return n.GetTwitchUser(twitchId)
+	}
+	return n.GetTwitchUserByDiscordId(sub)
+}
+
+func (v *PreferencesView) Get(route *router.Route) *router.Response {
+	log.Println("Entered route: GSG.Preferences.Get")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	user, err := selfTwitchUser(route, n)
+	if err != nil {
+		log.Printf("Could not look up Twitch user for request: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	if user.ID == "" {
+		log.Println("No Twitch user is linked to this login.")
+		response.StatusCode = "404"
+		return response
+	}
+
+	respBody := PreferencesBody{
+		Preferences: user.AnnouncementPreferences(),
+		Platforms:   connector.PublisherNames(),
+	}
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: GSG.Preferences.Get")
+	return response
+}
+
+// Replaces the member's preferences
+func (v *PreferencesView) Put(route *router.Route) *router.Response {
+	log.Println("Entered route: GSG.Preferences.Put")
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	prefs := nosqldb.AnnouncementPreferences{}
+	err := json.Unmarshal([]byte(route.Body), &prefs)
+	if err != nil {
+		log.Printf("Could not unmarshal body json: %v\n", err)
+		response.StatusCode = "400"
+		return response
+	}
+
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		log.Println("Could not instantiate dynamodb.")
+		response.StatusCode = "500"
+		return response
+	}
+
+	user, err := selfTwitchUser(route, n)
+	if err != nil {
+		log.Printf("Could not look up Twitch user for request: %v\n", err)
+		response.StatusCode = "500"
+		return response
+	}
+	if user.ID == "" {
+		log.Println("No Twitch user is linked to this login.")
+		response.StatusCode = "404"
+		return response
+	}
+
+	respBody := PreferencesBody{
+		Preferences: &prefs,
+		Platforms:   connector.PublisherNames(),
+	}
+	err = prefs.Validate(respBody.Platforms)
+	if err != nil {
+		respBody.Error = err.Error()
+		bodyBytes, _ := json.Marshal(respBody)
+		response.Body = string(bodyBytes)
+		response.StatusCode = "400"
+		return response
+	}
+
+	user.ShrampybotAnnouncementPreferences = &prefs
+	err = n.PutTwitchUser(user)
+	if err != nil {
+		log.Printf("Could not save preferences for %v: %v\n", user.Login, err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	bodyBytes, _ := json.Marshal(respBody)
+
+	response.Body = string(bodyBytes)
+	response.StatusCode = "200"
+	log.Println("Exited route: GSG.Preferences.Put")
+	return response
+}
@@ -6,7 +6,9 @@ import (
 	"shrampybot/router"
 	"shrampybot/utility/nosqldb"
 	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
 type StreamView struct {
@@ -18,6 +20,19 @@ type StreamBody struct {
 	Data                       *[]nosqldb.StreamHistoryDatum `json:"data" tstype:"nosqldb.StreamHistoryDatum[]"`
 }
 
+type StreamTimelineBody struct {
+	StreamId       string                        `json:"stream_id"`
+	Segments       []*nosqldb.StreamSegmentDatum `json:"segments"`
+	CategoryTotals []*StreamCategoryTotal        `json:"category_totals"`
+}
+
+// Time spent in one category over the course of a stream
+type StreamCategoryTotal struct {
+	GameID   string `json:"game_id"`
+	GameName string `json:"game_name"`
+	Seconds  int64  `json:"seconds"`
+}
+
 func NewStreamView() *StreamView {
 	c := StreamView{}
 	return &c
@@ -53,6 +68,10 @@ func (v *StreamView) Get(route *router.Route) *router.Response {
 		return response
 	}
 
+	if len(route.Path) == 4 && route.Path[3] == "timeline" {
+		return v.getTimeline(route, n)
+	}
+
 	streams := []nosqldb.StreamHistoryDatum{}
 	if len(route.Path) == 3 {
 		// Get single result
@@ -114,3 +133,58 @@ func (v *StreamView) Get(route *router.Route) *router.Response {
 	log.Println("Exited route: Public.Stream.Get")
 	return response
 }
+
+// Segments of a single stream, along with the time spent in each category
+func (v *StreamView) getTimeline(route *router.Route, n *nosqldb.NoSqlDb) *router.Response {
+	response := &router.Response{}
+	response.Headers = &router.DefaultResponseHeaders
+
+	stream, err := n.GetStream(route.Path[2])
+	if err != nil {
+		log.Printf("Get stream [%v] failed.", route.Path[2])
+		response.StatusCode = "500"
+		return response
+	}
+	// Filtered streams are never shown publicly
+	if stream.ID == "" || stream.ShrampybotFiltered {
+		response.StatusCode = "404"
+		return response
+	}
+
+	segments, err := n.GetStreamSegments(stream.ID)
+	if err != nil {
+		log.Printf("Could not get segments for stream %v: %v\n", stream.ID, err)
+		response.StatusCode = "500"
+		return response
+	}
+
+	body := StreamTimelineBody{
+		StreamId:       stream.ID,
+		Segments:       segments,
+		CategoryTotals: []*StreamCategoryTotal{},
+	}
+	now := time.Now()
+	totals := map[string]*StreamCategoryTotal{}
+	for _, segment := range segments {
+		total, ok := totals[segment.GameID]
+		if !ok {
+			total = &StreamCategoryTotal{
+				GameID:   segment.GameID,
+				GameName: segment.GameName,
+			}
+			totals[segment.GameID] = total
+			body.CategoryTotals = append(body.CategoryTotals, total)
+		}
+		total.Seconds += int64(segment.Duration(now).Seconds())
+	}
+	sort.SliceStable(body.CategoryTotals, func(i, j int) bool {
+		return body.CategoryTotals[i].Seconds > body.CategoryTotals[j].Seconds
+	})
+
+	bodyBytes, _ := json.Marshal(body)
+
+	response.StatusCode = "200"
+	response.Body = string(bodyBytes)
+	log.Println("Exited route: Public.Stream.Get")
+	return response
+}
@@ -14,6 +14,13 @@ import (
 )
 
 func Main(ctx context.Context, ev map[string]any) (router.AWSResponse, error) {
+	// Anything other than an HTTP request (the schedule, or the event queue
+	// table's stream) is a nudge to work through queued events
+	if _, isHttp := ev["requestContext"]; !isHttp {
+		event.RunQueueWorker(ctx)
+		return router.AWSResponse{}, nil
+	}
+
 	var evnt router.Event
 	evBytes, _ := json.Marshal(ev)
 	// Uncomment if there's a need to log headers
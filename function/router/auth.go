@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"shrampybot/config"
+	"shrampybot/utility"
 	"shrampybot/utility/nosqldb"
 	"strings"
 	"time"
@@ -94,6 +95,9 @@ func (e *Event) CheckAuthorizationJWT() bool {
 	if !slices.Contains(scopes, "login") {
 		return false
 	}
+	if static != nil && !e.checkStaticTokenBounds(static, n) {
+		return false
+	}
 
 	e.Token = token
 	e.Claims = claims
@@ -101,6 +105,31 @@ func (e *Event) CheckAuthorizationJWT() bool {
 	return true
 }
 
+// Restricts static tokens to their allowed CIDR ranges and origins, if any,
+// and keeps track of rejected uses.
+func (e *Event) checkStaticTokenBounds(static *nosqldb.StaticTokenDatum, n *nosqldb.NoSqlDb) bool {
+	sourceIp := ""
+	if e.RequestContext != nil && e.RequestContext.Http != nil {
+		sourceIp = e.RequestContext.Http.SourceIp
+	}
+	origin := e.Headers.Origin
+
+	reason := ""
+	if len(static.AllowedCidrs) > 0 && !utility.AddressInCidrs(sourceIp, static.AllowedCidrs) {
+		reason = "source ip not allowed"
+	} else if len(static.AllowedOrigins) > 0 && !utility.OriginAllowed(origin, static.AllowedOrigins) {
+		reason = "origin not allowed"
+	}
+
+	if reason != "" {
+		log.Printf("Rejected static token %v from %v (origin %v): %v\n", static.Id, sourceIp, origin, reason)
+		n.RecordStaticTokenRejection(static.Id, sourceIp, origin, reason)
+		return false
+	}
+
+	return true
+}
+
 func (e *Event) calculateSHA256Signature() string {
 	combined := e.Headers.TwitchEventsubMessageId +
 		e.Headers.TwitchEventsubMessageTimestamp +
@@ -108,6 +108,14 @@ func NewRouter(ctx *context.Context, event *Event) Router {
 	}
 }
 
+// Context of the invocation being routed
+func (r *Router) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return *r.ctx
+}
+
 func (r *Router) AddRoute(matchPath string, action func(route *Route) *Response, requireAuth bool) *Route {
 	q, _ := url.ParseQuery(r.Event.RawQueryString)
 
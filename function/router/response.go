@@ -13,6 +13,7 @@ type ResponseHeaders struct {
 	AccessControlAllowHeaders     string `json:"Access-Control-Allow-Headers"`
 	Vary                          string `json:"Vary"`
 	Location                      string `json:"Location,omitempty"`
+	CacheControl                  string `json:"Cache-Control,omitempty"`
 }
 
 // type ResponseStatus struct {
@@ -0,0 +1,70 @@
+package utility
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Checks an IP address against a list of CIDR ranges. Bare addresses in the
+// list are treated as single-host ranges.
+func AddressInCidrs(address string, cidrs []string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(address))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, cidr := range cidrs {
+		prefix, err := ParseCidr(cidr)
+		if err != nil {
+			continue
+		}
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseCidr(cidr string) (netip.Prefix, error) {
+	cidr = strings.TrimSpace(cidr)
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	// net.ParseCIDR tolerates host bits being set, e.g. 10.0.0.5/24
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.ParsePrefix(ipNet.String())
+}
+
+// Compares an Origin header against a list of allowed origins, ignoring
+// case and trailing slashes. A blank origin never matches.
+func OriginAllowed(origin string, allowed []string) bool {
+	normalized := NormalizeOrigin(origin)
+	if normalized == "" {
+		return false
+	}
+	for _, a := range allowed {
+		if NormalizeOrigin(a) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// Reduces an origin to scheme://host[:port], or blank if it isn't one
+func NormalizeOrigin(origin string) string {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
@@ -0,0 +1,143 @@
+package utility
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressInCidrs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		address  string
+		cidrs    []string
+		expected bool
+	}{
+		{
+			name:     "IPv4 in range",
+			address:  "203.0.113.42",
+			cidrs:    []string{"203.0.113.0/24"},
+			expected: true,
+		},
+		{
+			name:     "IPv4 out of range",
+			address:  "203.0.114.42",
+			cidrs:    []string{"203.0.113.0/24"},
+			expected: false,
+		},
+		{
+			name:     "Bare address matches itself only",
+			address:  "198.51.100.7",
+			cidrs:    []string{"198.51.100.7"},
+			expected: true,
+		},
+		{
+			name:     "Bare address does not match neighbour",
+			address:  "198.51.100.8",
+			cidrs:    []string{"198.51.100.7"},
+			expected: false,
+		},
+		{
+			name:     "Host bits set in range",
+			address:  "10.0.0.200",
+			cidrs:    []string{"10.0.0.5/24"},
+			expected: true,
+		},
+		{
+			name:     "IPv6 in range",
+			address:  "2001:db8::1",
+			cidrs:    []string{"2001:db8::/32"},
+			expected: true,
+		},
+		{
+			name:     "IPv4-mapped IPv6 address",
+			address:  "::ffff:203.0.113.42",
+			cidrs:    []string{"203.0.113.0/24"},
+			expected: true,
+		},
+		{
+			name:     "Second of multiple ranges",
+			address:  "192.0.2.1",
+			cidrs:    []string{"203.0.113.0/24", "192.0.2.0/28"},
+			expected: true,
+		},
+		{
+			name:     "Invalid range is skipped",
+			address:  "192.0.2.1",
+			cidrs:    []string{"not-a-cidr", "192.0.2.0/28"},
+			expected: true,
+		},
+		{
+			name:     "Invalid address",
+			address:  "",
+			cidrs:    []string{"0.0.0.0/0"},
+			expected: false,
+		},
+		{
+			name:     "Empty list",
+			address:  "192.0.2.1",
+			cidrs:    []string{},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := AddressInCidrs(tc.address, tc.cidrs)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		origin   string
+		allowed  []string
+		expected bool
+	}{
+		{
+			name:     "Exact match",
+			origin:   "https://overlay.example.com",
+			allowed:  []string{"https://overlay.example.com"},
+			expected: true,
+		},
+		{
+			name:     "Case and trailing slash ignored",
+			origin:   "https://Overlay.Example.com",
+			allowed:  []string{"https://overlay.example.com/"},
+			expected: true,
+		},
+		{
+			name:     "Scheme mismatch",
+			origin:   "http://overlay.example.com",
+			allowed:  []string{"https://overlay.example.com"},
+			expected: false,
+		},
+		{
+			name:     "Port mismatch",
+			origin:   "https://overlay.example.com:8443",
+			allowed:  []string{"https://overlay.example.com"},
+			expected: false,
+		},
+		{
+			name:     "Blank origin",
+			origin:   "",
+			allowed:  []string{"https://overlay.example.com"},
+			expected: false,
+		},
+		{
+			name:     "Subdomain does not match",
+			origin:   "https://evil.overlay.example.com",
+			allowed:  []string{"https://overlay.example.com"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := OriginAllowed(tc.origin, tc.allowed)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"shrampybot/config"
@@ -22,6 +23,21 @@ func GenerateRandomBase64(byteLength int) string {
 	return base64.URLEncoding.EncodeToString(b)
 }
 
+// Produces a PKCE code verifier and its S256 code challenge
+func GeneratePKCEPair() (string, string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// One-way hash for high-entropy secrets we only need to compare against
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
 // Based on example code from:
 // https://bitfieldconsulting.com/posts/aes-encryption
 
@@ -0,0 +1,98 @@
+package nosqldb
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	// Lambda images don't ship a zone database
+	_ "time/tzdata"
+)
+
+const (
+	quietHoursLayout = "15:04"
+	// Longest gap a member can ask for between announcements
+	MaxAnnouncementGapMinutes = 7 * 24 * 60
+)
+
+// How and when a member wants their streams announced
+type AnnouncementPreferences struct {
+	// Platforms the member has turned off. Anything not listed, including
+	// platforms added later, is announced on.
+	DisabledPlatforms []string `json:"disabled_platforms,omitempty"`
+	// IANA zone the quiet hours are given in, e.g. America/Toronto. Blank
+	// means UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Streams starting from QuietStart up to QuietEnd, as HH:MM on the
+	// member's clock, aren't announced. The range may cross midnight.
+	QuietStart string `json:"quiet_start,omitempty"`
+	QuietEnd   string `json:"quiet_end,omitempty"`
+	// Streams starting this soon after the last announced one aren't
+	// announced
+	MinGapMinutes int `json:"min_gap_minutes,omitempty"`
+}
+
+// Checks preferences a member has submitted against the platforms that can
+// be announced on
+func (p *AnnouncementPreferences) Validate(platforms []string) error {
+	for _, platform := range p.DisabledPlatforms {
+		if !slices.Contains(platforms, platform) {
+			return fmt.Errorf("unknown platform %v", platform)
+		}
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %v", p.Timezone)
+	}
+	if (p.QuietStart == "") != (p.QuietEnd == "") {
+		return fmt.Errorf("quiet hours need both a start and an end")
+	}
+	for _, clock := range []string{p.QuietStart, p.QuietEnd} {
+		if _, err := time.Parse(quietHoursLayout, clock); clock != "" && err != nil {
+			return fmt.Errorf("quiet hours must be given as HH:MM, not %v", clock)
+		}
+	}
+	if p.MinGapMinutes < 0 || p.MinGapMinutes > MaxAnnouncementGapMinutes {
+		return fmt.Errorf("minimum gap must be between 0 and %v minutes", MaxAnnouncementGapMinutes)
+	}
+	return nil
+}
+
+func (p *AnnouncementPreferences) PlatformEnabled(platform string) bool {
+	return !slices.Contains(p.DisabledPlatforms, platform)
+}
+
+// Whether t falls in the member's quiet hours
+func (p *AnnouncementPreferences) InQuietHours(t time.Time) bool {
+	if p.QuietStart == "" || p.QuietEnd == "" {
+		return false
+	}
+	start, err := time.Parse(quietHoursLayout, p.QuietStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(quietHoursLayout, p.QuietEnd)
+	if err != nil {
+		return false
+	}
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	local := t.In(location)
+	now := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from <= to {
+		return now >= from && now < to
+	}
+	// Quiet hours that run past midnight
+	return now >= from || now < to
+}
+
+// Whether a stream starting at t is too soon after one announced at last
+func (p *AnnouncementPreferences) WithinMinGap(last time.Time, t time.Time) bool {
+	if p.MinGapMinutes == 0 || last.IsZero() {
+		return false
+	}
+	return t.Sub(last) < time.Duration(p.MinGapMinutes)*time.Minute
+}
@@ -0,0 +1,104 @@
+package nosqldb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnouncementPreferencesValidate(t *testing.T) {
+	platforms := []string{"bluesky", "discord", "mastodon"}
+	testCases := []struct {
+		name     string
+		prefs    AnnouncementPreferences
+		expected string
+	}{
+		{
+			name:  "Defaults",
+			prefs: AnnouncementPreferences{},
+		},
+		{
+			name: "Everything set",
+			prefs: AnnouncementPreferences{
+				DisabledPlatforms: []string{"bluesky"},
+				Timezone:          "America/Toronto",
+				QuietStart:        "23:00",
+				QuietEnd:          "06:30",
+				MinGapMinutes:     120,
+			},
+		},
+		{
+			name:     "Unknown platform",
+			prefs:    AnnouncementPreferences{DisabledPlatforms: []string{"myspace"}},
+			expected: "unknown platform myspace",
+		},
+		{
+			name:     "Unknown timezone",
+			prefs:    AnnouncementPreferences{Timezone: "Mars/Olympus_Mons"},
+			expected: "unknown timezone Mars/Olympus_Mons",
+		},
+		{
+			name:     "Quiet hours missing an end",
+			prefs:    AnnouncementPreferences{QuietStart: "23:00"},
+			expected: "quiet hours need both a start and an end",
+		},
+		{
+			name:     "Quiet hours in the wrong format",
+			prefs:    AnnouncementPreferences{QuietStart: "11pm", QuietEnd: "06:00"},
+			expected: "quiet hours must be given as HH:MM, not 11pm",
+		},
+		{
+			name:     "Negative gap",
+			prefs:    AnnouncementPreferences{MinGapMinutes: -5},
+			expected: "minimum gap must be between 0 and 10080 minutes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.prefs.Validate(platforms)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAnnouncementPreferencesInQuietHours(t *testing.T) {
+	overnight := AnnouncementPreferences{Timezone: "America/Toronto", QuietStart: "23:00", QuietEnd: "06:00"}
+	afternoon := AnnouncementPreferences{QuietStart: "13:00", QuietEnd: "14:00"}
+	testCases := []struct {
+		name     string
+		prefs    AnnouncementPreferences
+		time     string
+		expected bool
+	}{
+		{name: "Before midnight in the member's zone", prefs: overnight, time: "2026-10-20T03:30:00Z", expected: true},
+		{name: "After midnight in the member's zone", prefs: overnight, time: "2026-10-20T09:59:00Z", expected: true},
+		{name: "Quiet hours just ended", prefs: overnight, time: "2026-10-20T10:00:00Z", expected: false},
+		{name: "Evening before quiet hours", prefs: overnight, time: "2026-10-20T02:00:00Z", expected: false},
+		{name: "Same-day range in UTC", prefs: afternoon, time: "2026-10-20T13:15:00Z", expected: true},
+		{name: "Outside same-day range", prefs: afternoon, time: "2026-10-20T14:00:00Z", expected: false},
+		{name: "No quiet hours", prefs: AnnouncementPreferences{}, time: "2026-10-20T03:30:00Z", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tc.time)
+			assert.Equal(t, tc.expected, tc.prefs.InQuietHours(at))
+		})
+	}
+}
+
+func TestAnnouncementPreferencesWithinMinGap(t *testing.T) {
+	prefs := AnnouncementPreferences{MinGapMinutes: 90}
+	last := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, prefs.WithinMinGap(last, last.Add(89*time.Minute)))
+	assert.False(t, prefs.WithinMinGap(last, last.Add(90*time.Minute)))
+	assert.False(t, prefs.WithinMinGap(time.Time{}, last))
+	assert.False(t, (&AnnouncementPreferences{}).WithinMinGap(last, last.Add(time.Minute)))
+}
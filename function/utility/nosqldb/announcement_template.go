@@ -0,0 +1,146 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	announcementTemplateTableName = "announcement_templates"
+)
+
+// A text/template for one platform's stream announcements, either as the
+// platform default or as an override for a single category
+type AnnouncementTemplateDatum struct {
+	Id       string `json:"id"`
+	Platform string `json:"platform"`
+	// Blank for the platform's default template
+	CategoryId string    `json:"category_id,omitempty"`
+	Template   string    `json:"template"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func AnnouncementTemplateId(platform string, categoryId string) string {
+	if categoryId == "" {
+		return platform
+	}
+	return platform + ":" + categoryId
+}
+
+func (n *NoSqlDb) GetAnnouncementTemplate(id string) (*AnnouncementTemplateDatum, error) {
+	var err error
+	fullTableName := n.prefix + announcementTemplateTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &AnnouncementTemplateDatum{}, err
+	}
+	output := AnnouncementTemplateDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
+
+func (n *NoSqlDb) GetAnnouncementTemplates() ([]*AnnouncementTemplateDatum, error) {
+	var err error
+	fullTableName := n.prefix + announcementTemplateTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*AnnouncementTemplateDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempTemplate := AnnouncementTemplateDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempTemplate)
+		output = append(output, &tempTemplate)
+	}
+
+	return output, nil
+}
+
+// Picks the template for a platform and category: the category's override
+// if there is one, then the platform default. Returns nil if neither exists.
+func FindAnnouncementTemplate(templates []*AnnouncementTemplateDatum, platform string, categoryId string) *AnnouncementTemplateDatum {
+	var fallback *AnnouncementTemplateDatum
+	for _, t := range templates {
+		if t.Platform != platform {
+			continue
+		}
+		if categoryId != "" && t.CategoryId == categoryId {
+			return t
+		}
+		if t.CategoryId == "" {
+			fallback = t
+		}
+	}
+	return fallback
+}
+
+func (n *NoSqlDb) PutAnnouncementTemplate(template *AnnouncementTemplateDatum) error {
+	var err error
+	fullTableName := n.prefix + announcementTemplateTableName
+
+	template.Id = AnnouncementTemplateId(template.Platform, template.CategoryId)
+	template.UpdatedAt = time.Now()
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(template)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		log.Printf("Couldn't marshal announcement template %v for writing because: %v\n", template.Id, err)
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't record announcement template: %v", err)
+	}
+
+	return err
+}
+
+func (n *NoSqlDb) DeleteAnnouncementTemplate(id string) error {
+	fullTableName := n.prefix + announcementTemplateTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	_, err := n.db.DeleteItem(n.ctx, &dynamodb.DeleteItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't delete announcement template %v: %v\n", id, err)
+	}
+
+	return err
+}
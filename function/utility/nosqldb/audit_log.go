@@ -0,0 +1,182 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	auditLogTableName = "audit_log"
+)
+
+// A single administrative change. Entries are append-only; there is
+// deliberately no update or delete function for this table.
+type AuditLogDatum struct {
+	Id   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Time in unix milliseconds, for range filters. The time string can't
+	// be compared as text since its fractional seconds vary in width.
+	TimeMs    int64  `json:"time_ms,omitempty"`
+	ActorSub  string `json:"actor_sub"`
+	TokenType string `json:"token_type"`
+	// Static token ID (kid) when the change was made with a static token
+	TokenId  string `json:"token_id,omitempty"`
+	Action   string `json:"action"`
+	TargetId string `json:"target_id,omitempty"`
+	// JSON snapshots of the target before and after the change
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// Top-level fields which differ between Before and After
+	Changed []string `json:"changed,omitempty"`
+}
+
+type AuditLogFilter struct {
+	ActorSub string
+	Action   string
+	Since    time.Time
+	Until    time.Time
+}
+
+func (f *AuditLogFilter) inRange(t time.Time) bool {
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && t.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// The scan filter for the audit log, or nil if nothing is filtered
+func auditLogFilterExpression(filter *AuditLogFilter) (*expression.Expression, error) {
+	conditions := []expression.ConditionBuilder{}
+	if filter.ActorSub != "" {
+		conditions = append(conditions, expression.Name("actor_sub").Equal(expression.Value(filter.ActorSub)))
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, expression.Name("action").Equal(expression.Value(filter.Action)))
+	}
+	// Milliseconds are coarser than the stored times, so both ends are
+	// inclusive here and made exact once read
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, expression.Name("time_ms").GreaterThanEqual(expression.Value(filter.Since.UnixMilli())))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, expression.Name("time_ms").LessThanEqual(expression.Value(filter.Until.UnixMilli())))
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	filt := conditions[0]
+	for _, c := range conditions[1:] {
+		filt = filt.And(c)
+	}
+	expr, err := expression.NewBuilder().WithFilter(filt).Build()
+	if err != nil {
+		return nil, err
+	}
+	return &expr, nil
+}
+
+func (n *NoSqlDb) GetAuditLog(id string) (*AuditLogDatum, error) {
+	var err error
+	fullTableName := n.prefix + auditLogTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &AuditLogDatum{}, err
+	}
+	output := AuditLogDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
+
+// Retrieves audit entries matching the filter, newest first.
+// Blank filter fields are ignored.
+func (n *NoSqlDb) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLogDatum, error) {
+	var results *[]map[string]any
+	var err error
+	fullTableName := n.prefix + auditLogTableName
+
+	output := []*AuditLogDatum{}
+	expr, err := auditLogFilterExpression(filter)
+	if err != nil {
+		return output, err
+	}
+	if expr == nil {
+		statement := aws.String(
+			fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+		)
+		results, err = n.QueryDB(statement)
+	} else {
+		results, err = n.ScanDBWithExpr(&fullTableName, expr, nil)
+	}
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempDat := AuditLogDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempDat)
+		if !filter.inRange(tempDat.Time) {
+			continue
+		}
+		output = append(output, &tempDat)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Time.After(output[j].Time)
+	})
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutAuditLog(entry *AuditLogDatum) error {
+	var err error
+	fullTableName := n.prefix + auditLogTableName
+	entry.TimeMs = entry.Time.UnixMilli()
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(entry)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		log.Printf("Couldn't marshal audit entry %v for writing because: %v\n", entry.Id, err)
+		return err
+	}
+
+	// Never overwrite an existing entry
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           &fullTableName,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		log.Printf("Couldn't record audit entry: %v", err)
+	}
+
+	return err
+}
@@ -0,0 +1,68 @@
+package nosqldb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogFilterInRange(t *testing.T) {
+	since := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	filter := AuditLogFilter{Since: since, Until: until}
+
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected bool
+	}{
+		{"at since", since, true},
+		// Fractional seconds sort before Z as text, which used to drop these
+		{"just after since", since.Add(500 * time.Millisecond), true},
+		{"just before since", since.Add(-time.Nanosecond), false},
+		{"at until", until, true},
+		{"just after until", until.Add(time.Microsecond), false},
+		{"other zone", since.In(time.FixedZone("EST", -5*60*60)).Add(time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, filter.inRange(tt.time))
+		})
+	}
+
+	assert.True(t, (&AuditLogFilter{}).inRange(since))
+}
+
+func TestAuditLogFilterExpression(t *testing.T) {
+	expr, err := auditLogFilterExpression(&AuditLogFilter{})
+	assert.NoError(t, err)
+	assert.Nil(t, expr)
+
+	since := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	expr, err = auditLogFilterExpression(&AuditLogFilter{Action: "token.create", Since: since, Until: until})
+	assert.NoError(t, err)
+
+	// Swap the placeholders back for names and values to read the filter
+	filter := *expr.Filter()
+	for placeholder, name := range expr.Names() {
+		filter = strings.ReplaceAll(filter, placeholder, name)
+	}
+	for placeholder, value := range expr.Values() {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberN:
+			filter = strings.ReplaceAll(filter, placeholder, v.Value)
+		case *types.AttributeValueMemberS:
+			filter = strings.ReplaceAll(filter, placeholder, "'"+v.Value+"'")
+		}
+	}
+	assert.Equal(t, fmt.Sprintf(
+		"((action = 'token.create') AND (time_ms >= %v)) AND (time_ms <= %v)",
+		since.UnixMilli(),
+		until.UnixMilli(),
+	), filter)
+}
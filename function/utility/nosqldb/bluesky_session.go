@@ -0,0 +1,105 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/utility"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	blueskySessionTableName = "bluesky_sessions"
+)
+
+// A Bluesky login session, kept between invocations so that posts don't
+// each need a fresh password login
+type BlueskySessionDatum struct {
+	// The login identifier the session was created with
+	Id     string `json:"id"`
+	Did    string `json:"did"`
+	Handle string `json:"handle"`
+	// Raw, unencrypted tokens; never get stored
+	AccessJwt        string    `json:"-"`
+	RefreshJwt       string    `json:"-"`
+	AccessJwtEnc     string    `json:"access_jwt_enc,omitempty"`
+	AccessJwtIV      string    `json:"access_jwt_iv,omitempty"`
+	RefreshJwtEnc    string    `json:"refresh_jwt_enc,omitempty"`
+	RefreshJwtIV     string    `json:"refresh_jwt_iv,omitempty"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (n *NoSqlDb) GetBlueskySession(id string) (*BlueskySessionDatum, error) {
+	var err error
+	fullTableName := n.prefix + blueskySessionTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &BlueskySessionDatum{}, err
+	}
+	output := BlueskySessionDatum{}
+
+	rSession := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &rSession)
+	oBytes, _ := json.Marshal(rSession)
+	json.Unmarshal(oBytes, &output)
+
+	if output.Id != "" {
+		// Decrypt secret values. A token that can't be decrypted is left
+		// blank, which sends the caller back to logging in.
+		if output.AccessJwtEnc != "" && output.AccessJwtIV != "" {
+			output.AccessJwt, _ = utility.DecryptSecret(output.AccessJwtEnc, output.AccessJwtIV)
+		}
+		if output.RefreshJwtEnc != "" && output.RefreshJwtIV != "" {
+			output.RefreshJwt, _ = utility.DecryptSecret(output.RefreshJwtEnc, output.RefreshJwtIV)
+		}
+	}
+
+	return &output, nil
+}
+
+func (n *NoSqlDb) PutBlueskySession(session *BlueskySessionDatum) error {
+	var err error
+	fullTableName := n.prefix + blueskySessionTableName
+
+	// Encrypt secret values to be stored
+	session.AccessJwtEnc, session.AccessJwtIV, err = utility.EncryptSecret(session.AccessJwt)
+	if err != nil {
+		log.Printf("Could not encrypt bluesky access token: %v\n", err)
+		return err
+	}
+	session.RefreshJwtEnc, session.RefreshJwtIV, err = utility.EncryptSecret(session.RefreshJwt)
+	if err != nil {
+		log.Printf("Could not encrypt bluesky refresh token: %v\n", err)
+		return err
+	}
+	session.UpdatedAt = time.Now()
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(session)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
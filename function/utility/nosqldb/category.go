@@ -22,6 +22,8 @@ type CategoryDatum struct {
 	TwitchCategory string   `json:"twitch_category"`
 	MastodonTags   []string `json:"mastodon_tags,omitempty"`
 	BlueskyTags    []string `json:"bluesky_tags,omitempty"`
+	// text or embed; blank follows the global Discord post mode
+	DiscordPostMode string `json:"discord_post_mode,omitempty"`
 }
 
 func (n *NoSqlDb) GetCategory(id string) (*CategoryDatum, error) {
@@ -52,6 +54,9 @@ func (n *NoSqlDb) GetCategory(id string) (*CategoryDatum, error) {
 	if rCat["bluesky_tags"] != nil {
 		json.Unmarshal([]byte(rCat["bluesky_tags"].(string)), &output.BlueskyTags)
 	}
+	if rCat["discord_post_mode"] != nil {
+		output.DiscordPostMode = rCat["discord_post_mode"].(string)
+	}
 
 	return &output, nil
 }
@@ -81,6 +86,9 @@ func (n *NoSqlDb) GetCategoryByName(name string) (*CategoryDatum, error) {
 		if rCat["bluesky_tags"] != nil {
 			json.Unmarshal([]byte(rCat["bluesky_tags"].(string)), &output.BlueskyTags)
 		}
+		if rCat["discord_post_mode"] != nil {
+			output.DiscordPostMode = rCat["discord_post_mode"].(string)
+		}
 	}
 
 	return &output, nil
@@ -112,6 +120,9 @@ func (n *NoSqlDb) GetCategoryMap() (*[]CategoryDatum, error) {
 		if rCat["bluesky_tags"] != nil {
 			json.Unmarshal([]byte(rCat["bluesky_tags"].(string)), &tempCat.BlueskyTags)
 		}
+		if rCat["discord_post_mode"] != nil {
+			tempCat.DiscordPostMode = rCat["discord_post_mode"].(string)
+		}
 
 		output = append(output, tempCat)
 	}
@@ -138,6 +149,9 @@ func (n *NoSqlDb) PutCategories(categories *[]CategoryDatum) error {
 		tempMap["mastodon_tags"] = string(mTags)
 		bTags, _ := json.Marshal(c.BlueskyTags)
 		tempMap["bluesky_tags"] = string(bTags)
+		if c.DiscordPostMode != "" {
+			tempMap["discord_post_mode"] = c.DiscordPostMode
+		}
 
 		mapCategories = append(mapCategories, tempMap)
 	}
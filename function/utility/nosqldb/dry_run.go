@@ -0,0 +1,90 @@
+package nosqldb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Reads go through to DynamoDB as usual, but writes are only noted down
+type dryRunDB struct {
+	dynamoAPI
+	prefix string
+	lock   sync.Mutex
+	writes []string
+}
+
+// A client which never writes anything. Useful for seeing what a piece of
+// processing would do without it having any effect.
+func NewDryRunClient() (*NoSqlDb, error) {
+	n, err := NewClient()
+	if err != nil {
+		return n, err
+	}
+	n.db = &dryRunDB{dynamoAPI: n.db, prefix: n.prefix, writes: []string{}}
+	return n, nil
+}
+
+// Writes a dry run client skipped, in order. Always empty for real clients.
+func (n *NoSqlDb) DryRunWrites() []string {
+	d, ok := n.db.(*dryRunDB)
+	if !ok {
+		return []string{}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]string{}, d.writes...)
+}
+
+func (d *dryRunDB) note(operation string, tableName *string, key map[string]types.AttributeValue) {
+	table := ""
+	if tableName != nil {
+		table = strings.TrimPrefix(*tableName, d.prefix)
+	}
+	id := ""
+	if attr, ok := key["id"].(*types.AttributeValueMemberS); ok {
+		id = attr.Value
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.writes = append(d.writes, fmt.Sprintf("%v %v %v", operation, table, id))
+}
+
+func (d *dryRunDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	d.note("put", params.TableName, params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *dryRunDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	d.note("update", params.TableName, params.Key)
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (d *dryRunDB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	d.note("delete", params.TableName, params.Key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (d *dryRunDB) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	for tableName, requests := range params.RequestItems {
+		for _, request := range requests {
+			if request.PutRequest != nil {
+				d.note("put", &tableName, request.PutRequest.Item)
+			}
+			if request.DeleteRequest != nil {
+				d.note("delete", &tableName, request.DeleteRequest.Key)
+			}
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (d *dryRunDB) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	d.note("create_table", params.TableName, nil)
+	return &dynamodb.CreateTableOutput{}, nil
+}
@@ -0,0 +1,251 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	eventQueueTableName      = "event_queue"
+	eventDeadLetterTableName = "event_dead_letters"
+
+	EventQueueStatusPending    = "pending"
+	EventQueueStatusProcessing = "processing"
+)
+
+// A queued unit of work. Times are unix seconds so that they compare
+// correctly inside DynamoDB expressions.
+type EventQueueDatum struct {
+	Id            string `json:"id"`
+	Type          string `json:"type"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	CreatedAt     int64  `json:"created_at"`
+	NextAttemptAt int64  `json:"next_attempt_at"`
+	// While processing, nobody else may claim the job until this passes
+	LeaseUntil int64  `json:"lease_until"`
+	LastError  string `json:"last_error,omitempty"`
+	// Set once the job has given up and moved to the dead letters
+	FailedAt int64 `json:"failed_at,omitempty"`
+}
+
+func (n *NoSqlDb) putQueueItem(tableName string, job *EventQueueDatum, onlyIfNew bool) error {
+	var err error
+	fullTableName := n.prefix + tableName
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(job)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		log.Printf("Couldn't marshal queue item %v for writing because: %v\n", job.Id, err)
+		return err
+	}
+
+	input := dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	}
+	if onlyIfNew {
+		input.ConditionExpression = aws.String("attribute_not_exists(id)")
+	}
+	_, err = n.db.PutItem(n.ctx, &input)
+
+	return err
+}
+
+func (n *NoSqlDb) deleteQueueItem(tableName string, id string) error {
+	fullTableName := n.prefix + tableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	_, err := n.db.DeleteItem(n.ctx, &dynamodb.DeleteItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't delete queue item %v because: %v", id, err)
+	}
+
+	return err
+}
+
+// Adds a job to the queue. Returns false if a job with the same id was
+// already queued, which makes enqueueing safe to repeat.
+func (n *NoSqlDb) PutEventQueueJob(job *EventQueueDatum) (bool, error) {
+	err := n.putQueueItem(eventQueueTableName, job, true)
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return false, nil
+		}
+		log.Printf("Couldn't enqueue job %v: %v\n", job.Id, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// Overwrites a job, used when rescheduling after a failure
+func (n *NoSqlDb) UpdateEventQueueJob(job *EventQueueDatum) error {
+	return n.putQueueItem(eventQueueTableName, job, false)
+}
+
+func (n *NoSqlDb) DeleteEventQueueJob(id string) error {
+	return n.deleteQueueItem(eventQueueTableName, id)
+}
+
+// Lists jobs which are due, including ones whose lease ran out because the
+// worker handling them died.
+func (n *NoSqlDb) GetDueEventQueueJobs(now int64) ([]*EventQueueDatum, error) {
+	var err error
+	fullTableName := n.prefix + eventQueueTableName
+
+	filt := expression.Or(
+		expression.Name("status").Equal(expression.Value(EventQueueStatusPending)).And(
+			expression.Name("next_attempt_at").LessThanEqual(expression.Value(now)),
+		),
+		expression.Name("status").Equal(expression.Value(EventQueueStatusProcessing)).And(
+			expression.Name("lease_until").LessThan(expression.Value(now)),
+		),
+	)
+	expr, err := expression.NewBuilder().WithFilter(filt).Build()
+	if err != nil {
+		return []*EventQueueDatum{}, err
+	}
+
+	results, err := n.ScanDBWithExpr(&fullTableName, &expr, nil)
+	if err != nil {
+		return []*EventQueueDatum{}, err
+	}
+
+	output := []*EventQueueDatum{}
+	for _, result := range *results {
+		tempJob := EventQueueDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempJob)
+		output = append(output, &tempJob)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].CreatedAt < output[j].CreatedAt
+	})
+
+	return output, nil
+}
+
+// Takes a lease on a due job. Returns false if another worker got there first.
+func (n *NoSqlDb) ClaimEventQueueJob(id string, now int64, leaseUntil int64) (bool, error) {
+	var err error
+	fullTableName := n.prefix + eventQueueTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	update := expression.Set(
+		expression.Name("status"), expression.Value(EventQueueStatusProcessing),
+	).Set(
+		expression.Name("lease_until"), expression.Value(leaseUntil),
+	)
+	cond := expression.Or(
+		expression.Name("status").Equal(expression.Value(EventQueueStatusPending)),
+		expression.Name("lease_until").LessThan(expression.Value(now)),
+	)
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return false, nil
+		}
+		log.Printf("Couldn't claim job %v: %v\n", id, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (n *NoSqlDb) GetEventDeadLetter(id string) (*EventQueueDatum, error) {
+	var err error
+	fullTableName := n.prefix + eventDeadLetterTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &EventQueueDatum{}, err
+	}
+	output := EventQueueDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
+
+// Retrieves all dead letters, most recent failures first
+func (n *NoSqlDb) GetEventDeadLetters() ([]*EventQueueDatum, error) {
+	var err error
+	fullTableName := n.prefix + eventDeadLetterTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*EventQueueDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempJob := EventQueueDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempJob)
+		output = append(output, &tempJob)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].FailedAt > output[j].FailedAt
+	})
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutEventDeadLetter(job *EventQueueDatum) error {
+	err := n.putQueueItem(eventDeadLetterTableName, job, false)
+	if err != nil {
+		log.Printf("Couldn't record dead letter %v: %v\n", job.Id, err)
+	}
+	return err
+}
+
+func (n *NoSqlDb) DeleteEventDeadLetter(id string) error {
+	return n.deleteQueueItem(eventDeadLetterTableName, id)
+}
@@ -0,0 +1,134 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	eventsubLifecycleTableName = "eventsub_lifecycle"
+)
+
+// What we've seen of a single EventSub subscription, keyed by Twitch's
+// subscription id. Unlike eventsub_subscriptions, a re-created subscription
+// gets a record of its own.
+type EventsubLifecycleDatum struct {
+	Id            string            `json:"id"`
+	BroadcasterId string            `json:"broadcaster_id"`
+	Type          string            `json:"type"`
+	Version       string            `json:"version"`
+	Condition     map[string]string `json:"condition,omitempty"`
+	Cost          int64             `json:"cost"`
+	CreatedAt     string            `json:"created_at,omitempty"` // RFC3339, from Twitch
+	Status        string            `json:"status"`
+
+	Verifications      int       `json:"verifications"`
+	FirstVerifiedAt    time.Time `json:"first_verified_at,omitempty"`
+	LastVerifiedAt     time.Time `json:"last_verified_at,omitempty"`
+	Notifications      int       `json:"notifications"`
+	LastNotificationAt time.Time `json:"last_notification_at,omitempty"`
+	LastMessageId      string    `json:"last_message_id,omitempty"`
+	RevokedAt          time.Time `json:"revoked_at,omitempty"`
+	RevocationReason   string    `json:"revocation_reason,omitempty"`
+}
+
+func (n *NoSqlDb) GetEventsubLifecycles() ([]*EventsubLifecycleDatum, error) {
+	var err error
+	fullTableName := n.prefix + eventsubLifecycleTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*EventsubLifecycleDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempLifecycle := EventsubLifecycleDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempLifecycle)
+		output = append(output, &tempLifecycle)
+	}
+
+	return output, nil
+}
+
+// Counts a webhook_callback_verification for a subscription
+func (n *NoSqlDb) RecordEventsubVerification(sub *EventsubLifecycleDatum) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	update := eventsubMetadataUpdate(sub, "enabled").
+		Add(expression.Name("verifications"), expression.Value(1)).
+		Set(expression.Name("first_verified_at"), expression.IfNotExists(expression.Name("first_verified_at"), expression.Value(now))).
+		Set(expression.Name("last_verified_at"), expression.Value(now))
+
+	return n.updateEventsubLifecycle(sub.Id, update)
+}
+
+// Counts a notification delivered for a subscription
+func (n *NoSqlDb) RecordEventsubNotification(sub *EventsubLifecycleDatum, messageId string) error {
+	update := eventsubMetadataUpdate(sub, "enabled").
+		Add(expression.Name("notifications"), expression.Value(1)).
+		Set(expression.Name("last_notification_at"), expression.Value(time.Now().UTC().Format(time.RFC3339))).
+		Set(expression.Name("last_message_id"), expression.Value(messageId))
+
+	return n.updateEventsubLifecycle(sub.Id, update)
+}
+
+// Notes that Twitch revoked a subscription, along with the reason given
+func (n *NoSqlDb) RecordEventsubRevocation(sub *EventsubLifecycleDatum, reason string) error {
+	update := eventsubMetadataUpdate(sub, reason).
+		Set(expression.Name("revoked_at"), expression.Value(time.Now().UTC().Format(time.RFC3339))).
+		Set(expression.Name("revocation_reason"), expression.Value(reason))
+
+	return n.updateEventsubLifecycle(sub.Id, update)
+}
+
+// Keeps the subscription's own details current alongside each event
+func eventsubMetadataUpdate(sub *EventsubLifecycleDatum, status string) expression.UpdateBuilder {
+	update := expression.Set(expression.Name("broadcaster_id"), expression.Value(sub.BroadcasterId)).
+		Set(expression.Name("type"), expression.Value(sub.Type)).
+		Set(expression.Name("version"), expression.Value(sub.Version)).
+		Set(expression.Name("cost"), expression.Value(sub.Cost)).
+		Set(expression.Name("status"), expression.Value(status))
+	if len(sub.Condition) > 0 {
+		update = update.Set(expression.Name("condition"), expression.Value(sub.Condition))
+	}
+	if sub.CreatedAt != "" {
+		update = update.Set(expression.Name("created_at"), expression.Value(sub.CreatedAt))
+	}
+	return update
+}
+
+func (n *NoSqlDb) updateEventsubLifecycle(id string, update expression.UpdateBuilder) error {
+	fullTableName := n.prefix + eventsubLifecycleTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		log.Printf("Couldn't update lifecycle for eventsub subscription %v: %v\n", id, err)
+	}
+
+	return err
+}
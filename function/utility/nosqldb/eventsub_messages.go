@@ -14,6 +14,10 @@ type EventsubMessageDatum struct {
 	Time  string `json:"time"` // RFC3339
 	Type  string `json:"type"`
 	Retry string `json:"retry"`
+	// Kept so that a message can be replayed later on
+	SubscriptionType string            `json:"subscription_type,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Body             string            `json:"body,omitempty"`
 }
 
 const (
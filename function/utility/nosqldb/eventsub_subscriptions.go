@@ -0,0 +1,117 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	eventsubSubscriptionTableName = "eventsub_subscriptions"
+)
+
+// Last known state of one EventSub subscription type for one broadcaster.
+// The id is the broadcaster id and type joined by a colon, so the record
+// survives the subscription itself being revoked and re-created.
+type EventsubSubscriptionDatum struct {
+	Id             string `json:"id"`
+	BroadcasterId  string `json:"broadcaster_id"`
+	Type           string `json:"type"`
+	Version        string `json:"version"`
+	SubscriptionId string `json:"subscription_id"`
+	// Twitch's status string, e.g. enabled or authorization_revoked
+	Status           string    `json:"status"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	VerifiedAt       time.Time `json:"verified_at,omitempty"`
+	RevokedAt        time.Time `json:"revoked_at,omitempty"`
+	RevocationReason string    `json:"revocation_reason,omitempty"`
+	// Re-creation attempts since the last successful verification
+	RecreateAttempts  int    `json:"recreate_attempts"`
+	LastRecreateError string `json:"last_recreate_error,omitempty"`
+}
+
+func EventsubSubscriptionId(broadcasterId string, subType string) string {
+	return broadcasterId + ":" + subType
+}
+
+func (n *NoSqlDb) GetEventsubSubscription(broadcasterId string, subType string) (*EventsubSubscriptionDatum, error) {
+	var err error
+	fullTableName := n.prefix + eventsubSubscriptionTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: EventsubSubscriptionId(broadcasterId, subType)}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &EventsubSubscriptionDatum{}, err
+	}
+	output := EventsubSubscriptionDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
+
+func (n *NoSqlDb) GetEventsubSubscriptions() ([]*EventsubSubscriptionDatum, error) {
+	var err error
+	fullTableName := n.prefix + eventsubSubscriptionTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*EventsubSubscriptionDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempSub := EventsubSubscriptionDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempSub)
+		output = append(output, &tempSub)
+	}
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutEventsubSubscription(sub *EventsubSubscriptionDatum) error {
+	var err error
+	fullTableName := n.prefix + eventsubSubscriptionTableName
+
+	sub.Id = EventsubSubscriptionId(sub.BroadcasterId, sub.Type)
+	sub.UpdatedAt = time.Now()
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(sub)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		log.Printf("Couldn't marshal eventsub subscription %v for writing because: %v\n", sub.Id, err)
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't record eventsub subscription: %v", err)
+	}
+
+	return err
+}
@@ -16,10 +16,25 @@ const (
 	batchSize = 25
 )
 
+// The parts of the DynamoDB client we use, so that writes can be swapped
+// out for dry runs
+type dynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	ExecuteStatement(ctx context.Context, params *dynamodb.ExecuteStatementInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ExecuteStatementOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
 type NoSqlDb struct {
 	ctx    context.Context
 	prefix string
-	db     *dynamodb.Client
+	db     dynamoAPI
 }
 
 func NewClient() (*NoSqlDb, error) {
@@ -126,21 +141,29 @@ func (n *NoSqlDb) QueryDBWithExpr(tableName *string, expr *expression.Expression
 // Scan will always parse the entire table. Try to avoid.
 func (n *NoSqlDb) ScanDBWithExpr(tableName *string, expr *expression.Expression, indexName *string) (*[]map[string]any, error) {
 	var output []map[string]any
+	var startKey map[string]types.AttributeValue
 
-	result, err := n.db.Scan(n.ctx, &dynamodb.ScanInput{
-		TableName:                 tableName,
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		FilterExpression:          expr.Filter(),
-		ProjectionExpression:      expr.Projection(),
-		IndexName:                 indexName,
-	})
-	if err != nil {
-		return &output, err
-	}
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &output)
-	if err != nil {
-		return &output, err
+	for moreData := true; moreData; {
+		result, err := n.db.Scan(n.ctx, &dynamodb.ScanInput{
+			TableName:                 tableName,
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			FilterExpression:          expr.Filter(),
+			ProjectionExpression:      expr.Projection(),
+			IndexName:                 indexName,
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return &output, err
+		}
+		var pageOutput []map[string]any
+		err = attributevalue.UnmarshalListOfMaps(result.Items, &pageOutput)
+		if err != nil {
+			return &output, err
+		}
+		output = append(output, pageOutput...)
+		startKey = result.LastEvaluatedKey
+		moreData = len(startKey) > 0
 	}
 
 	return &output, nil
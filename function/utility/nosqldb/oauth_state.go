@@ -0,0 +1,111 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"log"
+	"shrampybot/utility"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	oAuthStateTableName = "oauth_state"
+)
+
+// Server-side half of a pending OAuth login. The id is the jti of the
+// signed state value handed to the browser.
+type OAuthStateDatum struct {
+	Id       string `json:"id"`
+	Provider string `json:"provider"`
+	// Raw, unencrypted value of the PKCE code verifier; never gets stored
+	CodeVerifier    string `json:"-"`
+	CodeVerifierIV  string `json:"code_verifier_iv,omitempty"`
+	CodeVerifierEnc string `json:"code_verifier_enc,omitempty"`
+	// SHA256 of the nonce in the cookie of the browser that started the
+	// login; the nonce itself is never stored
+	NonceHash    string    `json:"nonce_hash"`
+	RedirectUri  string    `json:"redirect_uri"`
+	RedirectPath string    `json:"redirect_path,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+func (n *NoSqlDb) GetOAuthState(id string) (*OAuthStateDatum, error) {
+	var err error
+	fullTableName := n.prefix + oAuthStateTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &OAuthStateDatum{}, err
+	}
+	output := OAuthStateDatum{}
+
+	rState := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &rState)
+	oBytes, _ := json.Marshal(rState)
+	json.Unmarshal(oBytes, &output)
+
+	if output.Id != "" && output.CodeVerifierEnc != "" && output.CodeVerifierIV != "" {
+		// Decrypt secret values
+		output.CodeVerifier, _ = utility.DecryptSecret(output.CodeVerifierEnc, output.CodeVerifierIV)
+	}
+
+	return &output, nil
+}
+
+func (n *NoSqlDb) PutOAuthState(state *OAuthStateDatum) error {
+	var err error
+	fullTableName := n.prefix + oAuthStateTableName
+
+	// Encrypt secret values to be stored
+	state.CodeVerifierEnc, state.CodeVerifierIV, err = utility.EncryptSecret(state.CodeVerifier)
+	if err != nil {
+		log.Printf("Could not encrypt oauth state code verifier: %v\n", err)
+		return err
+	}
+
+	tempMap := map[string]string{}
+	tempBytes, _ := json.Marshal(state)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
+
+// States are single-use, so this gets called as soon as one is consumed.
+func (n *NoSqlDb) DeleteOAuthState(id string) error {
+	var err error
+	fullTableName := n.prefix + oAuthStateTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	_, err = n.db.DeleteItem(n.ctx, &dynamodb.DeleteItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't delete oauth state %v because: %v", id, err)
+	}
+
+	return err
+}
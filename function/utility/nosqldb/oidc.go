@@ -0,0 +1,261 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"shrampybot/utility"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	oidcKeyTableName    = "oidc_keys"
+	oidcClientTableName = "oidc_clients"
+	oidcCodeTableName   = "oidc_codes"
+)
+
+// Asymmetric signing key for OIDC tokens. The id doubles as the JWK kid.
+type OIDCKeyDatum struct {
+	Id        string    `json:"id"`
+	Algorithm string    `json:"algorithm"`
+	CreatedAt time.Time `json:"created_at"`
+	// Retired keys are still published so existing tokens can be verified,
+	// but are no longer used for signing.
+	Retired bool `json:"retired"`
+	// Raw, unencrypted PEM of the private key; never gets stored
+	PrivateKey    string `json:"-"`
+	PrivateKeyIV  string `json:"private_key_iv,omitempty"`
+	PrivateKeyEnc string `json:"private_key_enc,omitempty"`
+}
+
+// A client app registered to log users in through ShrampyBot
+type OIDCClientDatum struct {
+	Id           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreatorId    string    `json:"creator_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	RedirectUris []string  `json:"redirect_uris"`
+	// SHA256 of the client secret; the secret itself is only shown once
+	SecretHash string `json:"secret_hash,omitempty"`
+	Disabled   bool   `json:"disabled"`
+}
+
+// A pending authorization code. The id is the code itself.
+type OIDCCodeDatum struct {
+	Id          string `json:"id"`
+	ClientId    string `json:"client_id"`
+	Sub         string `json:"sub"`
+	RedirectUri string `json:"redirect_uri"`
+	Scope       string `json:"scope"`
+	// The user's own scopes, without admin ones, for the scopes claim
+	UserScopes          []string  `json:"user_scopes"`
+	Nonce               string    `json:"nonce,omitempty"`
+	CodeChallenge       string    `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string    `json:"code_challenge_method,omitempty"`
+	AuthTime            time.Time `json:"auth_time"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+func (n *NoSqlDb) GetOIDCKeys() ([]*OIDCKeyDatum, error) {
+	var err error
+	fullTableName := n.prefix + oidcKeyTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*OIDCKeyDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempKey := OIDCKeyDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempKey)
+
+		if tempKey.PrivateKeyEnc != "" && tempKey.PrivateKeyIV != "" {
+			// Decrypt secret values
+			tempKey.PrivateKey, _ = utility.DecryptSecret(tempKey.PrivateKeyEnc, tempKey.PrivateKeyIV)
+		}
+		output = append(output, &tempKey)
+	}
+	// Newest first, so the first unretired key is the current signing key
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].CreatedAt.After(output[j].CreatedAt)
+	})
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutOIDCKey(key *OIDCKeyDatum) error {
+	var err error
+	fullTableName := n.prefix + oidcKeyTableName
+
+	// Encrypt secret values to be stored
+	key.PrivateKeyEnc, key.PrivateKeyIV, err = utility.EncryptSecret(key.PrivateKey)
+	if err != nil {
+		log.Printf("Could not encrypt oidc private key: %v\n", err)
+		return err
+	}
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(key)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
+
+func (n *NoSqlDb) GetOIDCClient(id string) (*OIDCClientDatum, error) {
+	var err error
+	fullTableName := n.prefix + oidcClientTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &OIDCClientDatum{}, err
+	}
+	output := OIDCClientDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
+
+func (n *NoSqlDb) GetOIDCClients() ([]*OIDCClientDatum, error) {
+	var err error
+	fullTableName := n.prefix + oidcClientTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*OIDCClientDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempClient := OIDCClientDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempClient)
+		output = append(output, &tempClient)
+	}
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutOIDCClient(client *OIDCClientDatum) error {
+	var err error
+	fullTableName := n.prefix + oidcClientTableName
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(client)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
+
+func (n *NoSqlDb) DeleteOIDCClient(id string) error {
+	var err error
+	fullTableName := n.prefix + oidcClientTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	_, err = n.db.DeleteItem(n.ctx, &dynamodb.DeleteItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't delete oidc client %v because: %v", id, err)
+	}
+
+	return err
+}
+
+func (n *NoSqlDb) PutOIDCCode(code *OIDCCodeDatum) error {
+	var err error
+	fullTableName := n.prefix + oidcCodeTableName
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(code)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
+
+// Removes and returns an authorization code in a single step so that a
+// code can never be redeemed twice. A blank datum means it didn't exist.
+func (n *NoSqlDb) ConsumeOIDCCode(id string) (*OIDCCodeDatum, error) {
+	var err error
+	fullTableName := n.prefix + oidcCodeTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.DeleteItem(n.ctx, &dynamodb.DeleteItemInput{
+		Key:          keyMap,
+		TableName:    &fullTableName,
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		log.Printf("Couldn't consume oidc code because: %v", err)
+		return &OIDCCodeDatum{}, err
+	}
+	output := OIDCCodeDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Attributes, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
@@ -3,11 +3,13 @@ package nosqldb
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"shrampybot/utility"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
@@ -27,6 +29,21 @@ type StaticTokenDatum struct {
 	SecretKey    string    `json:"-"`
 	SecretKeyIV  string    `json:"secret_key_iv,omitempty"`
 	SecretKeyEnc string    `json:"secret_key_enc,omitempty"`
+	// Optional bounds on where the token may be used from. When both are
+	// set, a request has to satisfy both.
+	AllowedCidrs     []string `json:"allowed_cidrs,omitempty"`
+	AllowedOrigins   []string `json:"allowed_origins,omitempty"`
+	StaticTokenUsage `tstype:",extends"`
+}
+
+// Rejected uses, only ever modified through RecordStaticTokenRejection.
+// Successful uses aren't recorded, to keep writes off the request path.
+type StaticTokenUsage struct {
+	RejectedCount      int       `json:"rejected_count,omitempty"`
+	LastRejectedAt     time.Time `json:"last_rejected_at,omitempty"`
+	LastRejectedIp     string    `json:"last_rejected_ip,omitempty"`
+	LastRejectedOrigin string    `json:"last_rejected_origin,omitempty"`
+	LastRejectedReason string    `json:"last_rejected_reason,omitempty"`
 }
 
 func (n *NoSqlDb) GetStaticToken(id string) (*StaticTokenDatum, error) {
@@ -106,3 +123,42 @@ func (n *NoSqlDb) PutStaticToken(static *StaticTokenDatum) error {
 
 	return err
 }
+
+// Counts a use of a token from outside its allowed bounds
+func (n *NoSqlDb) RecordStaticTokenRejection(id string, ip string, origin string, reason string) error {
+	fullTableName := n.prefix + staticTokenTableName
+
+	update := expression.Add(expression.Name("rejected_count"), expression.Value(1)).
+		Set(expression.Name("last_rejected_at"), expression.Value(time.Now().UTC().Format(time.RFC3339))).
+		Set(expression.Name("last_rejected_ip"), expression.Value(ip)).
+		Set(expression.Name("last_rejected_origin"), expression.Value(origin)).
+		Set(expression.Name("last_rejected_reason"), expression.Value(reason))
+
+	return n.updateStaticTokenUsage(fullTableName, id, update)
+}
+
+func (n *NoSqlDb) updateStaticTokenUsage(fullTableName string, id string, update expression.UpdateBuilder) error {
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	// Never create a token record just by counting usage
+	cond := expression.AttributeExists(expression.Name("id"))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		log.Printf("Couldn't update usage for static token %v: %v\n", id, err)
+	}
+
+	return err
+}
@@ -2,9 +2,13 @@ package nosqldb
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"shrampybot/utility"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -14,18 +18,142 @@ import (
 
 const (
 	streamHistoryTableName = "stream_history"
+
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+
+	// A delivery still pending after this long was most likely cut off,
+	// e.g. by a timeout, and won't finish on its own
+	DeliveryPendingStaleAfter = 10 * time.Minute
 )
 
 type StreamHistoryDatum struct {
-	helix.Stream       `tstype:",extends,required"`
-	DiscordPostId      string    `json:"discord_post_id,omitempty"`
-	DiscordPostUrl     string    `json:"discord_post_url,omitempty"`
-	MastodonPostId     string    `json:"mastodon_post_id,omitempty"`
-	MastodonPostUrl    string    `json:"mastodon_post_url,omitempty"`
-	BlueskyPostId      string    `json:"bluesky_post_id,omitempty"`
-	BlueskyPostUrl     string    `json:"bluesky_post_url,omitempty"`
-	ShrampybotFiltered bool      `json:"shrampybot_filtered"`
-	EndedAt            time.Time `json:"ended_at,omitempty"`
+	helix.Stream `tstype:",extends,required"`
+	// Announcement posts by platform name
+	Posts map[string]utility.PostResponse `json:"posts,omitempty"`
+	// How the announcement is getting on with each platform
+	Deliveries map[string]PostDelivery `json:"deliveries,omitempty"`
+	// Set while any delivery has failed, so failures can be found cheaply
+	ShrampybotDeliveryFailed bool `json:"shrampybot_delivery_failed"`
+	// Set while any delivery is pending, for the same reason
+	ShrampybotDeliveryPending bool `json:"shrampybot_delivery_pending"`
+	ShrampybotFiltered        bool `json:"shrampybot_filtered"`
+	// Set once the stream has been announced, or claimed for announcing
+	ShrampybotAnnounced bool `json:"shrampybot_announced"`
+	// Set when the stream resumed too soon after the last one to be announced
+	ShrampybotDebounced bool      `json:"shrampybot_debounced"`
+	EndedAt             time.Time `json:"ended_at,omitempty"`
+	// Raids this stream sent or received
+	Raids []StreamRaid `json:"raids,omitempty"`
+}
+
+type StreamRaid struct {
+	Time time.Time `json:"time"`
+	// outgoing if this stream did the raiding, incoming if it was raided
+	Direction  string `json:"direction"`
+	FromUserId string `json:"from_user_id"`
+	FromLogin  string `json:"from_login"`
+	ToUserId   string `json:"to_user_id"`
+	ToLogin    string `json:"to_login"`
+	Viewers    int    `json:"viewers"`
+	// The stream on the other end of the raid, if we have a record of it
+	OtherStreamId string `json:"other_stream_id,omitempty"`
+	Announced     bool   `json:"announced"`
+	// Platform posts announcing the raid
+	Posts []utility.PostResponse `json:"posts,omitempty"`
+}
+
+type PostDelivery struct {
+	Status string `json:"status"`
+	// Failed attempts so far
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastAttemptAt time.Time `json:"last_attempt_at,omitempty"`
+}
+
+// Post fields from before Posts, when each platform had its own
+type legacyStreamPosts struct {
+	DiscordPostId   string `json:"discord_post_id"`
+	DiscordPostUrl  string `json:"discord_post_url"`
+	MastodonPostId  string `json:"mastodon_post_id"`
+	MastodonPostUrl string `json:"mastodon_post_url"`
+	BlueskyPostId   string `json:"bluesky_post_id"`
+	BlueskyPostUrl  string `json:"bluesky_post_url"`
+}
+
+// Folds the old per-platform post fields into Posts. They're dropped the
+// next time the record is written.
+func (s *StreamHistoryDatum) UnmarshalJSON(data []byte) error {
+	type plainStreamHistoryDatum StreamHistoryDatum
+	err := json.Unmarshal(data, (*plainStreamHistoryDatum)(s))
+
+	legacy := legacyStreamPosts{}
+	json.Unmarshal(data, &legacy)
+	for platform, post := range map[string]utility.PostResponse{
+		"discord":  {Platform: "discord", Id: legacy.DiscordPostId, Url: legacy.DiscordPostUrl},
+		"mastodon": {Platform: "mastodon", Id: legacy.MastodonPostId, Url: legacy.MastodonPostUrl},
+		"bluesky":  {Platform: "bluesky", Id: legacy.BlueskyPostId, Url: legacy.BlueskyPostUrl},
+	} {
+		if post.Id == "" {
+			continue
+		}
+		if s.Posts == nil {
+			s.Posts = map[string]utility.PostResponse{}
+		}
+		if _, ok := s.Posts[platform]; !ok {
+			s.Posts[platform] = post
+		}
+	}
+
+	return err
+}
+
+// Older records predate the announced flag, so fall back on post IDs
+func (s *StreamHistoryDatum) Announced() bool {
+	if s.ShrampybotAnnounced {
+		return true
+	}
+	for _, post := range s.Posts {
+		if post.Id != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Records the state of one platform's announcement and keeps the failed
+// and pending flags in step with it
+func (s *StreamHistoryDatum) SetDelivery(platform string, delivery PostDelivery) {
+	if s.Deliveries == nil {
+		s.Deliveries = map[string]PostDelivery{}
+	}
+	s.Deliveries[platform] = delivery
+
+	s.ShrampybotDeliveryFailed = false
+	s.ShrampybotDeliveryPending = false
+	for _, d := range s.Deliveries {
+		switch d.Status {
+		case DeliveryStatusFailed:
+			s.ShrampybotDeliveryFailed = true
+		case DeliveryStatusPending:
+			s.ShrampybotDeliveryPending = true
+		}
+	}
+}
+
+// Whether any delivery has failed, or has been pending for so long that it
+// isn't going to finish
+func (s *StreamHistoryDatum) DeliveryNeedsAttention(now time.Time) bool {
+	for _, d := range s.Deliveries {
+		if d.Status == DeliveryStatusFailed {
+			return true
+		}
+		if d.Status == DeliveryStatusPending && now.Sub(d.LastAttemptAt) > DeliveryPendingStaleAfter {
+			return true
+		}
+	}
+	return false
 }
 
 func (n *NoSqlDb) GetStream(id string) (*StreamHistoryDatum, error) {
@@ -81,12 +209,50 @@ func (n *NoSqlDb) GetActiveStreams() (*[]StreamHistoryDatum, error) {
 		tempDat := StreamHistoryDatum{}
 		tempBytes, _ := json.Marshal(result)
 		json.Unmarshal(tempBytes, &tempDat)
+
+		if tagIds, ok := result["tag_ids"].(string); ok {
+			json.Unmarshal([]byte(tagIds), &tempDat.TagIDs)
+		}
+		if tags, ok := result["tags"].(string); ok {
+			json.Unmarshal([]byte(tags), &tempDat.Tags)
+		}
 		output = append(output, tempDat)
 	}
 
 	return &output, nil
 }
 
+// Streams with an announcement that failed to reach at least one platform,
+// or is still pending on one
+func (n *NoSqlDb) GetStreamsWithFailedDeliveries() ([]*StreamHistoryDatum, error) {
+	var err error
+	fullTableName := n.prefix + streamHistoryTableName
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\" WHERE shrampybot_delivery_failed=true OR shrampybot_delivery_pending=true", fullTableName),
+	)
+	output := []*StreamHistoryDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempDat := StreamHistoryDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempDat)
+
+		if tagIds, ok := result["tag_ids"].(string); ok {
+			json.Unmarshal([]byte(tagIds), &tempDat.TagIDs)
+		}
+		if tags, ok := result["tags"].(string); ok {
+			json.Unmarshal([]byte(tags), &tempDat.Tags)
+		}
+		output = append(output, &tempDat)
+	}
+
+	return output, nil
+}
+
 func (n *NoSqlDb) GetLatestStreamByUserId(user_id string) (*StreamHistoryDatum, error) {
 	var err error
 	fullTableName := n.prefix + streamHistoryTableName
@@ -127,12 +293,46 @@ func (n *NoSqlDb) GetLatestStreamByUserId(user_id string) (*StreamHistoryDatum,
 	return &output, nil
 }
 
+// The most recent announced stream for a user, other than the one given.
+// Used to space announcements out.
+func (n *NoSqlDb) GetLatestAnnouncedStreamByUserId(user_id string, excludeId string) (*StreamHistoryDatum, error) {
+	var err error
+	fullTableName := n.prefix + streamHistoryTableName
+	indexName := fullTableName + ".user_id-index"
+
+	filt := expression.Key("user_id").Equal(expression.Value(user_id))
+	expr, err := expression.NewBuilder().WithKeyCondition(filt).Build()
+	if err != nil {
+		return &StreamHistoryDatum{}, err
+	}
+
+	result, err := n.QueryDBWithExpr(&fullTableName, &expr, &indexName)
+	if err != nil {
+		return &StreamHistoryDatum{}, err
+	}
+
+	output := StreamHistoryDatum{}
+	for _, res := range *result {
+		tempDat := StreamHistoryDatum{}
+		tempBytes, _ := json.Marshal(res)
+		json.Unmarshal(tempBytes, &tempDat)
+		if tempDat.ID == excludeId || !tempDat.Announced() {
+			continue
+		}
+		if tempDat.StartedAt.After(output.StartedAt) {
+			output = tempDat
+		}
+	}
+
+	return &output, nil
+}
+
 func (n *NoSqlDb) PutStream(stream *StreamHistoryDatum) error {
 	var err error
 
 	fullTableName := n.prefix + streamHistoryTableName
 
-	tempMap := map[string]string{}
+	tempMap := map[string]any{}
 	tempBytes, _ := json.Marshal(stream)
 	json.Unmarshal(tempBytes, &tempMap)
 
@@ -158,3 +358,143 @@ func (n *NoSqlDb) PutStream(stream *StreamHistoryDatum) error {
 
 	return err
 }
+
+// Atomically marks a stream as announced. Returns false if it already was,
+// so that concurrent notifications can't announce the same stream twice.
+func (n *NoSqlDb) ClaimStreamAnnouncement(id string) (bool, error) {
+	var err error
+	fullTableName := n.prefix + streamHistoryTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	update := expression.Set(expression.Name("shrampybot_announced"), expression.Value(true))
+	cond := expression.AttributeExists(expression.Name("id")).And(
+		expression.Or(
+			expression.AttributeNotExists(expression.Name("shrampybot_announced")),
+			expression.Name("shrampybot_announced").Equal(expression.Value(false)),
+		),
+	)
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return false, nil
+		}
+		log.Printf("Couldn't claim announcement for stream %v: %v\n", id, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Records how one platform's announcement went, along with its post once
+// it's been sent, without writing over the rest of the stream. Posting to
+// several platforms at once can't lose another platform's outcome this way.
+func (n *NoSqlDb) SetStreamDelivery(id string, platform string, delivery PostDelivery, post *utility.PostResponse) error {
+	var err error
+	fullTableName := n.prefix + streamHistoryTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+	cond := expression.AttributeExists(expression.Name("id"))
+
+	// Paths into the maps only work once they exist, which older streams
+	// and streams that were never announced won't have
+	empty := map[string]any{}
+	update := expression.Set(
+		expression.Name("deliveries"),
+		expression.IfNotExists(expression.Name("deliveries"), expression.Value(empty)),
+	).Set(
+		expression.Name("posts"),
+		expression.IfNotExists(expression.Name("posts"), expression.Value(empty)),
+	)
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+	_, err = n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		log.Printf("Couldn't prepare deliveries for stream %v: %v\n", id, err)
+		return err
+	}
+
+	deliveryMap := map[string]any{}
+	tempBytes, _ := json.Marshal(delivery)
+	json.Unmarshal(tempBytes, &deliveryMap)
+	update = expression.Set(expression.Name("deliveries."+platform), expression.Value(deliveryMap))
+	if post != nil {
+		postMap := map[string]any{}
+		tempBytes, _ = json.Marshal(post)
+		json.Unmarshal(tempBytes, &postMap)
+		update = update.Set(expression.Name("posts."+platform), expression.Value(postMap))
+	}
+	expr, err = expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+	result, err := n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		log.Printf("Couldn't record %v delivery for stream %v: %v\n", platform, id, err)
+		return err
+	}
+
+	// The flags depend on every platform, so work them out from what's
+	// stored now and only write them if they've changed
+	stored := StreamHistoryDatum{}
+	rStream := map[string]any{}
+	attributevalue.UnmarshalMap(result.Attributes, &rStream)
+	oBytes, _ := json.Marshal(rStream)
+	json.Unmarshal(oBytes, &stored)
+	failed, pending := stored.ShrampybotDeliveryFailed, stored.ShrampybotDeliveryPending
+	stored.SetDelivery(platform, delivery)
+	if stored.ShrampybotDeliveryFailed == failed && stored.ShrampybotDeliveryPending == pending {
+		return nil
+	}
+
+	update = expression.Set(expression.Name("shrampybot_delivery_failed"), expression.Value(stored.ShrampybotDeliveryFailed)).
+		Set(expression.Name("shrampybot_delivery_pending"), expression.Value(stored.ShrampybotDeliveryPending))
+	expr, err = expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+	_, err = n.db.UpdateItem(n.ctx, &dynamodb.UpdateItemInput{
+		Key:                       keyMap,
+		TableName:                 &fullTableName,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		log.Printf("Couldn't update delivery flags for stream %v: %v\n", id, err)
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package nosqldb
+
+import (
+	"context"
+	"encoding/json"
+	"shrampybot/utility"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamHistoryLegacyPosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    string
+		posts     map[string]utility.PostResponse
+		announced bool
+	}{
+		{
+			name:   "legacy fields only",
+			record: `{"id":"1","discord_post_id":"d1","discord_post_url":"https://discord/d1","bluesky_post_id":"b1"}`,
+			posts: map[string]utility.PostResponse{
+				"discord": {Platform: "discord", Id: "d1", Url: "https://discord/d1"},
+				"bluesky": {Platform: "bluesky", Id: "b1"},
+			},
+			announced: true,
+		},
+		{
+			name:   "posts win over legacy fields",
+			record: `{"id":"2","posts":{"discord":{"platform":"discord","id":"new"}},"discord_post_id":"old"}`,
+			posts: map[string]utility.PostResponse{
+				"discord": {Platform: "discord", Id: "new"},
+			},
+			announced: true,
+		},
+		{
+			name:      "never posted",
+			record:    `{"id":"3","mastodon_post_id":""}`,
+			posts:     nil,
+			announced: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := StreamHistoryDatum{}
+			assert.NoError(t, json.Unmarshal([]byte(test.record), &stream))
+			assert.Equal(t, test.posts, stream.Posts)
+			assert.Equal(t, test.announced, stream.Announced())
+
+			// Legacy fields aren't written back out
+			out, _ := json.Marshal(&stream)
+			assert.NotContains(t, string(out), "_post_id")
+		})
+	}
+}
+
+func TestDeliveryNeedsAttention(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		delivery PostDelivery
+		pending  bool
+		expected bool
+	}{
+		{"sent", PostDelivery{Status: DeliveryStatusSent, LastAttemptAt: now}, false, false},
+		{"failed", PostDelivery{Status: DeliveryStatusFailed, LastAttemptAt: now}, false, true},
+		{"pending", PostDelivery{Status: DeliveryStatusPending, LastAttemptAt: now.Add(-time.Minute)}, true, false},
+		{"stalled", PostDelivery{Status: DeliveryStatusPending, LastAttemptAt: now.Add(-DeliveryPendingStaleAfter - time.Minute)}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := StreamHistoryDatum{}
+			stream.SetDelivery("discord", PostDelivery{Status: DeliveryStatusSent, LastAttemptAt: now})
+			stream.SetDelivery("bluesky", tt.delivery)
+
+			assert.Equal(t, tt.pending, stream.ShrampybotDeliveryPending)
+			assert.Equal(t, tt.expected, stream.DeliveryNeedsAttention(now))
+		})
+	}
+}
+
+// Answers updates with a stored stream and notes what they were
+type streamUpdateDB struct {
+	dynamoAPI
+	stored  map[string]any
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (d *streamUpdateDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	d.updates = append(d.updates, params)
+	item, _ := attributevalue.MarshalMap(d.stored)
+	return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+}
+
+func TestSetStreamDelivery(t *testing.T) {
+	sent := PostDelivery{Status: DeliveryStatusSent}
+	tests := []struct {
+		name     string
+		stored   string
+		post     *utility.PostResponse
+		paths    []string
+		failed   bool
+		pending  bool
+		setFlags bool
+	}{
+		{
+			name:     "last pending platform sent",
+			stored:   `{"id":"1","deliveries":{"discord":{"status":"sent"},"bluesky":{"status":"sent"}},"shrampybot_delivery_pending":true}`,
+			post:     &utility.PostResponse{Platform: "bluesky", Id: "b1"},
+			paths:    []string{"id", "deliveries", "bluesky", "posts"},
+			setFlags: true,
+		},
+		{
+			name:     "another platform still failed",
+			stored:   `{"id":"1","deliveries":{"discord":{"status":"failed"},"bluesky":{"status":"sent"}},"shrampybot_delivery_failed":true}`,
+			post:     &utility.PostResponse{Platform: "bluesky", Id: "b1"},
+			paths:    []string{"id", "deliveries", "bluesky", "posts"},
+			failed:   true,
+			setFlags: false,
+		},
+		{
+			name:     "no post",
+			stored:   `{"id":"1","deliveries":{"bluesky":{"status":"sent"}},"shrampybot_delivery_failed":true}`,
+			paths:    []string{"id", "deliveries", "bluesky"},
+			setFlags: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &streamUpdateDB{stored: map[string]any{}}
+			json.Unmarshal([]byte(tt.stored), &db.stored)
+			n := &NoSqlDb{ctx: context.Background(), db: db}
+
+			err := n.SetStreamDelivery("1", "bluesky", sent, tt.post)
+			assert.NoError(t, err)
+
+			// Making sure the maps exist, then the platform's own entries
+			if !assert.GreaterOrEqual(t, len(db.updates), 2) {
+				return
+			}
+			names := []string{}
+			for _, name := range db.updates[1].ExpressionAttributeNames {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, tt.paths, names)
+			assert.Equal(t, types.ReturnValueAllNew, db.updates[1].ReturnValues)
+
+			if !tt.setFlags {
+				assert.Len(t, db.updates, 2)
+				return
+			}
+			if assert.Len(t, db.updates, 3) {
+				values := map[string]any{}
+				attributevalue.UnmarshalMap(db.updates[2].ExpressionAttributeValues, &values)
+				assert.ElementsMatch(t, []any{tt.failed, tt.pending}, []any{values[":0"], values[":1"]})
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+const (
+	streamSegmentsTableName = "stream_segments"
+)
+
+// A stretch of a stream during which its category, title and tags stayed
+// the same. Open segments have a zero EndedAt. Segments are the only record
+// of what a stream changed to while live; the stream itself only holds the
+// latest values.
+type StreamSegmentDatum struct {
+	Id        string    `json:"id"`
+	StreamId  string    `json:"stream_id"`
+	UserId    string    `json:"user_id"`
+	GameID    string    `json:"game_id"`
+	GameName  string    `json:"game_name"`
+	Title     string    `json:"title"`
+	Tags      []string  `json:"tags,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at,omitempty"`
+	// What produced the segment: online, channel.update or poll
+	Source string `json:"source"`
+}
+
+// Length of the segment, counting open segments up to the given time
+func (s *StreamSegmentDatum) Duration(now time.Time) time.Duration {
+	if s.EndedAt.IsZero() {
+		return now.Sub(s.StartedAt)
+	}
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// Retrieves all segments for a stream, oldest first
+func (n *NoSqlDb) GetStreamSegments(streamId string) ([]*StreamSegmentDatum, error) {
+	var err error
+	fullTableName := n.prefix + streamSegmentsTableName
+	indexName := fullTableName + ".stream_id-index"
+
+	filt := expression.Key("stream_id").Equal(expression.Value(streamId))
+	expr, err := expression.NewBuilder().WithKeyCondition(filt).Build()
+	if err != nil {
+		return []*StreamSegmentDatum{}, err
+	}
+
+	results, err := n.QueryDBWithExpr(&fullTableName, &expr, &indexName)
+	if err != nil {
+		return []*StreamSegmentDatum{}, err
+	}
+
+	output := []*StreamSegmentDatum{}
+	for _, result := range *results {
+		tempDat := StreamSegmentDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempDat)
+		output = append(output, &tempDat)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].StartedAt.Before(output[j].StartedAt)
+	})
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutStreamSegment(segment *StreamSegmentDatum) error {
+	var err error
+	fullTableName := n.prefix + streamSegmentsTableName
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(segment)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		log.Printf("Couldn't marshal stream segment %v for writing because: %v\n", segment.Id, err)
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't record stream segment: %v", err)
+	}
+
+	return err
+}
+
+// Starts a new segment if the stream's category, title or tags differ from
+// the open segment, closing the open one at the same moment.
+func (n *NoSqlDb) AdvanceStreamSegment(stream *StreamHistoryDatum, at time.Time, source string) error {
+	segments, err := n.GetStreamSegments(stream.ID)
+	if err != nil {
+		return err
+	}
+
+	var open *StreamSegmentDatum
+	for _, segment := range segments {
+		if segment.EndedAt.IsZero() {
+			open = segment
+		}
+	}
+	if open != nil {
+		if open.GameID == stream.GameID && open.Title == stream.Title && slices.Equal(open.Tags, stream.Tags) {
+			return nil
+		}
+		// Notifications can arrive out of order; never end a segment before it began
+		if at.Before(open.StartedAt) {
+			at = open.StartedAt
+		}
+		open.EndedAt = at
+		err = n.PutStreamSegment(open)
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.PutStreamSegment(&StreamSegmentDatum{
+		Id:        uuid.NewString(),
+		StreamId:  stream.ID,
+		UserId:    stream.UserID,
+		GameID:    stream.GameID,
+		GameName:  stream.GameName,
+		Title:     stream.Title,
+		Tags:      stream.Tags,
+		StartedAt: at,
+		Source:    source,
+	})
+}
+
+// Closes whichever segments are still open once a stream has ended
+func (n *NoSqlDb) CloseStreamSegments(streamId string, at time.Time) error {
+	segments, err := n.GetStreamSegments(streamId)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if !segment.EndedAt.IsZero() {
+			continue
+		}
+		segment.EndedAt = at
+		if at.Before(segment.StartedAt) {
+			segment.EndedAt = segment.StartedAt
+		}
+		err = n.PutStreamSegment(segment)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/litui/helix/v3"
@@ -57,6 +58,16 @@ type TwitchUserDatum struct {
 	TiktokUsername             string `json:"tiktok_username,omitempty"`
 	SpotifyUserId              string `json:"spotify_user_id,omitempty"`
 	SpotifyUsername            string `json:"spotify_username,omitempty"`
+	// Set by the member; nil means announce everything
+	ShrampybotAnnouncementPreferences *AnnouncementPreferences `json:"shrampybot_announcement_preferences,omitempty"`
+}
+
+// The member's preferences, or the defaults if they haven't set any
+func (u *TwitchUserDatum) AnnouncementPreferences() *AnnouncementPreferences {
+	if u.ShrampybotAnnouncementPreferences == nil {
+		return &AnnouncementPreferences{}
+	}
+	return u.ShrampybotAnnouncementPreferences
 }
 
 func (n *NoSqlDb) DisableTwitchUsers(ids *[]string) error {
@@ -115,6 +126,30 @@ func (n *NoSqlDb) GetTwitchUser(id string) (*TwitchUserDatum, error) {
 	return &output, nil
 }
 
+// Finds the Twitch user linked to a Discord account, if any. A blank
+// datum means there is no link.
+func (n *NoSqlDb) GetTwitchUserByDiscordId(discordId string) (*TwitchUserDatum, error) {
+	var err error
+	fullTableName := n.prefix + twitchUsersTableName
+
+	filt := expression.Name("discord_user_id").Equal(expression.Value(discordId))
+	expr, err := expression.NewBuilder().WithFilter(filt).Build()
+	if err != nil {
+		return &TwitchUserDatum{}, err
+	}
+	results, err := n.ScanDBWithExpr(&fullTableName, &expr, nil)
+	if err != nil {
+		return &TwitchUserDatum{}, err
+	}
+	output := TwitchUserDatum{}
+	if len(*results) > 0 {
+		tempBytes, _ := json.Marshal((*results)[0])
+		json.Unmarshal(tempBytes, &output)
+	}
+
+	return &output, nil
+}
+
 func (n *NoSqlDb) GetTwitchIdLoginMap() (map[string]string, error) {
 	var err error
 	fullTableName := n.prefix + twitchUsersTableName
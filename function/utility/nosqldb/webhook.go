@@ -0,0 +1,269 @@
+package nosqldb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"shrampybot/utility"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	webhookTableName         = "webhooks"
+	webhookDeliveryTableName = "webhook_deliveries"
+
+	WebhookEventOnline    = "online"
+	WebhookEventOffline   = "offline"
+	WebhookEventAnnounced = "announced"
+	WebhookEventFiltered  = "filtered"
+
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	// Failed deliveries are retried until the queue gives up on them
+	WebhookDeliveryStatusFailed = "failed"
+)
+
+var (
+	WebhookEvents = []string{
+		WebhookEventOnline,
+		WebhookEventOffline,
+		WebhookEventAnnounced,
+		WebhookEventFiltered,
+	}
+)
+
+// An outside service that gets told about stream events
+type WebhookDatum struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatorId string    `json:"creator_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Disabled  bool      `json:"disabled"`
+	// Raw, unencrypted signing secret; never gets stored
+	Secret    string `json:"-"`
+	SecretEnc string `json:"secret_enc,omitempty"`
+	SecretIV  string `json:"secret_iv,omitempty"`
+}
+
+func (w *WebhookDatum) Subscribes(event string) bool {
+	return !w.Disabled && slices.Contains(w.Events, event)
+}
+
+// One event sent, or being sent, to one webhook
+type WebhookDeliveryDatum struct {
+	Id        string `json:"id"`
+	WebhookId string `json:"webhook_id"`
+	Event     string `json:"event"`
+	// The exact body sent, so that retries match the first attempt
+	Payload       string    `json:"payload"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	ResponseCode  int       `json:"response_code,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastAttemptAt time.Time `json:"last_attempt_at,omitempty"`
+}
+
+func (n *NoSqlDb) GetWebhook(id string) (*WebhookDatum, error) {
+	var err error
+	fullTableName := n.prefix + webhookTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &WebhookDatum{}, err
+	}
+	output := WebhookDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	decryptWebhookSecret(&output)
+	return &output, nil
+}
+
+func (n *NoSqlDb) GetWebhooks() ([]*WebhookDatum, error) {
+	var err error
+	fullTableName := n.prefix + webhookTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\"", fullTableName),
+	)
+	output := []*WebhookDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempHook := WebhookDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempHook)
+		decryptWebhookSecret(&tempHook)
+		output = append(output, &tempHook)
+	}
+
+	return output, nil
+}
+
+// Webhooks that want to hear about an event
+func (n *NoSqlDb) GetWebhooksForEvent(event string) ([]*WebhookDatum, error) {
+	webhooks, err := n.GetWebhooks()
+	if err != nil {
+		return webhooks, err
+	}
+
+	output := []*WebhookDatum{}
+	for _, webhook := range webhooks {
+		if webhook.Subscribes(event) {
+			output = append(output, webhook)
+		}
+	}
+	return output, nil
+}
+
+func decryptWebhookSecret(webhook *WebhookDatum) {
+	if webhook.SecretEnc != "" && webhook.SecretIV != "" {
+		var err error
+		webhook.Secret, err = utility.DecryptSecret(webhook.SecretEnc, webhook.SecretIV)
+		if err != nil {
+			log.Printf("Could not decrypt secret for webhook %v: %v\n", webhook.Id, err)
+		}
+	}
+}
+
+func (n *NoSqlDb) PutWebhook(webhook *WebhookDatum) error {
+	var err error
+	fullTableName := n.prefix + webhookTableName
+
+	// Encrypt secret value to be stored
+	webhook.SecretEnc, webhook.SecretIV, err = utility.EncryptSecret(webhook.Secret)
+	if err != nil {
+		log.Printf("Could not encrypt webhook secret: %v\n", err)
+		return err
+	}
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(webhook)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
+
+func (n *NoSqlDb) DeleteWebhook(id string) error {
+	var err error
+	fullTableName := n.prefix + webhookTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	_, err = n.db.DeleteItem(n.ctx, &dynamodb.DeleteItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		log.Printf("Couldn't delete webhook %v because: %v", id, err)
+	}
+
+	return err
+}
+
+func (n *NoSqlDb) GetWebhookDelivery(id string) (*WebhookDeliveryDatum, error) {
+	var err error
+	fullTableName := n.prefix + webhookDeliveryTableName
+
+	keyMap := map[string]types.AttributeValue{}
+	keyMap["id"] = &types.AttributeValueMemberS{Value: id}
+
+	result, err := n.db.GetItem(n.ctx, &dynamodb.GetItemInput{
+		Key:       keyMap,
+		TableName: &fullTableName,
+	})
+	if err != nil {
+		return &WebhookDeliveryDatum{}, err
+	}
+	output := WebhookDeliveryDatum{}
+
+	tempMap := map[string]any{}
+	attributevalue.UnmarshalMap(result.Item, &tempMap)
+	tempBytes, _ := json.Marshal(tempMap)
+	json.Unmarshal(tempBytes, &output)
+
+	return &output, nil
+}
+
+// A webhook's deliveries, newest first
+func (n *NoSqlDb) GetWebhookDeliveries(webhookId string) ([]*WebhookDeliveryDatum, error) {
+	var err error
+	fullTableName := n.prefix + webhookDeliveryTableName
+
+	statement := aws.String(
+		fmt.Sprintf("SELECT * FROM \"%v\" WHERE webhook_id='%v'", fullTableName, webhookId),
+	)
+	output := []*WebhookDeliveryDatum{}
+	results, err := n.QueryDB(statement)
+	if err != nil {
+		return output, err
+	}
+
+	for _, result := range *results {
+		tempDat := WebhookDeliveryDatum{}
+		tempBytes, _ := json.Marshal(result)
+		json.Unmarshal(tempBytes, &tempDat)
+		output = append(output, &tempDat)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].CreatedAt.After(output[j].CreatedAt)
+	})
+
+	return output, nil
+}
+
+func (n *NoSqlDb) PutWebhookDelivery(delivery *WebhookDeliveryDatum) error {
+	var err error
+	fullTableName := n.prefix + webhookDeliveryTableName
+
+	tempMap := map[string]any{}
+	tempBytes, _ := json.Marshal(delivery)
+	json.Unmarshal(tempBytes, &tempMap)
+
+	var item map[string]types.AttributeValue
+	item, err = attributevalue.MarshalMap(tempMap)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.db.PutItem(n.ctx, &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: &fullTableName,
+	})
+
+	return err
+}
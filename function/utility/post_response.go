@@ -4,4 +4,6 @@ type PostResponse struct {
 	Platform string `json:"platform"`
 	Id       string `json:"id"`
 	Url      string `json:"url"`
+	// What was done to the post when the stream ended: edit or delete
+	EndedAction string `json:"ended_action,omitempty"`
 }
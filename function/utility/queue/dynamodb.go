@@ -0,0 +1,182 @@
+package queue
+
+import (
+	"shrampybot/utility/nosqldb"
+	"time"
+)
+
+const (
+	// How long a claimed job stays hidden before another worker may take it
+	dynamoLeaseDuration = 5 * time.Minute
+)
+
+// Keeps the queue and dead letters in DynamoDB so that they outlive the
+// Lambda invocation which enqueued them.
+type DynamoBackend struct{}
+
+func NewDynamoBackend() *DynamoBackend {
+	return &DynamoBackend{}
+}
+
+func (b *DynamoBackend) Enqueue(job *Job) error {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return err
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = job.CreatedAt
+	}
+
+	_, err = n.PutEventQueueJob(toDatum(job, nosqldb.EventQueueStatusPending))
+	return err
+}
+
+func (b *DynamoBackend) Claim(now time.Time, limit int) ([]*Job, error) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return []*Job{}, err
+	}
+
+	due, err := n.GetDueEventQueueJobs(now.Unix())
+	if err != nil {
+		return []*Job{}, err
+	}
+
+	output := []*Job{}
+	for _, datum := range due {
+		if len(output) >= limit {
+			break
+		}
+		claimed, err := n.ClaimEventQueueJob(datum.Id, now.Unix(), now.Add(dynamoLeaseDuration).Unix())
+		if err != nil {
+			return output, err
+		}
+		if claimed {
+			output = append(output, fromDatum(datum))
+		}
+	}
+
+	return output, nil
+}
+
+func (b *DynamoBackend) Complete(job *Job) error {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return err
+	}
+	return n.DeleteEventQueueJob(job.Id)
+}
+
+func (b *DynamoBackend) Retry(job *Job) error {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return err
+	}
+	return n.UpdateEventQueueJob(toDatum(job, nosqldb.EventQueueStatusPending))
+}
+
+func (b *DynamoBackend) DeadLetter(job *Job) error {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return err
+	}
+	err = n.PutEventDeadLetter(toDatum(job, ""))
+	if err != nil {
+		return err
+	}
+	return n.DeleteEventQueueJob(job.Id)
+}
+
+func (b *DynamoBackend) GetDeadLetters() ([]*Job, error) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return []*Job{}, err
+	}
+
+	dead, err := n.GetEventDeadLetters()
+	if err != nil {
+		return []*Job{}, err
+	}
+	output := []*Job{}
+	for _, datum := range dead {
+		output = append(output, fromDatum(datum))
+	}
+	return output, nil
+}
+
+func (b *DynamoBackend) Replay(id string) (*Job, error) {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	datum, err := n.GetEventDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+	if datum.Id == "" {
+		return nil, ErrNotFound
+	}
+
+	job := fromDatum(datum)
+	resetForReplay(job)
+	// Written with a plain put so that replaying a job which is somehow
+	// still queued resets it rather than failing
+	err = n.UpdateEventQueueJob(toDatum(job, nosqldb.EventQueueStatusPending))
+	if err != nil {
+		return nil, err
+	}
+	return job, n.DeleteEventDeadLetter(id)
+}
+
+func (b *DynamoBackend) DeleteDeadLetter(id string) error {
+	n, err := nosqldb.NewClient()
+	if err != nil {
+		return err
+	}
+
+	datum, err := n.GetEventDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	if datum.Id == "" {
+		return ErrNotFound
+	}
+	return n.DeleteEventDeadLetter(id)
+}
+
+func toDatum(job *Job, status string) *nosqldb.EventQueueDatum {
+	datum := nosqldb.EventQueueDatum{
+		Id:            job.Id,
+		Type:          job.Type,
+		Payload:       job.Payload,
+		Status:        status,
+		Attempts:      job.Attempts,
+		CreatedAt:     job.CreatedAt.Unix(),
+		NextAttemptAt: job.NextAttemptAt.Unix(),
+		LastError:     job.LastError,
+	}
+	if !job.FailedAt.IsZero() {
+		datum.FailedAt = job.FailedAt.Unix()
+	}
+	return &datum
+}
+
+func fromDatum(datum *nosqldb.EventQueueDatum) *Job {
+	job := Job{
+		Id:            datum.Id,
+		Type:          datum.Type,
+		Payload:       datum.Payload,
+		Attempts:      datum.Attempts,
+		CreatedAt:     time.Unix(datum.CreatedAt, 0),
+		NextAttemptAt: time.Unix(datum.NextAttemptAt, 0),
+		LastError:     datum.LastError,
+	}
+	if datum.FailedAt != 0 {
+		job.FailedAt = time.Unix(datum.FailedAt, 0)
+	}
+	return &job
+}
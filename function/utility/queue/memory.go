@@ -0,0 +1,144 @@
+package queue
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keeps everything in process memory. Jobs are lost when the process exits.
+type MemoryBackend struct {
+	lock     sync.Mutex
+	pending  map[string]*Job
+	claimed  map[string]*Job
+	dead     map[string]*Job
+	sequence []string
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		pending: map[string]*Job{},
+		claimed: map[string]*Job{},
+		dead:    map[string]*Job{},
+	}
+}
+
+func (b *MemoryBackend) Enqueue(job *Job) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.pending[job.Id] != nil || b.claimed[job.Id] != nil {
+		return nil
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = job.CreatedAt
+	}
+	stored := *job
+	b.pending[job.Id] = &stored
+	b.sequence = append(b.sequence, job.Id)
+	return nil
+}
+
+func (b *MemoryBackend) Claim(now time.Time, limit int) ([]*Job, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	output := []*Job{}
+	remaining := []string{}
+	for _, id := range b.sequence {
+		job := b.pending[id]
+		if job == nil {
+			continue
+		}
+		if len(output) < limit && !job.NextAttemptAt.After(now) {
+			delete(b.pending, id)
+			b.claimed[id] = job
+			claimed := *job
+			output = append(output, &claimed)
+			continue
+		}
+		remaining = append(remaining, id)
+	}
+	b.sequence = remaining
+
+	return output, nil
+}
+
+func (b *MemoryBackend) Complete(job *Job) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.claimed, job.Id)
+	return nil
+}
+
+func (b *MemoryBackend) Retry(job *Job) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.claimed, job.Id)
+	stored := *job
+	b.pending[job.Id] = &stored
+	b.sequence = append(b.sequence, job.Id)
+	return nil
+}
+
+func (b *MemoryBackend) DeadLetter(job *Job) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.claimed, job.Id)
+	stored := *job
+	b.dead[job.Id] = &stored
+	return nil
+}
+
+func (b *MemoryBackend) GetDeadLetters() ([]*Job, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	output := []*Job{}
+	for _, job := range b.dead {
+		dead := *job
+		output = append(output, &dead)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].FailedAt.After(output[j].FailedAt)
+	})
+	return output, nil
+}
+
+func (b *MemoryBackend) Replay(id string) (*Job, error) {
+	b.lock.Lock()
+	job := b.dead[id]
+	if job == nil {
+		b.lock.Unlock()
+		return nil, ErrNotFound
+	}
+	delete(b.dead, id)
+	b.lock.Unlock()
+
+	resetForReplay(job)
+	return job, b.Enqueue(job)
+}
+
+func (b *MemoryBackend) DeleteDeadLetter(id string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.dead[id] == nil {
+		return ErrNotFound
+	}
+	delete(b.dead, id)
+	return nil
+}
+
+func resetForReplay(job *Job) {
+	job.Attempts = 0
+	job.LastError = ""
+	job.FailedAt = time.Time{}
+	job.NextAttemptAt = time.Now()
+}
@@ -0,0 +1,63 @@
+// Package queue runs event processing outside of the request that received
+// the event, with retries and a dead-letter store for jobs that keep failing.
+package queue
+
+import (
+	"context"
+	"errors"
+	"shrampybot/config"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+
+	memoryBackend     *MemoryBackend
+	memoryBackendOnce sync.Once
+)
+
+type Job struct {
+	// Enqueueing a job with an id that's already queued does nothing
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+	// Failed attempts so far
+	Attempts      int       `json:"attempts"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	FailedAt      time.Time `json:"failed_at,omitempty"`
+}
+
+// Storage for queued and dead jobs. Implementations must make Claim safe
+// to call from several workers at once.
+type Backend interface {
+	Enqueue(job *Job) error
+	// Hands out up to limit due jobs, hiding them from other workers
+	// until they're completed, retried or dead-lettered.
+	Claim(now time.Time, limit int) ([]*Job, error)
+	Complete(job *Job) error
+	// Puts a job back to be claimed again after its NextAttemptAt
+	Retry(job *Job) error
+	DeadLetter(job *Job) error
+
+	GetDeadLetters() ([]*Job, error)
+	// Moves a dead job back onto the queue with a clean slate
+	Replay(id string) (*Job, error)
+	DeleteDeadLetter(id string) error
+}
+
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Picks the backend named by EVENT_QUEUE_BACKEND. The memory backend only
+// suits long-running processes; Lambda needs dynamodb, the default.
+func DefaultBackend() Backend {
+	if config.EventQueueBackend == "memory" {
+		memoryBackendOnce.Do(func() {
+			memoryBackend = NewMemoryBackend()
+		})
+		return memoryBackend
+	}
+	return NewDynamoBackend()
+}
@@ -0,0 +1,174 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = 10 * time.Second
+	DefaultMaxDelay    = 10 * time.Minute
+	defaultBatchSize   = 10
+)
+
+// Runs queued jobs through the handler registered for their type. Failed
+// jobs are retried with exponential backoff until MaxAttempts, after which
+// they're moved to the dead letters.
+type Worker struct {
+	Backend     Backend
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	BatchSize   int
+
+	handlers map[string]HandlerFunc
+}
+
+func NewWorker(backend Backend) *Worker {
+	return &Worker{
+		Backend:     backend,
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		BatchSize:   defaultBatchSize,
+		handlers:    map[string]HandlerFunc{},
+	}
+}
+
+func (w *Worker) Handle(jobType string, handler HandlerFunc) {
+	w.handlers[jobType] = handler
+}
+
+// Delay before the next attempt once a job has failed this many times
+func (w *Worker) Backoff(attempts int) time.Duration {
+	return backoff(attempts, w.BaseDelay, w.MaxDelay)
+}
+
+// Backoff with the default delays, for jobs queued having already failed
+func Backoff(attempts int) time.Duration {
+	return backoff(attempts, DefaultBaseDelay, DefaultMaxDelay)
+}
+
+func backoff(attempts int, baseDelay time.Duration, maxDelay time.Duration) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
+
+// Processes one batch of due jobs, returning how many were claimed
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	jobs, err := w.Backend.Claim(time.Now(), w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, job := range jobs {
+		// Hand back what's left rather than start a job the invocation
+		// might not have time to finish
+		if ctx.Err() != nil {
+			w.release(jobs[i:])
+			break
+		}
+		w.process(ctx, job)
+	}
+
+	return len(jobs), nil
+}
+
+// Processes due jobs until none are left or the context is done
+func (w *Worker) Drain(ctx context.Context) error {
+	for ctx.Err() == nil {
+		count, err := w.RunOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Keeps draining the queue until the context is cancelled
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := w.Drain(ctx)
+		if err != nil {
+			log.Printf("Queue worker could not claim jobs: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *Job) {
+	var err error
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		err = fmt.Errorf("no handler for job type %v", job.Type)
+	} else {
+		err = runHandler(ctx, handler, job)
+	}
+
+	if err == nil {
+		err = w.Backend.Complete(job)
+		if err != nil {
+			log.Printf("Could not mark job %v complete: %v\n", job.Id, err)
+		}
+		return
+	}
+
+	job.Attempts++
+	job.LastError = err.Error()
+	log.Printf("Job %v (%v) failed on attempt %v: %v\n", job.Id, job.Type, job.Attempts, err)
+
+	if !ok || job.Attempts >= w.MaxAttempts {
+		job.FailedAt = time.Now()
+		err = w.Backend.DeadLetter(job)
+		if err != nil {
+			log.Printf("Could not dead-letter job %v: %v\n", job.Id, err)
+		}
+		return
+	}
+
+	job.NextAttemptAt = time.Now().Add(w.Backoff(job.Attempts))
+	err = w.Backend.Retry(job)
+	if err != nil {
+		log.Printf("Could not reschedule job %v: %v\n", job.Id, err)
+	}
+}
+
+// Returns claimed jobs to the queue untouched, to be claimed again straight away
+func (w *Worker) release(jobs []*Job) {
+	for _, job := range jobs {
+		err := w.Backend.Retry(job)
+		if err != nil {
+			log.Printf("Could not release job %v: %v\n", job.Id, err)
+		}
+	}
+}
+
+// A panicking handler counts as a failed attempt rather than taking the
+// worker down with it
+func runHandler(ctx context.Context, handler HandlerFunc, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerBackoff(t *testing.T) {
+	w := NewWorker(NewMemoryBackend())
+	w.BaseDelay = time.Second
+	w.MaxDelay = 5 * time.Second
+
+	testCases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: time.Second},
+		{attempts: 2, expected: 2 * time.Second},
+		{attempts: 3, expected: 4 * time.Second},
+		{attempts: 4, expected: 5 * time.Second},
+		{attempts: 20, expected: 5 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, w.Backoff(tc.attempts), "attempts: %v", tc.attempts)
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	assert.Equal(t, DefaultBaseDelay, Backoff(1))
+	assert.Equal(t, 2*DefaultBaseDelay, Backoff(2))
+	assert.Equal(t, DefaultMaxDelay, Backoff(20))
+	assert.Equal(t, NewWorker(NewMemoryBackend()).Backoff(3), Backoff(3))
+}
+
+func TestWorkerCompletesJobs(t *testing.T) {
+	backend := NewMemoryBackend()
+	w := NewWorker(backend)
+	handled := []string{}
+	w.Handle("stream.online", func(ctx context.Context, job *Job) error {
+		handled = append(handled, job.Payload)
+		return nil
+	})
+
+	backend.Enqueue(&Job{Id: "a", Type: "stream.online", Payload: "first"})
+	backend.Enqueue(&Job{Id: "b", Type: "stream.online", Payload: "second"})
+	// Same id as a queued job; should be ignored
+	backend.Enqueue(&Job{Id: "a", Type: "stream.online", Payload: "duplicate"})
+
+	err := w.Drain(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, handled)
+	dead, _ := backend.GetDeadLetters()
+	assert.Empty(t, dead)
+}
+
+func TestWorkerRetriesThenDeadLetters(t *testing.T) {
+	backend := NewMemoryBackend()
+	w := NewWorker(backend)
+	w.MaxAttempts = 3
+	// No waiting between attempts so that the test can drive them directly
+	w.BaseDelay = 0
+	attempts := 0
+	w.Handle("stream.online", func(ctx context.Context, job *Job) error {
+		attempts++
+		return errors.New("mastodon is down")
+	})
+
+	backend.Enqueue(&Job{Id: "a", Type: "stream.online"})
+	for i := 0; i < 5; i++ {
+		w.RunOnce(context.Background())
+	}
+
+	assert.Equal(t, 3, attempts)
+	dead, _ := backend.GetDeadLetters()
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, "a", dead[0].Id)
+		assert.Equal(t, 3, dead[0].Attempts)
+		assert.Equal(t, "mastodon is down", dead[0].LastError)
+		assert.False(t, dead[0].FailedAt.IsZero())
+	}
+}
+
+func TestWorkerWaitsForBackoff(t *testing.T) {
+	backend := NewMemoryBackend()
+	w := NewWorker(backend)
+	w.BaseDelay = time.Hour
+	attempts := 0
+	w.Handle("stream.online", func(ctx context.Context, job *Job) error {
+		attempts++
+		return errors.New("failed")
+	})
+
+	backend.Enqueue(&Job{Id: "a", Type: "stream.online"})
+	w.RunOnce(context.Background())
+	count, _ := w.RunOnce(context.Background())
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 0, count, "job should not be due until its backoff passes")
+}
+
+func TestWorkerStopsBetweenJobsWhenContextDone(t *testing.T) {
+	backend := NewMemoryBackend()
+	w := NewWorker(backend)
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := []string{}
+	w.Handle("stream.online", func(ctx context.Context, job *Job) error {
+		handled = append(handled, job.Id)
+		// Runs out of time partway through the batch
+		cancel()
+		return nil
+	})
+
+	backend.Enqueue(&Job{Id: "a", Type: "stream.online"})
+	backend.Enqueue(&Job{Id: "b", Type: "stream.online"})
+	w.Drain(ctx)
+
+	assert.Equal(t, []string{"a"}, handled)
+
+	// The unstarted job went back on the queue as it was
+	w.Drain(context.Background())
+	assert.Equal(t, []string{"a", "b"}, handled)
+	dead, _ := backend.GetDeadLetters()
+	assert.Empty(t, dead)
+}
+
+func TestWorkerDeadLettersUnknownTypesAndPanics(t *testing.T) {
+	backend := NewMemoryBackend()
+	w := NewWorker(backend)
+	w.MaxAttempts = 1
+	w.Handle("stream.offline", func(ctx context.Context, job *Job) error {
+		panic("nil map")
+	})
+
+	backend.Enqueue(&Job{Id: "a", Type: "channel.raid"})
+	backend.Enqueue(&Job{Id: "b", Type: "stream.offline"})
+	w.Drain(context.Background())
+
+	dead, _ := backend.GetDeadLetters()
+	assert.Len(t, dead, 2)
+}
+
+func TestMemoryBackendReplay(t *testing.T) {
+	backend := NewMemoryBackend()
+	w := NewWorker(backend)
+	w.MaxAttempts = 1
+	fail := true
+	w.Handle("stream.online", func(ctx context.Context, job *Job) error {
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	backend.Enqueue(&Job{Id: "a", Type: "stream.online"})
+	w.Drain(context.Background())
+
+	job, err := backend.Replay("a")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, job.Attempts)
+	assert.Empty(t, job.LastError)
+
+	fail = false
+	w.Drain(context.Background())
+	dead, _ := backend.GetDeadLetters()
+	assert.Empty(t, dead)
+
+	_, err = backend.Replay("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -9,13 +9,21 @@ var (
 		"gsg",
 		"gsg:streamer",
 		"admin",
+		"admin:announcements",
+		"admin:audit",
 		"admin:categories",
 		"admin:collection",
 		"admin:events",
 		"admin:filters",
+		"admin:oidc",
+		"admin:queue",
+		"admin:replay",
 		"admin:stream",
+		"admin:subscriptions",
+		"admin:templates",
 		"admin:tokens",
 		"admin:users",
+		"admin:webhooks",
 	}
 )
 
@@ -0,0 +1,21 @@
+package utility
+
+import "strings"
+
+// JWT subjects are Discord user IDs, unless the user logged in with Twitch,
+// in which case the Twitch user ID is prefixed to keep the two apart.
+const (
+	TwitchSubjectPrefix = "twitch:"
+)
+
+func SubjectForTwitchUser(twitchId string) string {
+	return TwitchSubjectPrefix + twitchId
+}
+
+// Returns the Twitch user ID for a subject issued through a Twitch login
+func TwitchIdFromSubject(sub string) (string, bool) {
+	if !strings.HasPrefix(sub, TwitchSubjectPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(sub, TwitchSubjectPrefix), true
+}
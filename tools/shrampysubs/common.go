@@ -16,27 +16,71 @@ type UserLoginsBody struct {
 	Data *[]string `json:"data"`
 }
 
+type PostResponse struct {
+	Platform string `json:"platform"`
+	Id       string `json:"id"`
+	Url      string `json:"url"`
+}
+
 type LiveStream struct {
 	helix.Stream       `tstype:",extends,required"`
-	DiscordPostId      string    `json:"discord_post_id,omitempty"`
-	DiscordPostUrl     string    `json:"discord_post_url,omitempty"`
-	MastodonPostId     string    `json:"mastodon_post_id,omitempty"`
-	MastodonPostUrl    string    `json:"mastodon_post_url,omitempty"`
-	BlueskyPostId      string    `json:"bluesky_post_id,omitempty"`
-	BlueskyPostUrl     string    `json:"bluesky_post_url,omitempty"`
-	ShrampybotFiltered bool      `json:"shrampybot_filtered"`
-	EndedAt            time.Time `json:"ended_at,omitempty"`
+	Posts              map[string]PostResponse `json:"posts,omitempty"`
+	ShrampybotFiltered bool                    `json:"shrampybot_filtered"`
+	EndedAt            time.Time               `json:"ended_at,omitempty"`
 }
 
 type PutLiveStreamRequest struct {
 	EndNow bool `json:"endNow,omitempty"`
 }
 
+type PollLiveStreamsResponse struct {
+	Active  int      `json:"active"`
+	Live    int      `json:"live"`
+	Updated []string `json:"updated"`
+}
+
+type ReplayRequest struct {
+	MessageId string `json:"message_id,omitempty"`
+	Payload   string `json:"payload,omitempty"`
+	Mode      string `json:"mode"`
+}
+
+type SkippedPost struct {
+	Platform string `json:"platform"`
+	Message  string `json:"message"`
+}
+
+type ReplayResponse struct {
+	Mode             string        `json:"mode"`
+	MessageId        string        `json:"message_id,omitempty"`
+	SubscriptionType string        `json:"subscription_type"`
+	Error            string        `json:"error,omitempty"`
+	Writes           []string      `json:"writes"`
+	SkippedPosts     []SkippedPost `json:"skipped_posts"`
+}
+
 type PutLiveStreamResponse struct {
 	Id     string `json:"id"`
 	Status string `json:"status"`
 }
 
+type MissingSubscription struct {
+	BroadcasterId string `json:"broadcaster_id"`
+	Login         string `json:"login"`
+	Type          string `json:"type"`
+	Version       string `json:"version"`
+	Error         string `json:"error,omitempty"`
+}
+
+type ReconcileResponse struct {
+	Total      int                    `json:"total"`
+	Desired    int                    `json:"desired"`
+	DryRun     bool                   `json:"dry_run"`
+	Created    []*MissingSubscription `json:"created"`
+	Failed     []*MissingSubscription `json:"failed"`
+	Incomplete bool                   `json:"incomplete"`
+}
+
 type LiveStreamResponse struct {
 	Count int           `json:"count"`
 	Data  []*LiveStream `json:"data"`
@@ -140,6 +184,71 @@ func endLiveStream(id string, config *ShrampyConfig) error {
 	return nil
 }
 
+func pollLiveStreams(config *ShrampyConfig) (*PollLiveStreamsResponse, error) {
+	respBody := PollLiveStreamsResponse{}
+	client := http.Client{}
+	request, _ := http.NewRequest("POST", config.Url+"admin/stream/poll", bytes.NewReader([]byte{}))
+	request.Header.Add("Content-type", "application/json")
+	request.Header.Add("Authorization", fmt.Sprintf("bearer %v", config.AdminToken))
+	resp, err := client.Do(request)
+	if err != nil {
+		return &respBody, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &respBody, fmt.Errorf("poll returned status %v", resp.StatusCode)
+	}
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(bodyBytes, &respBody)
+
+	return &respBody, nil
+}
+
+func reconcileSubscriptions(config *ShrampyConfig, dryRun bool) (*ReconcileResponse, error) {
+	respBody := ReconcileResponse{}
+	client := http.Client{}
+	url := config.Url + "admin/subscription/reconcile"
+	if dryRun {
+		url += "?dry_run=true"
+	}
+	request, _ := http.NewRequest("POST", url, bytes.NewReader([]byte{}))
+	request.Header.Add("Content-type", "application/json")
+	request.Header.Add("Authorization", fmt.Sprintf("bearer %v", config.AdminToken))
+	resp, err := client.Do(request)
+	if err != nil {
+		return &respBody, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &respBody, fmt.Errorf("reconcile returned status %v", resp.StatusCode)
+	}
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(bodyBytes, &respBody)
+
+	return &respBody, nil
+}
+
+func replayNotification(config *ShrampyConfig, replay *ReplayRequest) (*ReplayResponse, error) {
+	respBody := ReplayResponse{}
+	replayBytes, _ := json.Marshal(replay)
+	client := http.Client{}
+	request, _ := http.NewRequest("POST", config.Url+"admin/replay", bytes.NewReader(replayBytes))
+	request.Header.Add("Content-type", "application/json")
+	request.Header.Add("Authorization", fmt.Sprintf("bearer %v", config.AdminToken))
+	resp, err := client.Do(request)
+	if err != nil {
+		return &respBody, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &respBody, fmt.Errorf("replay returned status %v", resp.StatusCode)
+	}
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(bodyBytes, &respBody)
+
+	return &respBody, nil
+}
+
 func twitchGetUsers(tc *helix.Client, logins *[]string) (*[]helix.User, error) {
 	users := []helix.User{}
 
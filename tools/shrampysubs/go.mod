@@ -3,9 +3,12 @@ module shrampytools/shrampysubs
 go 1.23.4
 
 require (
-	github.com/akamensky/argparse v1.4.0 // indirect
+	github.com/akamensky/argparse v1.4.0
+	github.com/litui/helix/v3 v3.0.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
-	github.com/litui/helix/v3 v3.0.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
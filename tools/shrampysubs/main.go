@@ -32,10 +32,34 @@ func main() {
 		"reconcile",
 		"unsubscribe_all",
 		"tidy_live",
+		"poll_live",
+		"replay",
 	}, &argparse.Options{
 		Required: true,
 		Help:     "Task to execute",
 	})
+	messageId := parser.String("i", "messageId", &argparse.Options{
+		Required: false,
+		Help:     "Stored EventSub message ID to replay",
+	})
+	payloadFile := parser.String("p", "payloadFile", &argparse.Options{
+		Required: false,
+		Help:     "JSON file holding a notification body to replay",
+	})
+	mode := parser.Selector("m", "mode", []string{
+		"dry_run",
+		"storage_only",
+		"full",
+	}, &argparse.Options{
+		Required: false,
+		Default:  "dry_run",
+		Help:     "Replay mode",
+	})
+
+	dryRun := parser.Flag("d", "dryRun", &argparse.Options{
+		Required: false,
+		Help:     "Only report the subscriptions reconcile would create",
+	})
 
 	err = parser.Parse(os.Args)
 	if err != nil {
@@ -63,10 +87,14 @@ func main() {
 	case "populate":
 		taskPopulate(config)
 	case "reconcile":
-		taskReconcile(config)
+		taskReconcile(config, *dryRun)
 	case "unsubscribe_all":
 		taskUnsubscribeAll(config)
 	case "tidy_live":
 		taskTidyLive(config)
+	case "poll_live":
+		taskPollLive(config)
+	case "replay":
+		taskReplay(config, *messageId, *payloadFile, *mode)
 	}
 }
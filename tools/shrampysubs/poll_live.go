@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Record category, title and tag changes on live streams in case any
+// channel.update notifications were missed. Meant to be run from cron.
+func taskPollLive(config *ShrampyConfig) {
+	fmt.Printf("ShrampyBot Poll Live Streams\n\n")
+
+	result, err := pollLiveStreams(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(11)
+	}
+
+	fmt.Printf("Active streams in ShrampyBot: %v\n", result.Active)
+	fmt.Printf("Confirmed live on Twitch: %v\n", result.Live)
+	fmt.Printf("Streams with changes: %v\n", len(result.Updated))
+	for _, id := range result.Updated {
+		fmt.Printf("  %v\n", id)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Run a stored or hand-crafted EventSub notification through the pipeline
+// again. Defaults to a dry run so that nothing is posted or written.
+func taskReplay(config *ShrampyConfig, messageId string, payloadFile string, mode string) {
+	fmt.Printf("ShrampyBot Replay Notification\n\n")
+
+	replay := &ReplayRequest{
+		MessageId: messageId,
+		Mode:      mode,
+	}
+	if payloadFile != "" {
+		payload, err := os.ReadFile(payloadFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(12)
+		}
+		replay.Payload = string(payload)
+	}
+	if (replay.MessageId == "") == (replay.Payload == "") {
+		fmt.Println("Error: specify exactly one of --messageId or --payloadFile")
+		os.Exit(13)
+	}
+
+	result, err := replayNotification(config, replay)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(11)
+	}
+
+	fmt.Printf("Mode: %v\n", result.Mode)
+	fmt.Printf("Subscription type: %v\n", result.SubscriptionType)
+	if result.Error != "" {
+		fmt.Printf("Pipeline error: %v\n", result.Error)
+	}
+	if result.Mode == "dry_run" {
+		fmt.Printf("Writes skipped: %v\n", len(result.Writes))
+		for _, write := range result.Writes {
+			fmt.Printf("  %v\n", write)
+		}
+	}
+	fmt.Printf("Posts skipped: %v\n", len(result.SkippedPosts))
+	for _, post := range result.SkippedPosts {
+		fmt.Printf("  [%v] %v\n", post.Platform, post.Message)
+	}
+}